@@ -4,6 +4,7 @@
 package cft
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"slices"
@@ -37,6 +38,163 @@ type Template struct {
 	Packages  map[string]*PackageAlias
 }
 
+// Clone returns a deep copy of the template, so that changes to the
+// copy do not affect the original. Aliases in the copy point to the
+// copied anchor nodes.
+func (t Template) Clone() *Template {
+	clones := make(map[*yaml.Node]*yaml.Node)
+
+	retval := &Template{Node: cloneNode(t.Node, clones)}
+
+	if t.Constants != nil {
+		retval.Constants = make(map[string]*yaml.Node)
+		for k, v := range t.Constants {
+			retval.Constants[k] = cloneNode(v, clones)
+		}
+	}
+
+	if t.Packages != nil {
+		retval.Packages = make(map[string]*PackageAlias)
+		for k, v := range t.Packages {
+			p := *v
+			retval.Packages[k] = &p
+		}
+	}
+
+	return retval
+}
+
+// cloneNode copies n and its children, reusing copies that have
+// already been made so that shared nodes stay shared
+func cloneNode(n *yaml.Node, clones map[*yaml.Node]*yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	if c, ok := clones[n]; ok {
+		return c
+	}
+
+	c := &yaml.Node{}
+	*c = *n
+	clones[n] = c
+
+	c.Alias = cloneNode(n.Alias, clones)
+	if n.Content != nil {
+		c.Content = make([]*yaml.Node, len(n.Content))
+		for i, child := range n.Content {
+			c.Content[i] = cloneNode(child, clones)
+		}
+	}
+
+	return c
+}
+
+// ExpandAliases returns a copy of the template with every alias replaced
+// by a copy of its anchored node and every merge key (<<) replaced by the
+// keys it merges, so the tree matches what Map returns. Keys set explicitly
+// in a map take precedence over merged keys, as they do in YAML.
+// Anchors are removed from the copy.
+func (t Template) ExpandAliases() *Template {
+	retval := &Template{Node: expandNode(t.Node)}
+
+	if t.Constants != nil {
+		retval.Constants = make(map[string]*yaml.Node)
+		for k, v := range t.Constants {
+			retval.Constants[k] = expandNode(v)
+		}
+	}
+
+	if t.Packages != nil {
+		retval.Packages = make(map[string]*PackageAlias)
+		for k, v := range t.Packages {
+			p := *v
+			retval.Packages[k] = &p
+		}
+	}
+
+	return retval
+}
+
+// expandNode copies n and its children, inlining aliases and merge keys
+func expandNode(n *yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	if n.Kind == yaml.AliasNode {
+		return expandNode(n.Alias)
+	}
+
+	c := &yaml.Node{}
+	*c = *n
+	c.Anchor = ""
+	c.Alias = nil
+	if n.Content == nil {
+		return c
+	}
+
+	if n.Kind != yaml.MappingNode {
+		c.Content = make([]*yaml.Node, len(n.Content))
+		for i, child := range n.Content {
+			c.Content[i] = expandNode(child)
+		}
+		return c
+	}
+
+	// Explicit keys win over merged keys, wherever they appear in the map
+	explicit := make(map[string]bool)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if !isMergeKey(n.Content[i]) {
+			explicit[n.Content[i].Value] = true
+		}
+	}
+
+	c.Content = make([]*yaml.Node, 0, len(n.Content))
+	merged := make(map[string]bool)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if !isMergeKey(key) {
+			c.Content = append(c.Content, expandNode(key), expandNode(value))
+			continue
+		}
+
+		// The value of a merge key is a map or a sequence of maps,
+		// and earlier maps in the sequence take precedence
+		sources := []*yaml.Node{value}
+		if resolveAlias(value).Kind == yaml.SequenceNode {
+			sources = resolveAlias(value).Content
+		}
+		for _, source := range sources {
+			m := expandNode(source)
+			if m.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(m.Content); j += 2 {
+				k := m.Content[j].Value
+				if explicit[k] || merged[k] {
+					continue
+				}
+				merged[k] = true
+				c.Content = append(c.Content, m.Content[j], m.Content[j+1])
+			}
+		}
+	}
+
+	return c
+}
+
+// isMergeKey returns true if key is a YAML merge key (<<)
+func isMergeKey(key *yaml.Node) bool {
+	return key.Kind == yaml.ScalarNode && key.ShortTag() == "!!merge"
+}
+
+// resolveAlias follows n to its anchored node if it is an alias
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
 // TODO - We really need a convenient Template data structure
 // that lets us easily access elements.
 // t.Resources["MyResource"].Properties["MyProp"]
@@ -44,11 +202,21 @@ type Template struct {
 // Add a Model attribute to the struct and an Init function to populate it.
 // t.Model.Resources
 
-// Map returns the template as a map[string]interface{}
+// Map returns the template as a map[string]interface{}.
+// Aliases and merge keys (<<) are expanded by the decoder, so the map
+// is fully inlined and each aliased value is an independent copy.
+// Use ExpandAliases to get a template node tree that is inlined in the same way.
+// Short-form intrinsics, like !Ref Bucket, are converted to their long form,
+// like {Ref: Bucket}, so that the map does not depend on which form was used.
 func (t Template) Map() map[string]interface{} {
 	var out map[string]interface{}
 
-	err := t.Node.Decode(&out)
+	n, err := LongForm(t.Node)
+	if err != nil {
+		panic(fmt.Errorf("error converting template to map: %s", err))
+	}
+
+	err = n.Decode(&out)
 	if err != nil {
 		panic(fmt.Errorf("error converting template to map: %s", err))
 	}
@@ -56,6 +224,39 @@ func (t Template) Map() map[string]interface{} {
 	return out
 }
 
+// String returns the template as YAML with two space indentation.
+// Keys stay in the order they are in the node tree, and comments are kept,
+// so a template that is parsed and written out again without being edited
+// comes out the same every time. This makes it suitable for writing back
+// a state file after SetPath or RemovePath. Use format.String to also
+// put the sections in the usual CloudFormation order.
+func (t Template) String() (string, error) {
+	b, err := t.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Bytes is like String but returns the YAML as a byte slice
+func (t Template) Bytes() ([]byte, error) {
+	if t.Node == nil {
+		return nil, errors.New("template has no node to serialize")
+	}
+
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(t.Node); err != nil {
+		return nil, fmt.Errorf("unable to serialize template: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("unable to serialize template: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
 // AppendStateMap appends a "State" section to the template
 func AppendStateMap(state Template) *yaml.Node {
 	state.Node.Content[0].Content = append(state.Node.Content[0].Content,
@@ -83,6 +284,24 @@ const (
 	Rain                     Section = "Rain"
 )
 
+// knownSections are the sections listed above
+var knownSections = []Section{
+	AWSTemplateFormatVersion, Resources, Description, Metadata, Parameters, Rules,
+	Mappings, Conditions, Transform, Outputs, State, Rain,
+}
+
+// IsKnown returns true if s is one of the sections that rain knows about,
+// so that tools can point out top level keys that are probably typos
+func (s Section) IsKnown() bool {
+	return slices.Contains(knownSections, s)
+}
+
+// SectionNode is a top level section of a template and its node
+type SectionNode struct {
+	Name Section
+	Node *yaml.Node
+}
+
 // GetResource returns the yaml node for a resource by logical id
 func (t Template) GetResource(name string) (*yaml.Node, error) {
 	return t.GetNode(Resources, name)
@@ -107,6 +326,27 @@ func (t Template) GetNode(section Section, name string) (*yaml.Node, error) {
 	return resource, nil
 }
 
+// GetStringValue returns the scalar value found by following path
+// through the template's maps, starting with a section name, e.g.
+// GetStringValue("State", "FilePath").
+// ok is false if the path is missing or the node is not a scalar.
+func (t Template) GetStringValue(path ...string) (value string, ok bool) {
+	if t.Node == nil || len(t.Node.Content) == 0 {
+		return "", false
+	}
+	n := t.Node.Content[0]
+	for _, key := range path {
+		_, n, _ = s11n.GetMapValue(n, key)
+		if n == nil {
+			return "", false
+		}
+	}
+	if n.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	return n.Value, true
+}
+
 // AddScalarSection adds a section like Description to the template
 func (t Template) AddScalarSection(section Section, val string) error {
 	if t.Node == nil {
@@ -148,6 +388,53 @@ func (t Template) GetSection(section Section) (*yaml.Node, error) {
 	return s, nil
 }
 
+// Sections returns the top level sections of the template in the order
+// they are in the document, including any that are not known sections.
+// It returns nil if the template is empty or is not a map.
+func (t Template) Sections() []SectionNode {
+	if t.Node == nil || len(t.Node.Content) == 0 || t.Node.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	m := t.Node.Content[0]
+	retval := make([]SectionNode, 0, len(m.Content)/2)
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		retval = append(retval, SectionNode{Name: Section(m.Content[i].Value), Node: m.Content[i+1]})
+	}
+	return retval
+}
+
+// Resources returns a map of logical id to resource node.
+// The map is unordered, so use GetSection if template order matters.
+func (t Template) Resources() (map[string]*yaml.Node, error) {
+	return t.sectionMap(Resources)
+}
+
+// Parameters returns a map of parameter name to parameter node
+func (t Template) Parameters() (map[string]*yaml.Node, error) {
+	return t.sectionMap(Parameters)
+}
+
+// Outputs returns a map of output name to output node
+func (t Template) Outputs() (map[string]*yaml.Node, error) {
+	return t.sectionMap(Outputs)
+}
+
+// sectionMap returns the key and value pairs of a mapping section as a map
+func (t Template) sectionMap(section Section) (map[string]*yaml.Node, error) {
+	s, err := t.GetSection(section)
+	if err != nil {
+		return nil, err
+	}
+	if s.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected %s to be a map", section)
+	}
+	retval := make(map[string]*yaml.Node)
+	for i := 0; i < len(s.Content)-1; i += 2 {
+		retval[s.Content[i].Value] = s.Content[i+1]
+	}
+	return retval, nil
+}
+
 // RemoveSection removes a section node from the template
 func (t Template) RemoveSection(section Section) error {
 	return node.RemoveFromMap(t.Node.Content[0], string(Rain))
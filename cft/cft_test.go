@@ -1 +1,293 @@
 package cft
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/internal/s11n"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSectionMaps(t *testing.T) {
+	var n yaml.Node
+	err := yaml.Unmarshal([]byte(`
+Parameters:
+  Name:
+    Type: String
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Queue:
+    Type: AWS::SQS::Queue
+`), &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := Template{Node: &n}
+
+	resources, err := template.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 2 || resources["Queue"].Content[1].Value != "AWS::SQS::Queue" {
+		t.Errorf("unexpected resources: %v", resources)
+	}
+
+	params, err := template.Parameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := params["Name"]; !ok {
+		t.Errorf("expected Name parameter")
+	}
+
+	if _, err := template.Outputs(); err == nil {
+		t.Errorf("expected an error for a missing Outputs section")
+	}
+}
+
+func TestSections(t *testing.T) {
+	var n yaml.Node
+	err := yaml.Unmarshal([]byte(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+Parameters: {}
+Resorces: {}
+State:
+  FilePath: a.yaml
+`), &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := Template{Node: &n}
+
+	names := make([]Section, 0)
+	unknown := make([]Section, 0)
+	for _, s := range template.Sections() {
+		names = append(names, s.Name)
+		if !s.Name.IsKnown() {
+			unknown = append(unknown, s.Name)
+		}
+	}
+
+	expected := []Section{Resources, Parameters, "Resorces", State}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("%#v\n!=\n%#v\n", names, expected)
+	}
+	if !reflect.DeepEqual(unknown, []Section{"Resorces"}) {
+		t.Errorf("unexpected unknown sections: %v", unknown)
+	}
+	if s := template.Sections(); s[3].Node.Content[1].Value != "a.yaml" {
+		t.Errorf("unexpected State node: %v", s[3].Node)
+	}
+
+	if s := (Template{}).Sections(); s != nil {
+		t.Errorf("expected no sections for an empty template, got %v", s)
+	}
+}
+
+func TestGetStringValue(t *testing.T) {
+	var n yaml.Node
+	err := yaml.Unmarshal([]byte(`
+State:
+  FilePath: a.yaml
+  ResourceModels:
+    Bucket: {}
+`), &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := Template{Node: &n}
+
+	if v, ok := template.GetStringValue("State", "FilePath"); !ok || v != "a.yaml" {
+		t.Errorf("expected a.yaml, got %q, %v", v, ok)
+	}
+
+	for _, path := range [][]string{
+		{"State", "Missing"},
+		{"State", "ResourceModels"},
+		{"Resources"},
+	} {
+		if v, ok := template.GetStringValue(path...); ok || v != "" {
+			t.Errorf("%v: expected no value, got %q, %v", path, v, ok)
+		}
+	}
+}
+
+func TestExpandAliases(t *testing.T) {
+	var n yaml.Node
+	err := yaml.Unmarshal([]byte(`
+Resources:
+  First:
+    Type: AWS::S3::Bucket
+    Properties: &props
+      Tags:
+        - Key: Team
+          Value: storage
+      Versioning: Enabled
+  Second:
+    Type: AWS::S3::Bucket
+    Properties: *props
+  Third:
+    Type: AWS::S3::Bucket
+    Properties:
+      <<: *props
+      Versioning: Suspended
+`), &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := Template{Node: &n}
+
+	// MatchAll does not follow aliases
+	if v := s11n.MatchOne(template.Node, "Resources/Second/Properties/Versioning"); v != nil {
+		t.Errorf("expected MatchAll not to follow the alias")
+	}
+
+	expanded := template.ExpandAliases()
+
+	if !reflect.DeepEqual(expanded.Map(), template.Map()) {
+		t.Errorf("%#v\n!=\n%#v\n", expanded.Map(), template.Map())
+	}
+
+	if v := s11n.MatchOne(expanded.Node, "Resources/Second/Properties/Tags/0/Value"); v == nil || v.Value != "storage" {
+		t.Errorf("expected the alias to be inlined")
+	}
+
+	// The explicit key wins over the merged one and there is no << left
+	third := s11n.MatchOne(expanded.Node, "Resources/Third/Properties")
+	if third == nil {
+		t.Fatal("expected Third to have Properties")
+	}
+	keys := make([]string, 0)
+	for i := 0; i < len(third.Content); i += 2 {
+		keys = append(keys, third.Content[i].Value)
+	}
+	if !reflect.DeepEqual(keys, []string{"Tags", "Versioning"}) {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+	if v := s11n.MatchOne(third, "Versioning"); v == nil || v.Value != "Suspended" {
+		t.Errorf("expected the explicit Versioning to win")
+	}
+
+	// Changing the copy leaves the anchored block alone
+	s11n.MatchOne(expanded.Node, "Resources/Second/Properties/Versioning").Value = "changed"
+	if v := s11n.MatchOne(expanded.Node, "Resources/First/Properties/Versioning"); v.Value != "Enabled" {
+		t.Errorf("expected inlined copies to be independent")
+	}
+	if v := s11n.MatchOne(template.Node, "Resources/First/Properties"); v.Anchor != "props" {
+		t.Errorf("expected the original to keep its anchor")
+	}
+}
+
+func TestString(t *testing.T) {
+	source := `# A comment
+Resources:
+    Bucket:
+        Type: AWS::S3::Bucket   # Trailing comment
+        Properties:
+            BucketName: !Sub ${AWS::StackName}-bucket
+            Tags:
+                - Key: a
+                  Value: "1"
+State:
+    Lock: abc
+`
+
+	var n yaml.Node
+	if err := yaml.Unmarshal([]byte(source), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := Template{Node: &n}.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var again yaml.Node
+	if err := yaml.Unmarshal([]byte(first), &again); err != nil {
+		t.Fatal(err)
+	}
+	second, err := Template{Node: &again}.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Errorf("%#v\n!=\n%#v\n", second, first)
+	}
+
+	expected := `# A comment
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket # Trailing comment
+    Properties:
+      BucketName: !Sub ${AWS::StackName}-bucket
+      Tags:
+        - Key: a
+          Value: "1"
+State:
+  Lock: abc
+`
+	if first != expected {
+		t.Errorf("%#v\n!=\n%#v\n", first, expected)
+	}
+
+	if _, err := (Template{}).String(); err == nil {
+		t.Errorf("expected an error for a template without a node")
+	}
+}
+
+func TestShortFormIntrinsics(t *testing.T) {
+	short := `Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Ref Name
+      Arn: !GetAtt Other.Arn
+      Text: !Sub ${AWS::Region}-x
+      Joined: !Join
+        - "-"
+        - - a
+          - !Ref Name
+`
+	long := `Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: {Ref: Name}
+      Arn: {Fn::GetAtt: [Other, Arn]}
+      Text: {Fn::Sub: "${AWS::Region}-x"}
+      Joined: {Fn::Join: ["-", [a, {Ref: Name}]]}
+`
+
+	decode := func(s string) Template {
+		var n yaml.Node
+		if err := yaml.Unmarshal([]byte(s), &n); err != nil {
+			t.Fatal(err)
+		}
+		return Template{Node: &n}
+	}
+
+	shortTemplate := decode(short)
+	shortMap := shortTemplate.Map()
+	longMap := decode(long).Map()
+	if !reflect.DeepEqual(shortMap, longMap) {
+		t.Errorf("%#v\n!=\n%#v\n", shortMap, longMap)
+	}
+
+	// Map does not change the node tree, so the short form is written out again
+	out, err := shortTemplate.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != short {
+		t.Errorf("%s\n!=\n%s\n", out, short)
+	}
+
+	if _, err := LongForm(decode("A: !GetAtt Missing").Node); err == nil {
+		t.Errorf("expected an error for a GetAtt without an attribute")
+	}
+}
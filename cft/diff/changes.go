@@ -0,0 +1,172 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change describes a single value that was added, removed, or changed
+type Change struct {
+	// Path is the location of the value, as a list of map keys (string)
+	// and slice indices (int)
+	Path []interface{}
+
+	// Mode is one of Added, Removed, Changed, or TypeChanged
+	Mode Mode
+
+	// Value is the new value for Added and Changed, and the old value for Removed
+	Value interface{}
+}
+
+// PathString returns the path as a /-separated string, e.g. Tags/0/Value
+func (c Change) PathString() string {
+	parts := make([]string, len(c.Path))
+	for i, p := range c.Path {
+		parts[i] = fmt.Sprint(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Summary counts the changes in a Diff
+type Summary struct {
+	Added   int
+	Removed int
+	Changed int
+
+	// Changes holds each change along with the path to the deepest changed value
+	Changes []Change
+}
+
+// Total returns the number of changed values
+func (s Summary) Total() int {
+	return s.Added + s.Removed + s.Changed
+}
+
+// String returns a short description like "2 changed, 1 added"
+func (s Summary) String() string {
+	parts := make([]string, 0)
+	if s.Changed > 0 {
+		parts = append(parts, fmt.Sprintf("%d changed", s.Changed))
+	}
+	if s.Added > 0 {
+		parts = append(parts, fmt.Sprintf("%d added", s.Added))
+	}
+	if s.Removed > 0 {
+		parts = append(parts, fmt.Sprintf("%d removed", s.Removed))
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func summarize(d Diff) Summary {
+	s := Summary{Changes: Changes(d)}
+	for _, c := range s.Changes {
+		switch c.Mode {
+		case Added:
+			s.Added++
+		case Removed:
+			s.Removed++
+		default:
+			s.Changed++
+		}
+	}
+	return s
+}
+
+// Changes returns the deepest values in d that are not Unchanged,
+// ordered by map key and slice index
+func Changes(d Diff) []Change {
+	return appendChanges(make([]Change, 0), d, []interface{}{})
+}
+
+func appendChanges(changes []Change, d Diff, path []interface{}) []Change {
+	switch v := d.(type) {
+	case dmap:
+		keys := v.keys()
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			changes = appendChanges(changes, v[k], subPath(path, k))
+		}
+	case slice:
+		for i, e := range v {
+			e, i := sliceIndex(e, i)
+			changes = appendChanges(changes, e, subPath(path, i))
+		}
+	case value:
+		if v.mode != Unchanged {
+			changes = append(changes, Change{Path: path, Mode: v.mode, Value: v.val})
+		}
+	}
+
+	return changes
+}
+
+// LeafCounts returns the number of leaf values in d that are not Unchanged,
+// and the total number of leaf values that were compared. A map or slice
+// that was added or removed as a whole counts as all of the values in it.
+func LeafCounts(d Diff) (differ int, total int) {
+	switch v := d.(type) {
+	case dmap:
+		for _, e := range v {
+			dd, dt := LeafCounts(e)
+			differ += dd
+			total += dt
+		}
+	case slice:
+		for i, e := range v {
+			e, _ := sliceIndex(e, i)
+			dd, dt := LeafCounts(e)
+			differ += dd
+			total += dt
+		}
+	case value:
+		total = countLeaves(v.val)
+		if v.mode != Unchanged {
+			differ = total
+		}
+	}
+
+	return differ, total
+}
+
+// DriftRatio returns the fraction of the leaf values in d that differ,
+// from 0 for no differences to 1 when every value differs.
+// It is 0 if there are no values to compare.
+func DriftRatio(d Diff) float64 {
+	differ, total := LeafCounts(d)
+	if total == 0 {
+		return 0
+	}
+	return float64(differ) / float64(total)
+}
+
+// countLeaves returns the number of scalar values in v, counting
+// an empty map or slice as a single value
+func countLeaves(v interface{}) int {
+	n := 0
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for _, e := range vv {
+			n += countLeaves(e)
+		}
+	case []interface{}:
+		for _, e := range vv {
+			n += countLeaves(e)
+		}
+	default:
+		return 1
+	}
+	return max(n, 1)
+}
+
+// subPath returns a copy of path with elem appended,
+// so that sibling paths do not share a backing array
+func subPath(path []interface{}, elem interface{}) []interface{} {
+	out := make([]interface{}, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, elem)
+}
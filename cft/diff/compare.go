@@ -1,77 +1,391 @@
 package diff
 
 import (
+	"fmt"
 	"math"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/aws-cloudformation/rain/cft"
+	"gopkg.in/yaml.v3"
 )
 
-// New returns a Diff that represents the difference between two templates
+// New returns a Diff that represents the difference between two templates.
+// A template with no node tree is treated as an empty template.
 func New(a, b cft.Template) Diff {
-	return CompareMaps(a.Map(), b.Map())
+	return Templates(&a, &b)
+}
+
+// Templates returns a Diff that represents the difference between two templates.
+// It is the same as New, but takes pointers to fit callers that might not
+// have one of the templates, such as a state file that doesn't exist yet.
+// A nil template, or one with no node tree or an empty document, is
+// compared as an empty template, so every section of the other one shows
+// as added or removed.
+//
+// This would be a method on cft.Template, but the diff package already
+// depends on cft, so cft can't return a Diff.
+func Templates(old, new *cft.Template) Diff {
+	return CompareMaps(templateMap(old), templateMap(new))
+}
+
+// templateMap returns t as a map, or an empty map if t has no content
+func templateMap(t *cft.Template) map[string]interface{} {
+	if t == nil || t.Node == nil {
+		return map[string]interface{}{}
+	}
+	if t.Node.Kind == yaml.DocumentNode && len(t.Node.Content) == 0 {
+		return map[string]interface{}{}
+	}
+	m := t.Map()
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	return m
 }
 
 func compareValues(old, new interface{}) Diff {
+	return comparer{}.values("", old, new)
+}
+
+func compareSlices(old, new []interface{}) Diff {
+	return comparer{}.slices("", old, new)
+}
+
+// CompareMaps returns a Diff that represents the difference between two maps.
+//
+// Numbers are compared by value, however they are stored, because a template
+// or state file may have 80 where CloudFormation or Cloud Control API
+// returns 80.0 or "80". Values decoded from YAML carry their tag in their
+// type: !!int is an int, !!float a float64, and !!str a string. The rules are:
+//
+//   - Two numbers of any type are unchanged if they are numerically equal, so 80 == 80.0
+//   - A number and a string are unchanged if the string is a plain decimal
+//     number with the same value, like "80", "80.0", "-1.5" or "1e3".
+//     Hex, octal, "Inf", "NaN", and strings with spaces or underscores are not numbers.
+//   - Two strings are always compared as strings, so "80" != "80.0"
+//   - Booleans are never numbers, so true != 1 and "true" != true
+//
+// The comparison can be changed with options. Without any, the whole of
+// both maps is compared, slices are compared by position, strings must
+// match exactly, and numbers follow the rules above:
+//
+//   - Ignore leaves paths out of the comparison
+//   - IdentityKeys matches the elements of slices by a key instead of by position
+//   - IgnoreCase treats strings that only differ by case as unchanged
+//   - ExactNumbers compares numbers by type and value, so 80 != 80.0 != "80"
+func CompareMaps(old, new map[string]interface{}, opts ...CompareOption) Diff {
+	c := comparer{ignore: make(map[string]bool), identityKeys: make(map[string]string)}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c.maps("", old, new)
+}
+
+// CompareOption changes how CompareMaps compares two maps
+type CompareOption func(*comparer)
+
+// Ignore treats the values at the given paths as Unchanged. Paths are
+// /-separated keys and slice indexes, in the same form as
+// Change.PathString, e.g. Tags/0/Value.
+func Ignore(paths ...string) CompareOption {
+	return func(c *comparer) {
+		for _, path := range paths {
+			c.ignore[strings.Trim(path, "/")] = true
+		}
+	}
+}
+
+// IdentityKeys matches the elements of the slices at the paths in keys by
+// the value of a key instead of by position. For example, {"Tags": "Key"}
+// matches tags by their Key, so that reordering the tags is not a change.
+// Slices that contain an element without the key are compared by position.
+func IdentityKeys(keys map[string]string) CompareOption {
+	return func(c *comparer) {
+		for path, key := range keys {
+			c.identityKeys[strings.Trim(path, "/")] = key
+		}
+	}
+}
+
+// IgnoreCase treats strings that only differ by case, like ENABLED and
+// Enabled, as Unchanged. They keep the value from new, like changed values
+// do, so that a diff shows the live value as it is, rather than the stored
+// one. Map keys, and the values of identity keys, are still compared exactly.
+func IgnoreCase() CompareOption {
+	return func(c *comparer) {
+		c.ignoreCase = true
+	}
+}
+
+// ExactNumbers turns off the numeric rules of CompareMaps, so that numbers
+// are only unchanged if they have the same type and value
+func ExactNumbers() CompareOption {
+	return func(c *comparer) {
+		c.exactNumbers = true
+	}
+}
+
+// comparer builds a Diff, keeping track of the path to each value
+// so that ignored paths can be skipped
+type comparer struct {
+	ignore       map[string]bool
+	identityKeys map[string]string
+
+	// ignoreCase compares strings without regard to case
+	ignoreCase bool
+
+	// exactNumbers compares numbers like any other value
+	exactNumbers bool
+}
+
+func (c comparer) ignored(path string) bool {
+	return c.ignore[path]
+}
+
+func join(path string, key interface{}) string {
+	if path == "" {
+		return fmt.Sprint(key)
+	}
+	return fmt.Sprintf("%s/%v", path, key)
+}
+
+func (c comparer) values(path string, old, new interface{}) Diff {
+	if !c.exactNumbers && numbersEqual(old, new) {
+		return value{old, Unchanged, nil}
+	}
+
 	if reflect.TypeOf(old) != reflect.TypeOf(new) {
 
 		// In YAML there is no difference between "" and null
 		if old == "" && new == nil {
-			return value{new, Unchanged}
+			return value{new, Unchanged, nil}
 		}
 
-		return value{new, Changed}
+		// Values that appear or disappear, or numbers that are stored
+		// differently, are ordinary changes
+		if old == nil || new == nil || typeName(old) == typeName(new) {
+			return value{new, Changed, old}
+		}
+
+		return value{new, TypeChanged, old}
 	}
 
 	switch v := old.(type) {
 	case []interface{}:
-		return compareSlices(v, new.([]interface{}))
+		return c.slices(path, v, new.([]interface{}))
 	case map[string]interface{}:
-		return CompareMaps(v, new.(map[string]interface{}))
+		return c.maps(path, v, new.(map[string]interface{}))
+	case string:
+		if v == new.(string) {
+			break
+		}
+		if c.ignoreCase && strings.EqualFold(v, new.(string)) {
+			return value{new, Unchanged, nil}
+		}
+		return value{new, Changed, old}
 	default:
 		if !reflect.DeepEqual(old, new) {
-			return value{new, Changed}
+			return value{new, Changed, old}
 		}
 	}
 
-	return value{old, Unchanged}
+	return value{old, Unchanged, nil}
 }
 
-func compareSlices(old, new []interface{}) Diff {
+func (c comparer) slices(path string, old, new []interface{}) Diff {
+	if key, ok := c.identityKeys[path]; ok {
+		if d, ok := c.slicesByKey(path, key, old, new); ok {
+			return d
+		}
+	}
+
 	max := int(math.Max(float64(len(old)), float64(len(new))))
 	d := make(slice, max)
 
 	for i := 0; i < max; i++ {
+		p := join(path, i)
 		if i >= len(old) {
-			d[i] = value{new[i], Added}
+			if c.ignored(p) {
+				d[i] = value{new[i], Unchanged, nil}
+			} else {
+				d[i] = value{new[i], Added, nil}
+			}
 		} else if i >= len(new) {
-			d[i] = value{old[i], Removed}
+			if c.ignored(p) {
+				d[i] = value{old[i], Unchanged, nil}
+			} else {
+				d[i] = value{old[i], Removed, nil}
+			}
+		} else if c.ignored(p) {
+			d[i] = value{new[i], Unchanged, nil}
 		} else {
-			d[i] = compareValues(old[i], new[i])
+			d[i] = c.values(p, old[i], new[i])
 		}
 	}
 
 	return d
 }
 
-func CompareMaps(old, new map[string]interface{}) Diff {
+func (c comparer) maps(path string, old, new map[string]interface{}) Diff {
 	d := make(dmap)
 
 	// New and updated keys
 	for key, val := range new {
-		if _, ok := old[key]; !ok {
-			d[key] = value{val, Added}
+		p := join(path, key)
+		if c.ignored(p) {
+			d[key] = value{val, Unchanged, nil}
+		} else if _, ok := old[key]; !ok {
+			d[key] = value{val, Added, nil}
 		} else {
-			d[key] = compareValues(old[key], val)
+			d[key] = c.values(p, old[key], val)
 		}
 	}
 
 	// Removed keys
 	for key, val := range old {
 		if _, ok := new[key]; !ok {
-			d[key] = value{val, Removed}
+			if c.ignored(join(path, key)) {
+				d[key] = value{val, Unchanged, nil}
+			} else {
+				d[key] = value{val, Removed, nil}
+			}
 		}
 	}
 
 	return d
 }
+
+// identity returns the value of key in v, if v is a map that has it
+func identity(v interface{}, key string) (interface{}, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	id, ok := m[key]
+	return id, ok
+}
+
+// slicesByKey compares slices of maps by matching elements with the same
+// value for key. Elements are in the order of new, followed by removed elements,
+// which keep their index in old. ok is false if any element does not have the key.
+func (c comparer) slicesByKey(path string, key string, old, new []interface{}) (d Diff, ok bool) {
+	oldIds := make([]interface{}, len(old))
+	for i, v := range old {
+		if oldIds[i], ok = identity(v, key); !ok {
+			return nil, false
+		}
+	}
+
+	matched := make([]bool, len(old))
+	s := make(slice, 0, len(new))
+
+	for i, v := range new {
+		id, ok := identity(v, key)
+		if !ok {
+			return nil, false
+		}
+
+		p := join(path, i)
+		match := -1
+		for j, oldId := range oldIds {
+			if !matched[j] && reflect.DeepEqual(oldId, id) {
+				match = j
+				break
+			}
+		}
+
+		switch {
+		case c.ignored(p):
+			s = append(s, value{v, Unchanged, nil})
+		case match < 0:
+			s = append(s, value{v, Added, nil})
+		default:
+			s = append(s, c.values(p, old[match], v))
+		}
+		if match >= 0 {
+			matched[match] = true
+		}
+	}
+
+	for j, v := range old {
+		if matched[j] {
+			continue
+		}
+		if c.ignored(join(path, j)) {
+			s = append(s, removedAt{value{v, Unchanged, nil}, j})
+		} else {
+			s = append(s, removedAt{value{v, Removed, nil}, j})
+		}
+	}
+
+	return s, true
+}
+
+// decimalNumber matches the strings that numbersEqual treats as numbers
+var decimalNumber = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// numericValue returns v as a float64 if it is a number,
+// or a string that holds a plain decimal number
+func numericValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		if !decimalNumber.MatchString(n) {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// numbersEqual returns true if old and new are numerically equal
+// and at least one of them is a number rather than a string.
+// See CompareMaps for the rules.
+func numbersEqual(old, new interface{}) bool {
+	_, oldIsString := old.(string)
+	_, newIsString := new.(string)
+	if oldIsString && newIsString {
+		return false
+	}
+
+	a, ok := numericValue(old)
+	if !ok {
+		return false
+	}
+	b, ok := numericValue(new)
+	if !ok {
+		return false
+	}
+	return a == b
+}
+
+// typeName returns a short name for the type of a decoded YAML or JSON value
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, float64:
+		return "number"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -23,6 +23,10 @@ const (
 	// Changed represents a modified value
 	Changed Mode = ">"
 
+	// TypeChanged represents a value that was replaced by a value of a
+	// different type, e.g. a string that became a list
+	TypeChanged Mode = "~"
+
 	// Involved represents a value that contains changes but is not wholly new itself
 	Involved Mode = "|"
 
@@ -48,14 +52,25 @@ type Diff interface {
 	// The long flag determines whether to produce long or short output
 	Format(bool) string
 
+	// FormatJSON returns the Diff as nested JSON with an explicit mode
+	// for every element, so that consumers don't need to parse Format's output
+	FormatJSON() string
+
 	// Value returns the value represented by the Diff
 	Value() interface{}
+
+	// Summary counts the values that were added, removed, or changed
+	// anywhere in the Diff, not only at the top level
+	Summary() Summary
 }
 
 // value represents a difference between values of any type
 type value struct {
 	val  interface{}
 	mode Mode
+
+	// from is the old value when mode is Changed or TypeChanged
+	from interface{}
 }
 
 // Mode returns the value's mode
@@ -68,14 +83,35 @@ func (v value) Value() interface{} {
 	return v.val
 }
 
+// Summary returns a summary of the value's changes
+func (v value) Summary() Summary {
+	return summarize(v)
+}
+
 // String returns a string representation of the value
 func (v value) String() string {
 	return fmt.Sprintf("%s%v", v.Mode(), v.Value())
 }
 
+// removedAt is an element that was removed from a slice that is compared
+// by key, along with its index in the old slice
+type removedAt struct {
+	value
+	index int
+}
+
 // slice represents a difference between two slices
 type slice []Diff
 
+// sliceIndex returns the index that the element of a slice at position i is
+// reported at, which is its old index if it was matched by key and removed
+func sliceIndex(e Diff, i int) (Diff, int) {
+	if r, ok := e.(removedAt); ok {
+		return r.value, r.index
+	}
+	return e, i
+}
+
 // Mode returns the slice's mode
 func (s slice) Mode() Mode {
 	for _, v := range s {
@@ -98,6 +134,11 @@ func (s slice) Value() interface{} {
 	return out
 }
 
+// Summary returns a summary of the slice's changes
+func (s slice) Summary() Summary {
+	return summarize(s)
+}
+
 // String returns a string representation of the slice
 func (s slice) String() string {
 	parts := make([]string, len(s))
@@ -147,6 +188,11 @@ func (m dmap) keys() []string {
 	return keys
 }
 
+// Summary returns a summary of the dmap's changes
+func (m dmap) Summary() Summary {
+	return summarize(m)
+}
+
 // String returns a string representation of the dmap
 func (m dmap) String() string {
 	keys := make([]string, 0)
@@ -190,7 +236,7 @@ func GetResourceActions(d Diff) map[string]ActionType {
 					actions[rname] = Update
 				case Unchanged:
 					actions[rname] = None
-				case Changed:
+				case Changed, TypeChanged:
 					actions[rname] = Update
 				}
 			}
@@ -1,8 +1,12 @@
 package diff
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"gopkg.in/yaml.v3"
 )
 
 type compareTest struct {
@@ -38,10 +42,51 @@ func TestCompareScalar(t *testing.T) {
 			"foo", "bar", "(>)bar", Changed,
 		},
 		{
-			"foo", 1, "(>)1", Changed,
+			"foo", 1, "(~)1", TypeChanged,
+		},
+		{
+			"foo", []int{1, 2, 3}, "(~)[1 2 3]", TypeChanged,
+		},
+		{
+			1, 1.5, "(>)1.5", Changed,
+		},
+		{
+			nil, "foo", "(>)foo", Changed,
+		},
+	})
+}
+
+func TestCompareNumbers(t *testing.T) {
+	testCompare(t, []compareTest{
+		{
+			80, 80.0, "(=)80", Unchanged,
+		},
+		{
+			80, "80", "(=)80", Unchanged,
+		},
+		{
+			80.0, "80", "(=)80", Unchanged,
+		},
+		{
+			"80.0", 80, "(=)80.0", Unchanged,
+		},
+		{
+			int64(80), 80.0, "(=)80", Unchanged,
+		},
+		{
+			80, 80.5, "(>)80.5", Changed,
+		},
+		{
+			80, "81", "(~)81", TypeChanged,
+		},
+		{
+			"80", "80.0", "(>)80.0", Changed,
+		},
+		{
+			80, "0x50", "(~)0x50", TypeChanged,
 		},
 		{
-			"foo", []int{1, 2, 3}, "(>)[1 2 3]", Changed,
+			1, true, "(~)true", TypeChanged,
 		},
 	})
 }
@@ -174,3 +219,289 @@ func TestCompare(t *testing.T) {
 		},
 	})
 }
+
+func TestChanges(t *testing.T) {
+	d := CompareMaps(
+		map[string]interface{}{
+			"foo": "bar",
+			"baz": []interface{}{"a", "b"},
+			"quux": map[string]interface{}{
+				"mooz": "xyzzy",
+			},
+		},
+		map[string]interface{}{
+			"foo": "bar",
+			"baz": []interface{}{"a", "c", "d"},
+			"new": "value",
+		},
+	)
+
+	expected := []Change{
+		{Path: []interface{}{"baz", 1}, Mode: Changed, Value: "c"},
+		{Path: []interface{}{"baz", 2}, Mode: Added, Value: "d"},
+		{Path: []interface{}{"new"}, Mode: Added, Value: "value"},
+		{Path: []interface{}{"quux"}, Mode: Removed, Value: map[string]interface{}{"mooz": "xyzzy"}},
+	}
+
+	actual := Changes(d)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%#v\n!=\n%#v", actual, expected)
+	}
+
+	if len(Changes(CompareMaps(map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1}))) != 0 {
+		t.Errorf("expected no changes for identical maps")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	d := CompareMaps(
+		map[string]interface{}{
+			"a": "1",
+			"b": map[string]interface{}{"c": "2", "d": "3"},
+			"e": []interface{}{"x"},
+		},
+		map[string]interface{}{
+			"a": "changed",
+			"b": map[string]interface{}{"c": "2", "d": "changed"},
+			"f": "new",
+		},
+	)
+
+	s := d.Summary()
+	if s.Added != 1 || s.Removed != 1 || s.Changed != 2 || s.Total() != 4 {
+		t.Errorf("unexpected summary counts: %+v", s)
+	}
+
+	paths := make([]string, 0)
+	for _, c := range s.Changes {
+		paths = append(paths, c.PathString())
+	}
+	expected := []string{"a", "b/d", "e", "f"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("%v != %v", paths, expected)
+	}
+
+	if s.String() != "2 changed, 1 added, 1 removed" {
+		t.Errorf("unexpected summary string: %s", s.String())
+	}
+
+	if u := CompareMaps(map[string]interface{}{}, map[string]interface{}{}).Summary(); u.Total() != 0 {
+		t.Errorf("expected no changes, got %+v", u)
+	}
+}
+
+func TestDriftRatio(t *testing.T) {
+	d := CompareMaps(
+		map[string]interface{}{
+			"a": "1",
+			"b": map[string]interface{}{"c": "2", "d": "3"},
+			"e": []interface{}{"x", "y"},
+		},
+		map[string]interface{}{
+			"a": "1",
+			"b": map[string]interface{}{"c": "2", "d": "changed"},
+		},
+	)
+
+	// d and both elements of e differ, out of a, c, d, and e's two elements
+	differ, total := LeafCounts(d)
+	if differ != 3 || total != 5 {
+		t.Errorf("expected 3 of 5, got %d of %d", differ, total)
+	}
+
+	if r := DriftRatio(d); r != 0.6 {
+		t.Errorf("expected 0.6, got %v", r)
+	}
+
+	if r := DriftRatio(CompareMaps(map[string]interface{}{}, map[string]interface{}{})); r != 0 {
+		t.Errorf("expected 0 for empty maps, got %v", r)
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	old := map[string]interface{}{
+		"Name":         "a",
+		"CreationTime": "yesterday",
+		"Tags":         []interface{}{map[string]interface{}{"Key": "k", "Value": "v"}},
+		"Removed":      "gone",
+	}
+	new := map[string]interface{}{
+		"Name":         "a",
+		"CreationTime": "today",
+		"Tags":         []interface{}{map[string]interface{}{"Key": "k", "Value": "changed"}},
+		"LastModified": "now",
+	}
+
+	d := CompareMaps(old, new, Ignore("CreationTime", "LastModified", "/Tags/0/Value", "Removed"))
+	if d.Mode() != Unchanged {
+		t.Errorf("expected ignored paths to be unchanged: %s", d)
+	}
+
+	d = CompareMaps(old, new, Ignore("CreationTime"))
+	paths := make([]string, 0)
+	for _, c := range Changes(d) {
+		paths = append(paths, c.PathString())
+	}
+	expected := []string{"LastModified", "Removed", "Tags/0/Value"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("%v != %v", paths, expected)
+	}
+}
+
+func TestIdentityKeys(t *testing.T) {
+	tag := func(k, v string) interface{} {
+		return map[string]interface{}{"Key": k, "Value": v}
+	}
+	old := map[string]interface{}{
+		"Tags": []interface{}{tag("a", "1"), tag("b", "2"), tag("c", "3")},
+	}
+	new := map[string]interface{}{
+		"Tags": []interface{}{tag("b", "2"), tag("a", "changed"), tag("d", "4")},
+	}
+	keys := map[string]string{"Tags": "Key"}
+
+	paths := func(d Diff) []string {
+		retval := make([]string, 0)
+		for _, c := range Changes(d) {
+			retval = append(retval, fmt.Sprintf("%s %s", c.Mode, c.PathString()))
+		}
+		return retval
+	}
+
+	expected := []string{"(>) Tags/1/Value", "(+) Tags/2", "(-) Tags/2"}
+	if actual := paths(CompareMaps(old, new, IdentityKeys(keys))); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v != %v", actual, expected)
+	}
+
+	// Removed elements are ignored by their index in old
+	expected = []string{"(>) Tags/1/Value"}
+	if actual := paths(CompareMaps(old, new, Ignore("Tags/2"), IdentityKeys(keys))); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v != %v", actual, expected)
+	}
+
+	// Reordering alone is not a change
+	reordered := map[string]interface{}{
+		"Tags": []interface{}{tag("c", "3"), tag("a", "1"), tag("b", "2")},
+	}
+	if d := CompareMaps(old, reordered, IdentityKeys(keys)); d.Mode() != Unchanged {
+		t.Errorf("expected reordered tags to be unchanged: %s", d)
+	}
+
+	// Without the key, slices are compared by position
+	if d := CompareMaps(old, reordered); d.Mode() == Unchanged {
+		t.Errorf("expected positional comparison to find changes")
+	}
+
+	// Elements without the key fall back to position
+	mixed := map[string]interface{}{"Tags": []interface{}{"x"}}
+	if actual := paths(CompareMaps(mixed, mixed, IdentityKeys(keys))); len(actual) != 0 {
+		t.Errorf("expected no changes, got %v", actual)
+	}
+}
+
+func TestIgnoreCase(t *testing.T) {
+	old := map[string]interface{}{
+		"Status":     "ENABLED",
+		"Versioning": map[string]interface{}{"Status": "Suspended"},
+		"Name":       "a",
+	}
+	new := map[string]interface{}{
+		"Status":     "Enabled",
+		"Versioning": map[string]interface{}{"Status": "suspended"},
+		"Name":       "b",
+	}
+
+	d := CompareMaps(old, new, IgnoreCase())
+	paths := make([]string, 0)
+	for _, c := range Changes(d) {
+		paths = append(paths, c.PathString())
+	}
+	if expected := []string{"Name"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("%v != %v", paths, expected)
+	}
+
+	// The new values are shown as they are
+	expected := "(|)map[Name:(>)b Status:(=)Enabled Versioning:(=)map[Status:(=)suspended]]"
+	if actual := d.String(); actual != expected {
+		t.Errorf("%s != %s", actual, expected)
+	}
+
+	// Case still counts without the option
+	if changes := Changes(CompareMaps(old, new)); len(changes) != 3 {
+		t.Errorf("expected 3 changes, got %d", len(changes))
+	}
+}
+
+func TestCompareOptions(t *testing.T) {
+	old := map[string]interface{}{
+		"Port":   80,
+		"Status": "ENABLED",
+		"Time":   "yesterday",
+		"Tags":   []interface{}{map[string]interface{}{"Key": "a"}, map[string]interface{}{"Key": "b"}},
+	}
+	new := map[string]interface{}{
+		"Port":   80.0,
+		"Status": "Enabled",
+		"Time":   "today",
+		"Tags":   []interface{}{map[string]interface{}{"Key": "b"}, map[string]interface{}{"Key": "a"}},
+	}
+
+	paths := func(d Diff) []string {
+		retval := make([]string, 0)
+		for _, c := range Changes(d) {
+			retval = append(retval, c.PathString())
+		}
+		return retval
+	}
+
+	cases := []struct {
+		opts     []CompareOption
+		expected []string
+	}{
+		{nil, []string{"Status", "Tags/0/Key", "Tags/1/Key", "Time"}},
+		{[]CompareOption{Ignore("Time")}, []string{"Status", "Tags/0/Key", "Tags/1/Key"}},
+		{[]CompareOption{IdentityKeys(map[string]string{"Tags": "Key"})}, []string{"Status", "Time"}},
+		{[]CompareOption{IgnoreCase()}, []string{"Tags/0/Key", "Tags/1/Key", "Time"}},
+		{[]CompareOption{ExactNumbers(), Ignore("Tags", "Time")}, []string{"Port", "Status"}},
+		{[]CompareOption{Ignore("Time"), IdentityKeys(map[string]string{"Tags": "Key"}), IgnoreCase()}, []string{}},
+	}
+
+	for i, c := range cases {
+		if actual := paths(CompareMaps(old, new, c.opts...)); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("case %d: %v != %v", i, actual, c.expected)
+		}
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte("Resources:\n  Bucket:\n    Type: AWS::S3::Bucket\n"), &node); err != nil {
+		t.Fatal(err)
+	}
+	template := &cft.Template{Node: &node}
+
+	if d := Templates(template, template); d.Mode() != Unchanged {
+		t.Errorf("expected a template to match itself: %s", d)
+	}
+
+	empty := []*cft.Template{
+		nil,
+		{},
+		{Node: &yaml.Node{Kind: yaml.DocumentNode}},
+	}
+	for _, e := range empty {
+		if d := Templates(e, e); d.Mode() != Unchanged {
+			t.Errorf("expected empty templates to match: %s", d)
+		}
+
+		expected := "(|)map[Resources:(+)map[Bucket:map[Type:AWS::S3::Bucket]]]"
+		if actual := Templates(e, template).String(); actual != expected {
+			t.Errorf("%s != %s", actual, expected)
+		}
+
+		expected = "(|)map[Resources:(-)map[Bucket:map[Type:AWS::S3::Bucket]]]"
+		if actual := Templates(template, e).String(); actual != expected {
+			t.Errorf("%s != %s", actual, expected)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package diff
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
@@ -11,24 +12,188 @@ import (
 
 const indent = "  "
 
+// elementKind says whether an element represents a value, a slice, or a map
+type elementKind int
+
+const (
+	valueElement elementKind = iota
+	sliceElement
+	mapElement
+)
+
+// element is the representation of a Diff that both Format and FormatJSON
+// are built on, so that the text and JSON output always agree
+type element struct {
+	kind elementKind
+	mode Mode
+
+	// val is only set for values
+	val interface{}
+
+	// from is the old value of a TypeChanged value
+	from interface{}
+
+	// keys holds sorted map keys or slice indexes, matching children
+	keys     []interface{}
+	children []*element
+}
+
+// newElement converts a Diff to an element tree
+func newElement(d Diff) *element {
+	switch v := d.(type) {
+	case slice:
+		e := &element{kind: sliceElement, mode: v.Mode()}
+		for i, c := range v {
+			c, i := sliceIndex(c, i)
+			e.keys = append(e.keys, i)
+			e.children = append(e.children, newElement(c))
+		}
+		return e
+	case dmap:
+		e := &element{kind: mapElement, mode: v.Mode()}
+		keys := v.keys()
+		sort.Strings(keys)
+		for _, k := range keys {
+			e.keys = append(e.keys, k)
+			e.children = append(e.children, newElement(v[k]))
+		}
+		return e
+	case value:
+		return &element{kind: valueElement, mode: v.Mode(), val: v.Value(), from: v.from}
+	default:
+		panic(fmt.Errorf("unexpected type '%T'", d))
+	}
+}
+
+// modeName returns the name used for a mode in JSON output
+func modeName(m Mode) string {
+	switch m {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	case TypeChanged:
+		return "type-changed"
+	case Involved:
+		return "involved"
+	default:
+		return "unchanged"
+	}
+}
+
+// MarshalJSON writes values as {"mode": ..., "value": ...},
+// maps as {"mode": ..., "map": {...}}, and slices as {"mode": ..., "items": [...]}
+func (e *element) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{"mode": modeName(e.mode)}
+
+	switch e.kind {
+	case valueElement:
+		out["value"] = e.val
+		if e.mode == TypeChanged {
+			out["from"] = e.from
+			out["fromType"] = typeName(e.from)
+			out["type"] = typeName(e.val)
+		}
+	case sliceElement:
+		out["items"] = e.children
+	case mapElement:
+		m := make(map[string]*element)
+		for i, k := range e.keys {
+			m[k.(string)] = e.children[i]
+		}
+		out["map"] = m
+	}
+
+	return json.Marshal(out)
+}
+
 // Format returns a pretty-printed representation of the slice
 func (s slice) Format(long bool) string {
-	return formatSlice(s, []interface{}{}, long)
+	return formatElement(newElement(s), long, 0)
 }
 
 // Format returns a pretty-printed representation of the dmap
 func (m dmap) Format(long bool) string {
-	return formatMap(m, []interface{}{}, long)
+	return formatElement(newElement(m), long, 0)
 }
 
 // Format returns a pretty-printed representation of the value
 func (v value) Format(long bool) string {
+	return formatElement(newElement(v), long, 0)
+}
+
+// FormatCollapsed is like d.Format, but a map or slice in which at least
+// threshold of the values changed is shown as a single line, such as
+// "(>) VpcConfig: <entire block replaced>", instead of a line for each of
+// its values. threshold is a fraction between 0 and 1, so that 1 only
+// collapses blocks where every value changed. Blocks with a single value
+// are never collapsed, and nothing is collapsed if threshold is 0.
+func FormatCollapsed(d Diff, long bool, threshold float64) string {
+	return formatElement(newElement(d), long, threshold)
+}
+
+// collapsedText replaces the contents of a collapsed block
+const collapsedText = "<entire block replaced>"
+
+// leafCounts returns the number of values under e that changed
+// and the total number of values under e
+func (e *element) leafCounts() (changed int, total int) {
+	if e.kind == valueElement {
+		if e.mode != Unchanged {
+			return 1, 1
+		}
+		return 0, 1
+	}
+	for _, c := range e.children {
+		ch, t := c.leafCounts()
+		changed += ch
+		total += t
+	}
+	return changed, total
+}
+
+// collapses returns true if e is a block with enough changed values
+// to be shown as a single line
+func (e *element) collapses(threshold float64) bool {
+	if threshold <= 0 || e.kind == valueElement || e.mode != Involved {
+		return false
+	}
+	changed, total := e.leafCounts()
+	return total > 1 && float64(changed) >= threshold*float64(total)
+}
+
+// FormatJSON returns the slice as nested JSON with the mode of each element
+func (s slice) FormatJSON() string {
+	return formatJSON(s)
+}
+
+// FormatJSON returns the dmap as nested JSON with the mode of each element
+func (m dmap) FormatJSON() string {
+	return formatJSON(m)
+}
+
+// FormatJSON returns the value as JSON with its mode
+func (v value) FormatJSON() string {
+	return formatJSON(v)
+}
+
+func formatJSON(d Diff) string {
+	j, err := json.Marshal(newElement(d))
+	if err != nil {
+		panic(err)
+	}
+	return string(j)
+}
+
+func formatValue(e *element) string {
 	buf := strings.Builder{}
 
-	e := yaml.NewEncoder(&buf)
-	e.SetIndent(2)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
 
-	err := e.Encode(v.Value())
+	err := enc.Encode(e.val)
 	if err != nil {
 		panic(err)
 	}
@@ -36,8 +201,8 @@ func (v value) Format(long bool) string {
 	return strings.TrimSpace(buf.String())
 }
 
-func stubValue(v value) string {
-	switch v.Value().(type) {
+func stubValue(e *element) string {
+	switch e.val.(type) {
 	case map[string]interface{}:
 		return "{...}"
 	case []interface{}:
@@ -47,74 +212,50 @@ func stubValue(v value) string {
 	}
 }
 
-func formatDiff(d Diff, path []interface{}, long bool) string {
-	switch v := d.(type) {
-	case slice:
-		return formatSlice(v, path, long)
-	case dmap:
-		return formatMap(v, path, long)
-	case value:
-		return v.Format(long)
-	default:
-		panic(fmt.Errorf("unexpected type '%T'", d))
+func formatElement(e *element, long bool, collapse float64) string {
+	if e.kind == valueElement {
+		return formatValue(e)
 	}
-}
 
-func formatSlice(s slice, path []interface{}, long bool) string {
 	output := strings.Builder{}
 
-	for i, v := range s {
-		m := v.Mode()
+	for i, c := range e.children {
+		m := c.mode
 
 		if !long && m == Unchanged {
 			continue
 		}
 
-		output.WriteString(fmt.Sprintf("%s [%d]:", m, i))
-
-		if !long && (m == Removed || m == Unchanged) {
-			output.WriteString(" " + stubValue(v.(value)) + "\n")
-		} else {
-			output.WriteString(formatSub(v, append(path, i), long))
+		label := fmt.Sprint(e.keys[i])
+		if e.kind == sliceElement {
+			label = fmt.Sprintf("[%d]", e.keys[i])
 		}
-	}
-
-	return output.String()
-}
-
-func formatMap(m dmap, path []interface{}, long bool) string {
-	output := strings.Builder{}
-
-	keys := m.keys()
-	sort.Strings(keys)
-
-	for _, k := range keys {
-		v := m[k]
-		m := v.Mode()
-
-		if !long && m == Unchanged {
+		if m == TypeChanged {
+			label += fmt.Sprintf(" (%s → %s)", typeName(c.from), typeName(c.val))
+		}
+		if c.collapses(collapse) {
+			output.WriteString(fmt.Sprintf("%s %s: %s\n", Changed, label, collapsedText))
 			continue
 		}
-
-		output.WriteString(fmt.Sprintf("%s %s:", m, k))
+		output.WriteString(fmt.Sprintf("%s %s:", m, label))
 
 		if !long && (m == Removed || m == Unchanged) {
-			output.WriteString(" " + stubValue(v.(value)) + "\n")
+			output.WriteString(" " + stubValue(c) + "\n")
 		} else {
-			output.WriteString(formatSub(v, append(path, k), long))
+			output.WriteString(formatSub(c, long, collapse))
 		}
 	}
 
 	return output.String()
 }
 
-func formatSub(d Diff, path []interface{}, long bool) string {
+func formatSub(e *element, long bool, collapse float64) string {
 	// Format the element
-	formatted := formatDiff(d, path, long)
+	formatted := formatElement(e, long, collapse)
 
-	v, isValue := d.(value)
+	isValue := e.kind == valueElement
 	if isValue {
-		k := reflect.ValueOf(v.Value()).Kind()
+		k := reflect.ValueOf(e.val).Kind()
 
 		if k != reflect.Array && k != reflect.Map && k != reflect.Slice {
 			return fmt.Sprintf(" %s\n", formatted)
@@ -134,7 +275,7 @@ func formatSub(d Diff, path []interface{}, long bool) string {
 	output.WriteString("\n")
 	for _, part := range parts {
 		if isValue {
-			part = fmt.Sprintf("%s %s%s", v.Mode(), indent, part)
+			part = fmt.Sprintf("%s %s%s", e.mode, indent, part)
 		} else {
 			part = part[:len(Added.String())] + indent + part[len(Added.String()):]
 		}
@@ -121,3 +121,69 @@ func TestDiff(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatJSON(t *testing.T) {
+	d := CompareMaps(
+		map[string]interface{}{"a": "1", "b": []interface{}{"x"}, "c": "gone"},
+		map[string]interface{}{"a": "2", "b": []interface{}{"x", "y"}},
+	)
+
+	expected := `{"map":{"a":{"mode":"changed","value":"2"},` +
+		`"b":{"items":[{"mode":"unchanged","value":"x"},{"mode":"added","value":"y"}],"mode":"involved"},` +
+		`"c":{"mode":"removed","value":"gone"}},"mode":"involved"}`
+
+	if actual := d.FormatJSON(); actual != expected {
+		t.Errorf("%s\n!=\n%s\n", actual, expected)
+	}
+
+	if actual := compareValues("foo", "foo").FormatJSON(); actual != `{"mode":"unchanged","value":"foo"}` {
+		t.Errorf("unexpected value JSON: %s", actual)
+	}
+}
+
+func TestFormatTypeChanged(t *testing.T) {
+	d := CompareMaps(
+		map[string]interface{}{"A": "x", "B": "same"},
+		map[string]interface{}{"A": []interface{}{"x", "y"}, "B": "same"},
+	)
+
+	expected := "(~) A (string → list):\n(~)   - x\n(~)   - \"y\"\n"
+	if actual := d.Format(false); actual != expected {
+		t.Errorf("%q\n!=\n%q\n", actual, expected)
+	}
+
+	if s := d.Summary(); s.Changed != 1 || s.Changes[0].Mode != TypeChanged {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+}
+
+func TestFormatCollapsed(t *testing.T) {
+	d := CompareMaps(
+		map[string]interface{}{
+			"VpcConfig": map[string]interface{}{"SubnetId": "a", "VpcId": "b"},
+			"Tags":      map[string]interface{}{"Env": "dev", "Team": "x", "Owner": "me"},
+			"Name":      "old",
+		},
+		map[string]interface{}{
+			"VpcConfig": map[string]interface{}{"SubnetId": "c", "VpcId": "d"},
+			"Tags":      map[string]interface{}{"Env": "prod", "Team": "x", "Owner": "me"},
+			"Name":      "new",
+		},
+	)
+
+	// Only VpcConfig changed completely
+	expected := "(>) Name: new\n(|) Tags:\n(>)   Env: prod\n(>) VpcConfig: <entire block replaced>\n"
+	if actual := FormatCollapsed(d, false, 1); actual != expected {
+		t.Errorf("%q\n!=\n%q\n", actual, expected)
+	}
+
+	// A third of Tags changed
+	expected = "(>) Name: new\n(>) Tags: <entire block replaced>\n(>) VpcConfig: <entire block replaced>\n"
+	if actual := FormatCollapsed(d, false, 0.3); actual != expected {
+		t.Errorf("%q\n!=\n%q\n", actual, expected)
+	}
+
+	if FormatCollapsed(d, true, 0) != d.Format(true) {
+		t.Errorf("expected a threshold of 0 not to collapse anything")
+	}
+}
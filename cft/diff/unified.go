@@ -0,0 +1,200 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Unified returns the difference between old and new in the style of diff -u.
+// Both maps are written out as YAML and compared line by line. Changed lines
+// are prefixed with - or +, and each group of changes is shown with up to
+// context lines of unchanged YAML around it, under an @@ hunk header.
+// Unified returns an empty string if there are no differences.
+func Unified(old, new map[string]interface{}, context int) string {
+	a := yamlLines(old)
+	b := yamlLines(new)
+	ops := diffLines(a, b)
+
+	if context < 0 {
+		context = 0
+	}
+
+	output := strings.Builder{}
+
+	i := 0
+	for i < len(ops) {
+		// Find the next change
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk until there is a gap of more than 2*context unchanged lines
+		start := max(i-context, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			gap := end
+			for gap < len(ops) && ops[gap].kind == ' ' {
+				gap++
+			}
+			if gap == len(ops) || gap-end > 2*context {
+				end = min(end+context, len(ops))
+				break
+			}
+			end = gap
+		}
+
+		writeHunk(&output, ops[start:end])
+		i = end
+	}
+
+	return output.String()
+}
+
+// UnifiedDiff is like Unified for the maps that d was made from. Values that
+// d treats as unchanged, like ignored paths or lists matched by key in another
+// order, are written the same way on both sides, so that the only - and + lines
+// are for the values that d reports as changed.
+func UnifiedDiff(d Diff, context int) string {
+	old, _, new, _ := sides(d)
+	oldMap, _ := old.(map[string]interface{})
+	newMap, _ := new.(map[string]interface{})
+	return Unified(oldMap, newMap, context)
+}
+
+// sides returns the old and new values that d was made from, and whether
+// the value was there on each side. An unchanged value is the same on both.
+func sides(d Diff) (old interface{}, inOld bool, new interface{}, inNew bool) {
+	switch v := d.(type) {
+	case dmap:
+		o, n := make(map[string]interface{}), make(map[string]interface{})
+		for k, e := range v {
+			ov, io, nv, in := sides(e)
+			if io {
+				o[k] = ov
+			}
+			if in {
+				n[k] = nv
+			}
+		}
+		return o, true, n, true
+	case slice:
+		o, n := make([]interface{}, 0), make([]interface{}, 0)
+		for i, e := range v {
+			e, _ := sliceIndex(e, i)
+			ov, io, nv, in := sides(e)
+			if io {
+				o = append(o, ov)
+			}
+			if in {
+				n = append(n, nv)
+			}
+		}
+		return o, true, n, true
+	case value:
+		switch v.mode {
+		case Added:
+			return nil, false, v.val, true
+		case Removed:
+			return v.val, true, nil, false
+		case Changed, TypeChanged:
+			return v.from, true, v.val, true
+		}
+		return v.val, true, v.val, true
+	}
+	return nil, false, nil, false
+}
+
+// lineOp is a line of a unified diff: ' ' for context, '-' for old, '+' for new.
+// oldLine and newLine are the 1-based line numbers the op starts at in each side.
+type lineOp struct {
+	kind    byte
+	text    string
+	oldLine int
+	newLine int
+}
+
+func writeHunk(output *strings.Builder, ops []lineOp) {
+	oldCount, newCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+
+	oldStart, newStart := ops[0].oldLine, ops[0].newLine
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+
+	output.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount))
+	for _, op := range ops {
+		output.WriteString(fmt.Sprintf("%c%s\n", op.kind, op.text))
+	}
+}
+
+// yamlLines returns m written out as YAML, one line per element
+func yamlLines(m map[string]interface{}) []string {
+	if len(m) == 0 {
+		return []string{}
+	}
+
+	buf := strings.Builder{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(m); err != nil {
+		panic(err)
+	}
+
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+}
+
+// diffLines returns the edit script that turns a into b,
+// based on their longest common subsequence of lines
+func diffLines(a, b []string) []lineOp {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]lineOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, lineOp{' ', a[i], i + 1, j + 1})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, lineOp{'+', b[j], i + 1, j + 1})
+			j++
+		default:
+			ops = append(ops, lineOp{'-', a[i], i + 1, j + 1})
+			i++
+		}
+	}
+
+	return ops
+}
@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	old := map[string]interface{}{
+		"A": 1,
+		"B": 2,
+		"C": 3,
+		"D": 4,
+		"E": 5,
+		"F": 6,
+		"G": 7,
+	}
+	new := map[string]interface{}{
+		"A": 1,
+		"B": 2,
+		"C": 3,
+		"D": 40,
+		"E": 5,
+		"F": 6,
+		"G": 7,
+		"H": 8,
+	}
+
+	cases := []struct {
+		context  int
+		expected string
+	}{
+		{0, "@@ -4,1 +4,1 @@\n-D: 4\n+D: 40\n@@ -7,0 +8,1 @@\n+H: 8\n"},
+		{1, "@@ -3,3 +3,3 @@\n C: 3\n-D: 4\n+D: 40\n E: 5\n@@ -7,1 +7,2 @@\n G: 7\n+H: 8\n"},
+		{3, "@@ -1,7 +1,8 @@\n A: 1\n B: 2\n C: 3\n-D: 4\n+D: 40\n E: 5\n F: 6\n G: 7\n+H: 8\n"},
+	}
+
+	for _, c := range cases {
+		actual := Unified(old, new, c.context)
+		if actual != c.expected {
+			t.Errorf("context %d:\n%s\n!=\n%s\n", c.context, actual, c.expected)
+		}
+	}
+
+	if actual := Unified(old, old, 3); actual != "" {
+		t.Errorf("expected no output for equal maps, got:\n%s", actual)
+	}
+
+	expected := "@@ -0,0 +1,1 @@\n+A: 1\n"
+	if actual := Unified(map[string]interface{}{}, map[string]interface{}{"A": 1}, 3); actual != expected {
+		t.Errorf("%#v\n!=\n%#v\n", actual, expected)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tag := func(k, v string) interface{} {
+		return map[string]interface{}{"Key": k, "Value": v}
+	}
+	old := map[string]interface{}{
+		"Name":  "a",
+		"Time":  "1",
+		"Count": 1,
+		"Tags":  []interface{}{tag("x", "1"), tag("y", "2")},
+	}
+	new := map[string]interface{}{
+		"Name":  "b",
+		"Time":  "2",
+		"Count": "1",
+		"Tags":  []interface{}{tag("y", "2"), tag("x", "1")},
+	}
+
+	// Only the change that counts is shown, not the ignored time,
+	// the number stored as a string, or the reordered tags
+	d := CompareMaps(old, new, Ignore("Time"), IdentityKeys(map[string]string{"Tags": "Key"}))
+	expected := "@@ -2,1 +2,1 @@\n-Name: a\n+Name: b\n"
+	if actual := UnifiedDiff(d, 0); actual != expected {
+		t.Errorf("%#v\n!=\n%#v\n", actual, expected)
+	}
+
+	if actual := UnifiedDiff(CompareMaps(old, old), 3); actual != "" {
+		t.Errorf("expected no output for equal maps, got:\n%s", actual)
+	}
+}
@@ -0,0 +1,95 @@
+package cft
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// MergePatch applies an RFC 7386 JSON merge patch to the map at path.
+// A nil value in patch removes the key, a map is merged into the existing
+// value recursively, and any other value replaces it. The path uses the
+// same syntax as s11n.MatchAll and must match exactly one node, or be
+// empty to patch the whole template. Keys that are added are appended in
+// sorted order, and the comments on replaced values are kept.
+func (t Template) MergePatch(path string, patch map[string]interface{}) error {
+	if t.Node == nil {
+		return fmt.Errorf("unable to patch %s because t.Node is nil", path)
+	}
+
+	target := t.Node
+	if path == "" {
+		if target.Kind == yaml.DocumentNode && len(target.Content) > 0 {
+			target = target.Content[0]
+		}
+	} else {
+		matches := t.MatchPathAll(path)
+		if len(matches) != 1 {
+			return fmt.Errorf("path %s matches %d nodes, expected 1", path, len(matches))
+		}
+		target = matches[0]
+	}
+
+	return mergePatch(target, patch)
+}
+
+// mergePatch applies patch to n in place.
+// If n is not a map, it becomes an empty map first, as the RFC requires.
+func mergePatch(n *yaml.Node, patch map[string]interface{}) error {
+	if n.Kind != yaml.MappingNode {
+		n.Kind = yaml.MappingNode
+		n.Tag = "!!map"
+		n.Style = 0
+		n.Value = ""
+		n.Content = make([]*yaml.Node, 0)
+	}
+
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		v := patch[k]
+
+		if v == nil {
+			if err := s11n.DeleteMapValue(n, k); err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, existing, _ := s11n.GetMapValue(n, k)
+
+		if m, ok := v.(map[string]interface{}); ok {
+			if existing == nil {
+				existing = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				if err := s11n.SetMapValue(n, k, existing); err != nil {
+					return err
+				}
+			}
+			if err := mergePatch(existing, m); err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+			continue
+		}
+
+		value := &yaml.Node{}
+		if err := value.Encode(v); err != nil {
+			return fmt.Errorf("%s: %v", k, err)
+		}
+		if existing != nil {
+			value.HeadComment = existing.HeadComment
+			value.LineComment = existing.LineComment
+			value.FootComment = existing.FootComment
+		}
+		if err := s11n.SetMapValue(n, k, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package cft_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+)
+
+func TestMergePatch(t *testing.T) {
+	tpl, err := parse.String(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: foo # keep me
+      Versioning:
+        Status: Enabled
+        Extra: 1
+      Old: gone
+      Scalar: x
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tpl.MergePatch("Resources/Bucket/Properties", map[string]interface{}{
+		"BucketName": "bar",
+		"Versioning": map[string]interface{}{"Extra": nil, "MFADelete": "Disabled"},
+		"Old":        nil,
+		"Scalar":     map[string]interface{}{"Now": "a map"},
+		"Tags":       []interface{}{map[string]interface{}{"Key": "k", "Value": "v"}},
+		"Missing":    nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: bar # keep me
+      Versioning:
+        Status: Enabled
+        MFADelete: Disabled
+      Scalar:
+        Now: a map
+      Tags:
+        - Key: k
+          Value: v
+`
+
+	actual, err := tpl.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(actual) != strings.TrimSpace(expected) {
+		t.Errorf("%s\n!=\n%s\n", actual, expected)
+	}
+
+	if err := tpl.MergePatch("Resources/Nothing", map[string]interface{}{"A": "b"}); err == nil {
+		t.Errorf("expected an error for a path that does not match")
+	}
+}
@@ -3,9 +3,11 @@
 package parse
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 
 	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/cft/diff"
@@ -33,6 +35,44 @@ func File(fileName string) (cft.Template, error) {
 	return String(string(source))
 }
 
+// Documents returns a cft.Template for each document in a multi-document
+// YAML stream, where documents are separated by ---
+func Documents(r io.Reader) ([]cft.Template, error) {
+	retval := make([]cft.Template, 0)
+
+	dec := yaml.NewDecoder(r)
+	for i := 0; ; i++ {
+		var n yaml.Node
+		err := dec.Decode(&n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML in document %d: %s", i, err)
+		}
+
+		t, err := Node(&n)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse document %d: %s", i, err)
+		}
+		retval = append(retval, t)
+	}
+
+	return retval, nil
+}
+
+// FileDocuments returns a cft.Template for each document in
+// the multi-document YAML file specified by fileName
+func FileDocuments(fileName string) ([]cft.Template, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %s", err)
+	}
+	defer f.Close()
+
+	return Documents(f)
+}
+
 // Map returns a cft.Template parsed from a map[string]interface{}
 func Map(input map[string]interface{}) (cft.Template, error) {
 	var node yaml.Node
@@ -44,6 +84,52 @@ func Map(input map[string]interface{}) (cft.Template, error) {
 	return Node(&node)
 }
 
+// OrderedMap is like Map, but keys that appear in order are placed first,
+// in that order, in every mapping node of the template. Other keys keep
+// the sorted order that Map produces. For example, an order of
+// "Parameters", "Resources", "Type", "Properties" puts Parameters before
+// Resources and Type before Properties in each resource.
+// The template's root is a document node, as it is for parsed strings,
+// so the Template methods can be used on the result.
+func OrderedMap(input map[string]interface{}, order []string) (cft.Template, error) {
+	var node yaml.Node
+	err := node.Encode(input)
+	if err != nil {
+		return cft.Template{}, err
+	}
+
+	orderKeys(&node, order)
+
+	return Node(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&node}})
+}
+
+// orderKeys sorts the keys of every mapping node in n by their position in order
+func orderKeys(n *yaml.Node, order []string) {
+	if n.Kind == yaml.MappingNode {
+		pairs := make([][2]*yaml.Node, 0, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			pairs = append(pairs, [2]*yaml.Node{n.Content[i], n.Content[i+1]})
+		}
+		rank := func(key string) int {
+			if i := slices.Index(order, key); i >= 0 {
+				return i
+			}
+			return len(order)
+		}
+		slices.SortStableFunc(pairs, func(a, b [2]*yaml.Node) int {
+			return rank(a[0].Value) - rank(b[0].Value)
+		})
+		for i, pair := range pairs {
+			n.Content[i*2] = pair[0]
+			n.Content[i*2+1] = pair[1]
+		}
+	}
+
+	for _, child := range n.Content {
+		orderKeys(child, order)
+	}
+}
+
 // String returns a cft.Template parsed from a string
 func String(input string) (cft.Template, error) {
 	var n yaml.Node
@@ -55,6 +141,75 @@ func String(input string) (cft.Template, error) {
 	return Node(&n)
 }
 
+// ErrDuplicateKey is returned by StringStrict for a mapping that has the same key twice
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// StringStrict is like String, but it rejects a template that has the same
+// key twice in a mapping, which CloudFormation forbids. String keeps the
+// last value of a duplicate key, which can hide a copy-paste mistake.
+// Each duplicate is reported with its line and the line of the first one.
+func StringStrict(input string) (cft.Template, error) {
+	var n yaml.Node
+	err := yaml.Unmarshal([]byte(input), &n)
+	if err != nil {
+		return cft.Template{}, fmt.Errorf("invalid YAML: %s", err)
+	}
+
+	if err := DuplicateKeys(&n); err != nil {
+		return cft.Template{}, err
+	}
+
+	return Node(&n)
+}
+
+// ReaderStrict is like StringStrict, but it decodes the template as it is
+// read from r, so that a large file, like the state file of a deployment
+// with thousands of resources, is not also held in memory as text.
+// Only the first document is read.
+func ReaderStrict(r io.Reader) (cft.Template, error) {
+	var n yaml.Node
+	err := yaml.NewDecoder(r).Decode(&n)
+	if err != nil && err != io.EOF {
+		return cft.Template{}, fmt.Errorf("invalid YAML: %s", err)
+	}
+
+	if err := DuplicateKeys(&n); err != nil {
+		return cft.Template{}, err
+	}
+
+	return Node(&n)
+}
+
+// DuplicateKeys returns an error for each mapping in n that has the same key
+// more than once, or nil if there are none. YAML merge keys (<<) are ignored.
+func DuplicateKeys(n *yaml.Node) error {
+	errs := make([]error, 0)
+	duplicateKeys(n, &errs)
+	return errors.Join(errs...)
+}
+
+func duplicateKeys(n *yaml.Node, errs *[]error) {
+	if n.Kind == yaml.MappingNode {
+		lines := make(map[string]int)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			if key.Value == "<<" {
+				continue
+			}
+			if first, ok := lines[key.Value]; ok {
+				*errs = append(*errs, fmt.Errorf("%w '%s' on line %d, first defined on line %d",
+					ErrDuplicateKey, key.Value, key.Line, first))
+				continue
+			}
+			lines[key.Value] = key.Line
+		}
+	}
+
+	for _, child := range n.Content {
+		duplicateKeys(child, errs)
+	}
+}
+
 // Node returns a cft.Template parse from a *yaml.Node
 func Node(n *yaml.Node) (cft.Template, error) {
 	err := NormalizeNode(n)
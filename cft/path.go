@@ -0,0 +1,224 @@
+package cft
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// SetPath replaces the node at path with value.
+// The path uses the same syntax as s11n.MatchAll and must match exactly one node.
+// If nothing matches but the parent of the last path element is a map,
+// the last element is added to it as a new key.
+func (t Template) SetPath(path string, value *yaml.Node) error {
+	if t.Node == nil {
+		return fmt.Errorf("unable to set %s because t.Node is nil", path)
+	}
+
+	matches := t.MatchPathAll(path)
+
+	switch len(matches) {
+	case 1:
+		return replaceNode(t.Node, matches[0], value)
+	case 0:
+		return t.addPath(path, value)
+	default:
+		return fmt.Errorf("path %s matches %d nodes, expected 1", path, len(matches))
+	}
+}
+
+// MatchPathAll returns every node that matches path, in document order.
+// The path uses the same syntax as s11n.MatchAll.
+// An empty slice is returned if nothing matches.
+func (t Template) MatchPathAll(path string) []*yaml.Node {
+	matches := make([]*yaml.Node, 0)
+	if t.Node == nil {
+		return matches
+	}
+
+	for n := range s11n.MatchAll(t.Node, path) {
+		matches = append(matches, n)
+	}
+
+	return matches
+}
+
+// PathMatch is a node that matched a path with wildcards,
+// along with the concrete path to it, e.g. Resources/Bucket
+type PathMatch struct {
+	Path string
+	Node *yaml.Node
+}
+
+// MatchPathWithPath is like MatchPathAll, but also returns the concrete path
+// to each match, so that callers can tell which element matched a wildcard,
+// such as which resource matched Resources/*. The paths can be passed to
+// SetPath and RemovePath.
+func (t Template) MatchPathWithPath(path string) []PathMatch {
+	matches := make([]PathMatch, 0)
+	if t.Node == nil {
+		return matches
+	}
+
+	for m := range s11n.MatchAllWithPath(t.Node, path) {
+		matches = append(matches, PathMatch{Path: m.Path, Node: m.Node})
+	}
+
+	return matches
+}
+
+// replaceNode replaces target with value in the Content of target's parent
+func replaceNode(root *yaml.Node, target *yaml.Node, value *yaml.Node) error {
+	parent := node.GetParent(target, root, nil).Value
+	if parent == nil || parent == target {
+		return fmt.Errorf("unable to find the parent node")
+	}
+
+	for i, n := range parent.Content {
+		// Skip map keys so that a key that happens to be the target is not replaced
+		if parent.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+		if n == target {
+			parent.Content[i] = value
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unable to find the node in its parent")
+}
+
+// RemovePath removes the node at path from its parent. For a map entry,
+// both the key and the value are removed; for a sequence, the element is removed.
+// The path uses the same syntax as s11n.MatchAll and must match exactly one node.
+func (t Template) RemovePath(path string) error {
+	if t.Node == nil {
+		return fmt.Errorf("unable to remove %s because t.Node is nil", path)
+	}
+
+	matches := t.MatchPathAll(path)
+	if len(matches) != 1 {
+		return fmt.Errorf("path %s matches %d nodes, expected 1", path, len(matches))
+	}
+	target := matches[0]
+
+	parent := node.GetParent(target, t.Node, nil).Value
+	if parent == nil || parent == target {
+		return fmt.Errorf("unable to find the parent node of %s", path)
+	}
+
+	switch parent.Kind {
+	case yaml.MappingNode:
+		for i := 1; i < len(parent.Content); i += 2 {
+			if parent.Content[i] == target {
+				parent.Content = append(parent.Content[:i-1], parent.Content[i+1:]...)
+				return nil
+			}
+		}
+	case yaml.SequenceNode:
+		for i, n := range parent.Content {
+			if n == target {
+				parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("unable to remove %s from its parent", path)
+}
+
+// addPath adds a new key to the map that is the parent of the last element in path
+func (t Template) addPath(path string, value *yaml.Node) error {
+	parentPath, key := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		parentPath, key = path[:i], path[i+1:]
+	}
+
+	if key == "" || key == "*" || key == "**" || strings.Contains(key, "|") {
+		return fmt.Errorf("path %s does not match any nodes", path)
+	}
+	if _, err := strconv.Atoi(key); err == nil {
+		return fmt.Errorf("path %s does not match any nodes", path)
+	}
+
+	var parent *yaml.Node
+	if parentPath == "" {
+		parent = t.Node
+		if parent.Kind == yaml.DocumentNode && len(parent.Content) > 0 {
+			parent = parent.Content[0]
+		}
+	} else {
+		parent = s11n.MatchOne(t.Node, parentPath)
+		if parent == nil {
+			return fmt.Errorf("path %s does not match exactly one node", parentPath)
+		}
+	}
+
+	if parent.Kind != yaml.MappingNode {
+		return fmt.Errorf("unable to add %s because %s is not a map", key, parentPath)
+	}
+
+	parent.Content = append(parent.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+
+	return nil
+}
+
+// Walk calls fn for every node in the template, depth-first and in document order.
+// The path passed to fn is /-separated map keys and sequence indices,
+// using the same syntax as s11n.MatchAll, e.g. Resources/Bucket/Properties/Tags/0.
+// The root node itself is not passed to fn.
+// If fn returns an error, the walk stops and the error is returned.
+func (t Template) Walk(fn func(path string, node *yaml.Node) error) error {
+	if t.Node == nil {
+		return nil
+	}
+
+	root := t.Node
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return nil
+		}
+		root = root.Content[0]
+	}
+
+	return walk(root, "", fn)
+}
+
+func walk(n *yaml.Node, path string, fn func(string, *yaml.Node) error) error {
+	join := func(elem string) string {
+		if path == "" {
+			return elem
+		}
+		return path + "/" + elem
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			p := join(n.Content[i].Value)
+			if err := fn(p, n.Content[i+1]); err != nil {
+				return err
+			}
+			if err := walk(n.Content[i+1], p, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, child := range n.Content {
+			p := join(strconv.Itoa(i))
+			if err := fn(p, child); err != nil {
+				return err
+			}
+			if err := walk(child, p, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
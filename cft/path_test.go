@@ -0,0 +1,253 @@
+package cft_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+var pathTestTemplate = `
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: foo
+  Queue:
+    Type: AWS::SQS::Queue
+    Properties:
+      Tags:
+        - first
+        - second
+`
+
+func TestSetPath(t *testing.T) {
+	tpl, err := parse.String(pathTestTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scalar := func(v string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	}
+
+	// Replace an existing value
+	if err := tpl.SetPath("Resources/Bucket/Properties/BucketName", scalar("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if v := s11n.MatchOne(tpl.Node, "Resources/Bucket/Properties/BucketName"); v == nil || v.Value != "bar" {
+		t.Errorf("expected BucketName to be bar")
+	}
+
+	// Replace a sequence element, using a query to select the resource
+	if err := tpl.SetPath("Resources/*|Type==AWS::SQS::Queue/Properties/Tags/1", scalar("third")); err != nil {
+		t.Fatal(err)
+	}
+	if v := s11n.MatchOne(tpl.Node, "Resources/Queue/Properties/Tags/1"); v == nil || v.Value != "third" {
+		t.Errorf("expected Tags/1 to be third")
+	}
+
+	// Add a new key to an existing map
+	if err := tpl.SetPath("Resources/Bucket/Properties/Versioning", scalar("on")); err != nil {
+		t.Fatal(err)
+	}
+	if v := s11n.MatchOne(tpl.Node, "Resources/Bucket/Properties/Versioning"); v == nil || v.Value != "on" {
+		t.Errorf("expected Versioning to be added")
+	}
+
+	// More than one match
+	if err := tpl.SetPath("Resources/*/Type", scalar("x")); err == nil {
+		t.Errorf("expected an error when more than one node matches")
+	}
+
+	// Missing parent
+	if err := tpl.SetPath("Resources/Missing/Properties/Foo", scalar("x")); err == nil {
+		t.Errorf("expected an error when the parent does not exist")
+	}
+
+	// Parent is not a map
+	if err := tpl.SetPath("Resources/Queue/Properties/Tags/Foo", scalar("x")); err == nil {
+		t.Errorf("expected an error when the parent is not a map")
+	}
+}
+
+func TestMatchPathAll(t *testing.T) {
+	tpl, err := parse.String(pathTestTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := func(nodes []*yaml.Node) []string {
+		retval := make([]string, 0)
+		for _, n := range nodes {
+			retval = append(retval, n.Value)
+		}
+		return retval
+	}
+
+	cases := map[string][]string{
+		"Resources/*/Type":              {"AWS::S3::Bucket", "AWS::SQS::Queue"},
+		"Resources/[Queue,Bucket]/Type": {"AWS::S3::Bucket", "AWS::SQS::Queue"},
+		"**/Tags/*":                     {"first", "second"},
+		"Resources/Missing":             {},
+	}
+
+	for path, expected := range cases {
+		actual := values(tpl.MatchPathAll(path))
+		if !slices.Equal(actual, expected) {
+			t.Errorf("%s: %#v\n!=\n%#v\n", path, actual, expected)
+		}
+	}
+}
+
+func TestMatchPathWithPath(t *testing.T) {
+	tpl, err := parse.String(pathTestTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := make([]string, 0)
+	for _, m := range tpl.MatchPathWithPath("Resources/*|Properties") {
+		paths = append(paths, m.Path)
+	}
+	expected := []string{"Resources/Bucket", "Resources/Queue"}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("%#v\n!=\n%#v\n", paths, expected)
+	}
+
+	matches := tpl.MatchPathWithPath("**/Tags/1")
+	if len(matches) != 1 || matches[0].Path != "Resources/Queue/Properties/Tags/1" || matches[0].Node.Value != "second" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
+
+func TestRemovePath(t *testing.T) {
+	tpl, err := parse.String(pathTestTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove a map entry
+	if err := tpl.RemovePath("Resources/Bucket/Properties/BucketName"); err != nil {
+		t.Fatal(err)
+	}
+	props := s11n.MatchOne(tpl.Node, "Resources/Bucket/Properties")
+	if props == nil || len(props.Content) != 0 {
+		t.Errorf("expected BucketName to be removed")
+	}
+
+	// Remove a sequence element
+	if err := tpl.RemovePath("Resources/Queue/Properties/Tags/0"); err != nil {
+		t.Fatal(err)
+	}
+	tags := s11n.MatchOne(tpl.Node, "Resources/Queue/Properties/Tags")
+	if tags == nil || len(tags.Content) != 1 || tags.Content[0].Value != "second" {
+		t.Errorf("expected only the second tag to be left")
+	}
+
+	// More than one match
+	if err := tpl.RemovePath("Resources/*/Type"); err == nil {
+		t.Errorf("expected an error when more than one node matches")
+	}
+
+	// No match
+	if err := tpl.RemovePath("Resources/Missing"); err == nil {
+		t.Errorf("expected an error when nothing matches")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	tpl, err := parse.String(pathTestTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := make([]string, 0)
+	err = tpl.Walk(func(path string, n *yaml.Node) error {
+		paths = append(paths, path)
+
+		// Every path should be usable with MatchOne
+		if s11n.MatchOne(tpl.Node, path) != n {
+			t.Errorf("path %s does not match its node", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"Resources",
+		"Resources/Bucket",
+		"Resources/Bucket/Type",
+		"Resources/Bucket/Properties",
+		"Resources/Bucket/Properties/BucketName",
+		"Resources/Queue",
+		"Resources/Queue/Type",
+		"Resources/Queue/Properties",
+		"Resources/Queue/Properties/Tags",
+		"Resources/Queue/Properties/Tags/0",
+		"Resources/Queue/Properties/Tags/1",
+	}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("%v\n!=\n%v", paths, expected)
+	}
+
+	// Returning an error stops the walk
+	stop := errors.New("stop")
+	count := 0
+	err = tpl.Walk(func(path string, n *yaml.Node) error {
+		count++
+		if path == "Resources/Bucket/Type" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 3 {
+		t.Errorf("expected the walk to stop after 3 nodes, got %d (%v)", count, err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	template, err := parse.String(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: &name foo # the name
+      Tags:
+        - Key: Name
+          Value: *name
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := template.Clone()
+
+	if err := clone.SetPath("Resources/Bucket/Properties/BucketName", &yaml.Node{Kind: yaml.ScalarNode, Value: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	original := s11n.MatchOne(template.Node, "Resources/Bucket/Properties/BucketName")
+	if original == nil || original.Value != "foo" || original.LineComment != "# the name" {
+		t.Errorf("expected the original to be unchanged, got %#v", original)
+	}
+
+	changed := s11n.MatchOne(clone.Node, "Resources/Bucket/Properties/BucketName")
+	if changed == nil || changed.Value != "bar" {
+		t.Errorf("expected the clone to be changed, got %#v", changed)
+	}
+
+	// Aliases in the clone point into the clone
+	alias := s11n.MatchOne(clone.Node, "Resources/Bucket/Properties/Tags/0/Value")
+	if alias == nil || alias.Kind != yaml.AliasNode {
+		t.Fatalf("expected an alias node, got %#v", alias)
+	}
+	if alias.Alias == s11n.MatchOne(template.Node, "Resources/Bucket/Properties/BucketName") {
+		t.Errorf("expected the clone's alias not to point into the original")
+	}
+}
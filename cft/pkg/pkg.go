@@ -25,6 +25,7 @@
 package pkg
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"errors"
@@ -83,17 +84,21 @@ func transform(ctx *transformContext) (bool, error) {
 
 	// registry is a map of functions defined in rain.go
 	for path, fn := range registry {
-		for found := range s11n.MatchAll(ctx.nodeToTransform, path) {
+		// Stop the search if a directive fails, so that the goroutine does not leak
+		matchCtx, cancel := context.WithCancel(context.Background())
+		for found := range s11n.MatchAllContext(matchCtx, ctx.nodeToTransform, path) {
 			nodeParent := node.GetParent(found, ctx.nodeToTransform, nil)
 			nodeParent.Parent = ctx.parent
 			c, err := fn(&directiveContext{found, ctx.rootDir, ctx.t, nodeParent, ctx.fs, ctx.baseUri})
 			if err != nil {
+				cancel()
 				config.Debugf("Error packaging template: %s\n", err)
 				return false, err
 			}
 
 			changed = changed || c
 		}
+		cancel()
 	}
 
 	return changed, nil
@@ -0,0 +1,152 @@
+package cft
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PseudoParameters holds the values of the CloudFormation pseudo parameters,
+// like AWS::Region, that a value can refer to. Empty fields are unknown,
+// and references to them are left as they are.
+type PseudoParameters struct {
+	AccountId string
+	Region    string
+	Partition string
+	URLSuffix string
+	StackName string
+	StackId   string
+}
+
+// NewPseudoParameters returns the pseudo parameters for an account and
+// region, along with the Partition and URLSuffix of the region
+func NewPseudoParameters(accountId string, region string) PseudoParameters {
+	p := PseudoParameters{AccountId: accountId, Region: region}
+	if region == "" {
+		return p
+	}
+
+	switch {
+	case strings.HasPrefix(region, "us-gov"):
+		p.Partition = "aws-us-gov"
+	case strings.HasPrefix(region, "cn-"):
+		p.Partition = "aws-cn"
+	case strings.HasPrefix(region, "us-isob-"):
+		p.Partition = "aws-iso-b"
+	case strings.HasPrefix(region, "us-iso-"):
+		p.Partition = "aws-iso"
+	default:
+		p.Partition = "aws"
+	}
+
+	switch p.Partition {
+	case "aws-cn":
+		p.URLSuffix = "amazonaws.com.cn"
+	case "aws-iso":
+		p.URLSuffix = "c2s.ic.gov"
+	case "aws-iso-b":
+		p.URLSuffix = "sc2s.sgov.gov"
+	default:
+		p.URLSuffix = "amazonaws.com"
+	}
+
+	return p
+}
+
+// Get returns the value of a pseudo parameter like AWS::Region,
+// and false if it is not a pseudo parameter or its value is unknown
+func (p PseudoParameters) Get(name string) (string, bool) {
+	var v string
+	switch name {
+	case "AWS::AccountId":
+		v = p.AccountId
+	case "AWS::Region":
+		v = p.Region
+	case "AWS::Partition":
+		v = p.Partition
+	case "AWS::URLSuffix":
+		v = p.URLSuffix
+	case "AWS::StackName":
+		v = p.StackName
+	case "AWS::StackId":
+		v = p.StackId
+	}
+	return v, v != ""
+}
+
+// subVariable matches the ${Name} variables in an Fn::Sub string, but not
+// the ${!Literal} escapes
+var subVariable = regexp.MustCompile(`\$\{([^!}][^}]*)\}`)
+
+// ResolvePseudoParameters returns a copy of v, a value decoded from a
+// template or model, where each {Ref: AWS::Region} and each ${AWS::Region}
+// in an Fn::Sub is replaced with the value in p, so that it can be compared
+// to a value that CloudFormation or Cloud Control API has already resolved.
+// An Fn::Sub that is left without variables becomes a plain string.
+// References to unknown values, to parameters, and to resources are kept.
+// Short form tags must already have been converted, as Template.Map does.
+func ResolvePseudoParameters(v interface{}, p PseudoParameters) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(t) == 1 {
+			if ref, ok := t["Ref"].(string); ok {
+				if value, ok := p.Get(ref); ok {
+					return value
+				}
+			}
+			if sub, ok := t["Fn::Sub"]; ok {
+				return resolveSub(sub, p)
+			}
+		}
+		retval := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			retval[k] = ResolvePseudoParameters(e, p)
+		}
+		return retval
+	case []interface{}:
+		retval := make([]interface{}, len(t))
+		for i, e := range t {
+			retval[i] = ResolvePseudoParameters(e, p)
+		}
+		return retval
+	default:
+		return v
+	}
+}
+
+// resolveSub replaces the pseudo parameters in the string of an Fn::Sub,
+// which is either a string or a list of a string and a map of variables
+func resolveSub(sub interface{}, p PseudoParameters) interface{} {
+	var s string
+	var vars interface{}
+	switch t := sub.(type) {
+	case string:
+		s = t
+	case []interface{}:
+		if len(t) != 2 {
+			return map[string]interface{}{"Fn::Sub": sub}
+		}
+		var ok bool
+		if s, ok = t[0].(string); !ok {
+			return map[string]interface{}{"Fn::Sub": ResolvePseudoParameters(sub, p)}
+		}
+		vars = ResolvePseudoParameters(t[1], p)
+	default:
+		return map[string]interface{}{"Fn::Sub": sub}
+	}
+
+	s = subVariable.ReplaceAllStringFunc(s, func(match string) string {
+		if value, ok := p.Get(match[2 : len(match)-1]); ok {
+			return value
+		}
+		return match
+	})
+
+	if subVariable.MatchString(s) {
+		if vars != nil {
+			return map[string]interface{}{"Fn::Sub": []interface{}{s, vars}}
+		}
+		return map[string]interface{}{"Fn::Sub": s}
+	}
+
+	return strings.ReplaceAll(s, "${!", "${")
+}
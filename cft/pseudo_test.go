@@ -0,0 +1,78 @@
+package cft_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft"
+)
+
+func TestNewPseudoParameters(t *testing.T) {
+	cases := []struct {
+		region    string
+		partition string
+		urlSuffix string
+	}{
+		{"us-east-1", "aws", "amazonaws.com"},
+		{"us-gov-west-1", "aws-us-gov", "amazonaws.com"},
+		{"cn-north-1", "aws-cn", "amazonaws.com.cn"},
+		{"us-iso-east-1", "aws-iso", "c2s.ic.gov"},
+		{"us-isob-east-1", "aws-iso-b", "sc2s.sgov.gov"},
+		{"", "", ""},
+	}
+
+	for _, c := range cases {
+		p := cft.NewPseudoParameters("123456789012", c.region)
+		if p.Partition != c.partition || p.URLSuffix != c.urlSuffix {
+			t.Errorf("%s: %s %s", c.region, p.Partition, p.URLSuffix)
+		}
+	}
+}
+
+func TestResolvePseudoParameters(t *testing.T) {
+	p := cft.NewPseudoParameters("123456789012", "us-east-1")
+
+	model := map[string]interface{}{
+		"Region": map[string]interface{}{"Ref": "AWS::Region"},
+		"Arn": map[string]interface{}{
+			"Fn::Sub": "arn:${AWS::Partition}:s3:::logs-${AWS::AccountId}",
+		},
+		"Escaped": map[string]interface{}{
+			"Fn::Sub": "${!Literal}-${AWS::Region}",
+		},
+		"Mixed": map[string]interface{}{
+			"Fn::Sub": []interface{}{
+				"${Name}-${AWS::Region}",
+				map[string]interface{}{"Name": map[string]interface{}{"Ref": "AWS::AccountId"}},
+			},
+		},
+		"Unknown": map[string]interface{}{"Ref": "AWS::StackName"},
+		"Param":   map[string]interface{}{"Ref": "BucketName"},
+		"List":    []interface{}{map[string]interface{}{"Ref": "AWS::AccountId"}, "x"},
+	}
+
+	expected := map[string]interface{}{
+		"Region":  "us-east-1",
+		"Arn":     "arn:aws:s3:::logs-123456789012",
+		"Escaped": "${Literal}-us-east-1",
+		"Mixed": map[string]interface{}{
+			"Fn::Sub": []interface{}{
+				"${Name}-us-east-1",
+				map[string]interface{}{"Name": "123456789012"},
+			},
+		},
+		"Unknown": map[string]interface{}{"Ref": "AWS::StackName"},
+		"Param":   map[string]interface{}{"Ref": "BucketName"},
+		"List":    []interface{}{"123456789012", "x"},
+	}
+
+	actual := cft.ResolvePseudoParameters(model, p)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%#v\n!=\n%#v\n", actual, expected)
+	}
+
+	// The original is not changed
+	if _, ok := model["Region"].(map[string]interface{}); !ok {
+		t.Errorf("expected the original model to keep its Ref")
+	}
+}
@@ -1,5 +1,13 @@
 package cft
 
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/internal/node"
+	"gopkg.in/yaml.v3"
+)
+
 // Tags is a mapping from YAML short tags to full instrincic function names
 var Tags = map[string]string{
 	"!And":            "Fn::And",
@@ -27,3 +35,100 @@ var Tags = map[string]string{
 	"!Rain::Module":   "Rain::Module",
 	"!Rain::Constant": "Rain::Constant",
 }
+
+// ExpandTag converts n in place from a short-form intrinsic, like !Ref Bucket,
+// to its long form, like {Ref: Bucket}, and reports whether it was changed.
+// A !GetAtt string is split into the logical id and the attribute name,
+// so that !GetAtt Bucket.Arn comes out the same as {Fn::GetAtt: [Bucket, Arn]}.
+// Only n itself is converted, not its children.
+func ExpandTag(n *yaml.Node) (bool, error) {
+	funcName, ok := Tags[n.ShortTag()]
+	if !ok {
+		return false, nil
+	}
+
+	body := node.Clone(n)
+
+	// Fix empty Fn values (should never be null)
+	if body.Tag == "!!null" {
+		body.Tag = "!!str"
+	} else {
+		body.Tag = ""
+	}
+
+	if funcName == "Fn::GetAtt" && body.Kind == yaml.ScalarNode {
+		parts := strings.SplitN(body.Value, ".", 2)
+		if len(parts) != 2 {
+			return false, errors.New("GetAtt requires two parameters")
+		}
+		body = &yaml.Node{
+			Kind:        yaml.SequenceNode,
+			HeadComment: body.HeadComment,
+			LineComment: body.LineComment,
+			FootComment: body.FootComment,
+			Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: parts[0]},
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: parts[1]},
+			},
+		}
+	}
+
+	// Wrap in a map
+	*n = yaml.Node{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			{
+				Kind:  yaml.ScalarNode,
+				Style: 0,
+				Tag:   "!!str",
+				Value: funcName,
+			},
+			body,
+		},
+	}
+
+	return true, nil
+}
+
+// hasShortTags returns true if n or any of its children is a short-form intrinsic
+func hasShortTags(n *yaml.Node) bool {
+	if _, ok := Tags[n.ShortTag()]; ok {
+		return true
+	}
+	for _, c := range n.Content {
+		if hasShortTags(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// LongForm returns a copy of n with every short-form intrinsic converted
+// to its long form with ExpandTag. n itself is returned if it has none.
+// Templates read with the parse package are already in long form, but
+// nodes decoded directly with yaml.Unmarshal are not.
+func LongForm(n *yaml.Node) (*yaml.Node, error) {
+	if n == nil || !hasShortTags(n) {
+		return n, nil
+	}
+
+	out := node.Clone(n)
+	var expand func(*yaml.Node) error
+	expand = func(n *yaml.Node) error {
+		if _, err := ExpandTag(n); err != nil {
+			return err
+		}
+		for _, c := range n.Content {
+			if err := expand(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := expand(out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
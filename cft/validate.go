@@ -0,0 +1,85 @@
+package cft
+
+import (
+	"fmt"
+
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// Validate checks the structure of the template and returns every problem it finds.
+// The template must have a Resources section, and each resource must have a Type.
+// If the template has a State section, as state files written by rain cc do,
+// its ResourceModels must have an entry for each resource and no others.
+// The line numbers of problems are included so that they are easy to fix.
+func (t Template) Validate() []error {
+	errs := make([]error, 0)
+
+	if t.Node == nil || len(t.Node.Content) == 0 {
+		return append(errs, fmt.Errorf("template is empty"))
+	}
+
+	root := t.Node
+	if root.Kind == yaml.DocumentNode {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return append(errs, fmt.Errorf("expected the template to be a map (line %d)", root.Line))
+	}
+
+	resourcesKey, resources, _ := s11n.GetMapValue(root, string(Resources))
+	switch {
+	case resources == nil:
+		errs = append(errs, fmt.Errorf("missing required section '%s'", Resources))
+	case resources.Kind != yaml.MappingNode:
+		errs = append(errs, fmt.Errorf("expected '%s' to be a map (line %d)", Resources, resourcesKey.Line))
+		resources = nil
+	default:
+		for i := 0; i+1 < len(resources.Content); i += 2 {
+			name, resource := resources.Content[i], resources.Content[i+1]
+			if resource.Kind != yaml.MappingNode {
+				errs = append(errs, fmt.Errorf("expected resource %s to be a map (line %d)", name.Value, name.Line))
+				continue
+			}
+			_, typ, _ := s11n.GetMapValue(resource, "Type")
+			if typ == nil {
+				errs = append(errs, fmt.Errorf("resource %s is missing 'Type' (line %d)", name.Value, name.Line))
+			} else if typ.Kind != yaml.ScalarNode || typ.Value == "" {
+				errs = append(errs, fmt.Errorf("expected the Type of resource %s to be a string (line %d)", name.Value, typ.Line))
+			}
+		}
+	}
+
+	stateKey, state, _ := s11n.GetMapValue(root, string(State))
+	if state == nil {
+		return errs
+	}
+	if state.Kind != yaml.MappingNode {
+		return append(errs, fmt.Errorf("expected '%s' to be a map (line %d)", State, stateKey.Line))
+	}
+
+	modelsKey, models, _ := s11n.GetMapValue(state, "ResourceModels")
+	switch {
+	case models == nil:
+		return append(errs, fmt.Errorf("missing 'ResourceModels' under '%s' (line %d)", State, stateKey.Line))
+	case models.Kind != yaml.MappingNode:
+		return append(errs, fmt.Errorf("expected 'ResourceModels' to be a map (line %d)", modelsKey.Line))
+	case resources == nil:
+		return errs
+	}
+
+	for i := 0; i+1 < len(models.Content); i += 2 {
+		name := models.Content[i]
+		if k, _, _ := s11n.GetMapValue(resources, name.Value); k == nil {
+			errs = append(errs, fmt.Errorf("resource model %s is not in '%s' (line %d)", name.Value, Resources, name.Line))
+		}
+	}
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		name := resources.Content[i]
+		if k, _, _ := s11n.GetMapValue(models, name.Value); k == nil {
+			errs = append(errs, fmt.Errorf("resource %s has no entry in 'ResourceModels' (line %d)", name.Value, name.Line))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,71 @@
+package cft_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		template string
+		expected []string
+	}{
+		{`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+`, []string{}},
+		{`
+Parameters: {}
+`, []string{"missing required section 'Resources'"}},
+		{`
+Resources:
+  Bucket:
+    Properties: {}
+  Queue:
+    Type: [a]
+`, []string{
+			"resource Bucket is missing 'Type' (line 3)",
+			"expected the Type of resource Queue to be a string (line 6)",
+		}},
+		{`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Queue:
+    Type: AWS::SQS::Queue
+State:
+  ResourceModels:
+    Bucket:
+      Identifier: b
+    Topic:
+      Identifier: t
+`, []string{
+			"resource model Topic is not in 'Resources' (line 11)",
+			"resource Queue has no entry in 'ResourceModels' (line 5)",
+		}},
+		{`
+Resources: {}
+State:
+  FilePath: a.yaml
+`, []string{"missing 'ResourceModels' under 'State' (line 3)"}},
+	}
+
+	for _, testCase := range testCases {
+		template, err := parse.String(testCase.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual := make([]string, 0)
+		for _, err := range template.Validate() {
+			actual = append(actual, err.Error())
+		}
+
+		if strings.Join(actual, "\n") != strings.Join(testCase.expected, "\n") {
+			t.Errorf("%#v\n!=\n%#v\n", actual, testCase.expected)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/aws-cloudformation/rain/internal/config"
@@ -15,6 +16,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws/middleware"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	smithymiddleware "github.com/aws/smithy-go/middleware"
 )
 
@@ -40,11 +42,6 @@ func loadConfig(ctx context.Context, sessionName string) *aws.Config {
 	// Credential configs
 	var configs = make([]func(*awsconfig.LoadOptions) error, 0)
 
-	// Uncomment for testing against a local endpoint
-	//configs = append(configs, awsconfig.WithEndpointResolver(aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
-	//	return aws.Endpoint{URL: "http://localhost:8000"}, nil
-	//})))
-
 	// Add user-agent
 	configs = append(configs, awsconfig.WithAPIOptions(
 		[]func(*smithymiddleware.Stack) error{
@@ -120,6 +117,60 @@ func NamedConfig(sessionName string) aws.Config {
 	return *awsCfg
 }
 
+// roleConfigs are the configs returned by RoleConfig, by role ARN and external id
+var roleConfigs = make(map[string]aws.Config)
+var roleConfigsMu sync.Mutex
+
+// RoleConfig returns a copy of Config that assumes roleArn, passing
+// externalID if it is not empty. The current credentials are used to
+// call STS, and the role's credentials are refreshed when they expire.
+// It is safe to call from multiple goroutines once Config has been loaded.
+func RoleConfig(roleArn string, externalID string) aws.Config {
+	roleConfigsMu.Lock()
+	defer roleConfigsMu.Unlock()
+
+	key := roleArn + "|" + externalID
+	if cfg, ok := roleConfigs[key]; ok {
+		return cfg
+	}
+
+	cfg := Config()
+	client := sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if endpoint := EndpointURL(); endpoint != nil {
+			o.BaseEndpoint = endpoint
+		}
+	})
+	provider := stscreds.NewAssumeRoleProvider(client, roleArn, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = lastSessionName
+		if externalID != "" {
+			o.ExternalID = aws.String(externalID)
+		}
+	})
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+
+	roleConfigs[key] = cfg
+	return cfg
+}
+
+// EndpointURL returns the endpoint that clients should send requests to,
+// or nil if the AWS SDK should choose it. config.EndpointURL takes precedence
+// over the AWS_ENDPOINT_URL and AWS_ENDPOINT_URL_<SERVICE> environment
+// variables, which the SDK reads itself, so it is set on each client.
+func EndpointURL() *string {
+	if config.EndpointURL == "" {
+		return nil
+	}
+	return aws.String(config.EndpointURL)
+}
+
+// HasCustomEndpoint returns true if requests to service, e.g. S3,
+// go to an endpoint set by config.EndpointURL or the environment
+func HasCustomEndpoint(service string) bool {
+	return config.EndpointURL != "" ||
+		os.Getenv("AWS_ENDPOINT_URL") != "" ||
+		os.Getenv("AWS_ENDPOINT_URL_"+service) != ""
+}
+
 // SetRegion is used to set the current AWS region
 func SetRegion(region string) {
 	awsCfg.Region = region
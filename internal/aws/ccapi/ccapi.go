@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/appscode/jsonpatch"
 	"github.com/aws-cloudformation/rain/cft/format"
@@ -18,23 +19,65 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// RoleArn is a role to assume for Cloud Control API requests, for example
+// to query resources in another account than the one with the rain bucket.
+// The current credentials are used if it is empty.
+var RoleArn string
+
+// ExternalID is passed when assuming RoleArn, if it is set
+var ExternalID string
+
+// ReadOptions are the settings for GetResourceWithOptions and
+// ListResourcesWithOptions, for callers that don't use the package variables
+type ReadOptions struct {
+	// RoleArn is a role to assume for the request, like the RoleArn variable
+	RoleArn string
+
+	// ExternalID is passed when assuming RoleArn, if it is set
+	ExternalID string
+
+	// Limiter spaces out requests. There is no limit if it is nil.
+	Limiter *RateLimiter
+
+	// MaxRetries is how many times a throttled or transient error is retried
+	MaxRetries int
+}
+
+// packageReadOptions returns the ReadOptions set by the package variables
+func packageReadOptions() ReadOptions {
+	return ReadOptions{RoleArn: RoleArn, ExternalID: ExternalID, Limiter: Limiter, MaxRetries: MaxRetries}
+}
+
 func getClient() *cloudcontrol.Client {
-	return cloudcontrol.NewFromConfig(aws.Config())
+	return getClientFor(RoleArn, ExternalID)
+}
+
+// getClientFor returns a client that assumes roleArn, if it is set
+func getClientFor(roleArn string, externalID string) *cloudcontrol.Client {
+	cfg := aws.Config()
+	if roleArn != "" {
+		cfg = aws.RoleConfig(roleArn, externalID)
+	}
+	return cloudcontrol.NewFromConfig(cfg, func(o *cloudcontrol.Options) {
+		if endpoint := aws.EndpointURL(); endpoint != nil {
+			o.BaseEndpoint = endpoint
+		}
+	})
+}
+
+// JoinIdentifier returns the identifier CCAPI expects for a resource
+// type with a composite primary identifier, e.g. ClusterName|ServiceName.
+// The parts must be in the order the primaryIdentifier is documented in the schema.
+func JoinIdentifier(parts []string) string {
+	return strings.Join(parts, "|")
 }
 
 // Returns true if the resource already exists
 func ResourceExists(typeName string, identifier []string) bool {
 
-	id := ""
-
 	// CCAPI expects the identifier to match the order that the
 	// primaryIdentifier is documented in the schema
-	for i, idValue := range identifier {
-		id += idValue
-		if i < len(identifier)-1 {
-			id += "|"
-		}
-	}
+	id := JoinIdentifier(identifier)
 
 	config.Debugf("ResourceExists %v %v", typeName, id)
 
@@ -116,22 +159,162 @@ func CreateResource(logicalId string, resource *yaml.Node) (identifier string, m
 }
 
 // GetResource gets a resource from cloud control api
-// It returns the resource model as a string
+// It returns the resource model as a string.
+// Throttling and transient errors are retried up to MaxRetries times.
+// Use IsNotFound to check if the resource does not exist.
+// Use GetResourceWithMetadata to also get the resource's ARN.
 func GetResource(identifier string, typeName string) (string, error) {
 
+	resource, err := GetResourceWithMetadata(identifier, typeName)
+	if err != nil {
+		return "", err
+	}
+
+	return resource.Properties, nil
+
+}
+
+// ResourceDescription is the live state of a resource along with
+// the metadata that Cloud Control API returns for it
+type ResourceDescription struct {
+	// Identifier is the primary identifier of the resource
+	Identifier string
+
+	// TypeName is the resource type, e.g. AWS::S3::Bucket
+	TypeName string
+
+	// Arn is the primary ARN of the resource, if its model has one
+	Arn string
+
+	// Properties is the JSON model of the resource.
+	// It is empty if Cloud Control API did not return a model.
+	Properties string
+}
+
+// GetResourceWithMetadata returns the live state of a resource along with
+// its identifier and ARN. Cloud Control API does not report who last
+// changed a resource or when, so only what is in the model is available.
+// Throttling and transient errors are retried up to MaxRetries times.
+func GetResourceWithMetadata(identifier string, typeName string) (*ResourceDescription, error) {
+	return GetResourceWithMetadataContext(context.Background(), identifier, typeName)
+}
+
+// GetResourceWithMetadataContext is like GetResourceWithMetadata, but gives up
+// when ctx is done, so that a hung request can be limited with a timeout.
+// Use errors.Is with context.DeadlineExceeded to check for a timeout.
+func GetResourceWithMetadataContext(ctx context.Context, identifier string, typeName string) (*ResourceDescription, error) {
+	return GetResourceWithOptions(ctx, identifier, typeName, packageReadOptions())
+}
+
+// GetResourceWithOptions is like GetResourceWithMetadataContext, with the
+// role, rate limit, and retries in opts instead of the package variables
+func GetResourceWithOptions(ctx context.Context, identifier string, typeName string, opts ReadOptions) (*ResourceDescription, error) {
+
 	input := &cloudcontrol.GetResourceInput{
 		Identifier: &identifier,
 		TypeName:   &typeName,
 	}
 
-	result, err := getClient().GetResource(context.Background(), input)
+	var result *cloudcontrol.GetResourceOutput
+	err := withRetryOptions(ctx, opts, func() error {
+		var err error
+		result, err = getClientFor(opts.RoleArn, opts.ExternalID).GetResource(ctx, input)
+		return err
+	})
 
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	retval := &ResourceDescription{
+		Identifier: identifier,
+		TypeName:   typeName,
+	}
+	if result.ResourceDescription == nil {
+		return retval, nil
+	}
+	if result.ResourceDescription.Properties != nil {
+		retval.Properties = *result.ResourceDescription.Properties
+	}
+	if result.ResourceDescription.Identifier != nil {
+		retval.Identifier = *result.ResourceDescription.Identifier
 	}
+	if result.TypeName != nil {
+		retval.TypeName = *result.TypeName
+	}
+	retval.Arn = findArn(typeName, retval.Properties)
+
+	return retval, nil
 
-	return *result.ResourceDescription.Properties, nil
+}
+
+// findArn returns the ARN of a resource from its JSON model: the Arn property,
+// or the one named after the type, like TopicArn for AWS::SNS::Topic.
+// Other properties that end with Arn, like RoleArn or KmsKeyArn, usually
+// refer to other resources, so an empty string is returned if neither is set.
+func findArn(typeName string, properties string) string {
+	var model map[string]any
+	if err := json.Unmarshal([]byte(properties), &model); err != nil {
+		return ""
+	}
+
+	keys := []string{"Arn"}
+	if i := strings.LastIndex(typeName, "::"); i >= 0 {
+		keys = append(keys, typeName[i+2:]+"Arn")
+	}
+
+	for _, k := range keys {
+		if arn, ok := model[k].(string); ok && strings.HasPrefix(arn, "arn:") {
+			return arn
+		}
+	}
+
+	return ""
+}
+
+// ListResources returns the identifiers of all resources of the given type
+// in the current account and region.
+// Throttling and transient errors are retried up to MaxRetries times.
+func ListResources(typeName string) ([]string, error) {
+	return ListResourcesWithOptions(typeName, packageReadOptions())
+}
+
+// ListResourcesWithOptions is like ListResources, with the role, rate limit,
+// and retries in opts instead of the package variables
+func ListResourcesWithOptions(typeName string, opts ReadOptions) ([]string, error) {
+
+	identifiers := make([]string, 0)
+	var nextToken *string
+
+	for {
+		input := &cloudcontrol.ListResourcesInput{
+			TypeName:  &typeName,
+			NextToken: nextToken,
+		}
+
+		var result *cloudcontrol.ListResourcesOutput
+		err := withRetryOptions(context.Background(), opts, func() error {
+			var err error
+			result, err = getClientFor(opts.RoleArn, opts.ExternalID).ListResources(context.Background(), input)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rd := range result.ResourceDescriptions {
+			if rd.Identifier != nil {
+				identifiers = append(identifiers, *rd.Identifier)
+			}
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		nextToken = result.NextToken
+	}
 
+	return identifiers, nil
 }
 
 // pollForCompletion checks for progress until the operation is complete or fails
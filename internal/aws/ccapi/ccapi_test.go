@@ -73,3 +73,24 @@ Resources:
 	}
 
 }
+
+func TestFindArn(t *testing.T) {
+	cases := []struct {
+		typeName   string
+		properties string
+		expected   string
+	}{
+		{"AWS::S3::Bucket", `{"Arn": "arn:aws:s3:::a", "BucketName": "a"}`, "arn:aws:s3:::a"},
+		{"AWS::SNS::Topic", `{"TopicArn": "arn:aws:sns:us-east-1:123:t", "KmsMasterKeyArn": "arn:aws:kms:us-east-1:123:key/k"}`, "arn:aws:sns:us-east-1:123:t"},
+		{"AWS::Lambda::Function", `{"FunctionName": "f", "Role": "arn:aws:iam::123:role/r", "KmsKeyArn": "arn:aws:kms:us-east-1:123:key/k"}`, ""},
+		{"AWS::SQS::Queue", `{"QueueUrl": "https://example.com", "QueueArn": "not-an-arn"}`, ""},
+		{"AWS::S3::Bucket", `{}`, ""},
+		{"AWS::S3::Bucket", `not json`, ""},
+	}
+
+	for _, c := range cases {
+		if got := findArn(c.typeName, c.properties); got != c.expected {
+			t.Errorf("findArn(%s, %s): %#v != %#v", c.typeName, c.properties, got, c.expected)
+		}
+	}
+}
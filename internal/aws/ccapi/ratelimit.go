@@ -0,0 +1,109 @@
+package ccapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws-cloudformation/rain/internal/config"
+)
+
+// Limiter spaces out the Cloud Control API reads made through withRetry,
+// so that a run with many resources doesn't get the account throttled.
+// There is no limit if it is nil.
+var Limiter *RateLimiter
+
+// RateLimiter is a token bucket, shared by every request in a run, that
+// adapts to throttling. Each throttled request halves the rate, down to
+// one request every few seconds, and each request that succeeds adds back
+// a tenth of the starting rate, until it is reached again.
+type RateLimiter struct {
+	mu sync.Mutex
+
+	// rate is the current number of requests per second, up to maxRate
+	rate    float64
+	maxRate float64
+	minRate float64
+
+	// next is when the next request can be made
+	next time.Time
+
+	now func() time.Time
+}
+
+// NewRateLimiter returns a limiter that starts at rate requests per second
+func NewRateLimiter(rate float64) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		maxRate: rate,
+		minRate: min(rate, 0.2),
+		now:     time.Now,
+	}
+}
+
+// Rate returns the current number of requests per second
+func (l *RateLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// reserve returns how long to wait before making a request,
+// and holds that slot for it
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(time.Duration(float64(time.Second) / l.rate))
+
+	return start.Sub(now)
+}
+
+// Wait blocks until a request can be made, or returns ctx's error once it is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	delay := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Throttled slows down the requests that follow a throttled one
+func (l *RateLimiter) Throttled() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = max(l.rate/2, l.minRate)
+	config.Debugf("Throttled by Cloud Control API, slowing down to %.2f requests per second", l.rate)
+}
+
+// Succeeded speeds the requests back up towards the starting rate
+func (l *RateLimiter) Succeeded() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = min(l.rate+l.maxRate/10, l.maxRate)
+}
@@ -0,0 +1,60 @@
+package ccapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(10)
+	l.now = func() time.Time { return now }
+
+	// Requests are spaced out by a tenth of a second
+	if d := l.reserve(); d != 0 {
+		t.Errorf("expected the first request not to wait, got %v", d)
+	}
+	if d := l.reserve(); d != 100*time.Millisecond {
+		t.Errorf("expected the second request to wait 100ms, got %v", d)
+	}
+
+	// Throttling halves the rate, down to the minimum
+	l.Throttled()
+	if r := l.Rate(); r != 5 {
+		t.Errorf("expected a rate of 5, got %v", r)
+	}
+	for i := 0; i < 10; i++ {
+		l.Throttled()
+	}
+	if r := l.Rate(); r != 0.2 {
+		t.Errorf("expected a rate of 0.2, got %v", r)
+	}
+
+	// Successes speed back up to the starting rate, but not past it
+	for i := 0; i < 20; i++ {
+		l.Succeeded()
+	}
+	if r := l.Rate(); r != 10 {
+		t.Errorf("expected a rate of 10, got %v", r)
+	}
+
+	// A nil limiter does not limit anything
+	var none *RateLimiter
+	if err := none.Wait(context.Background()); err != nil {
+		t.Error(err)
+	}
+	none.Throttled()
+	none.Succeeded()
+}
+
+func TestRateLimiterContext(t *testing.T) {
+	l := NewRateLimiter(0.5)
+	l.reserve()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err != context.Canceled {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+}
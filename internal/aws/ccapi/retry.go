@@ -0,0 +1,106 @@
+package ccapi
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	smithy "github.com/aws/smithy-go"
+)
+
+// MaxRetries is how many times GetResource retries after a throttling
+// or transient error before giving up
+var MaxRetries = 3
+
+// retryBaseDelay is the delay before the first retry. It doubles after each attempt.
+var retryBaseDelay = 500 * time.Millisecond
+
+// IsNotFound returns true if err means that the resource does not exist.
+// Besides the modeled ResourceNotFoundException, some resource handlers
+// report a missing resource as an API error with a NotFound code.
+func IsNotFound(err error) bool {
+	var nf *types.ResourceNotFoundException
+	if errors.As(err, &nf) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.ErrorCode()
+		return code == "ResourceNotFoundException" || code == "NotFound"
+	}
+	return false
+}
+
+// isRetryable returns true for throttling and transient service errors
+func isRetryable(err error) bool {
+	var network *types.NetworkFailureException
+	var internal *types.ServiceInternalErrorException
+	var general *types.GeneralServiceException
+	if errors.As(err, &network) || errors.As(err, &internal) || errors.As(err, &general) {
+		return true
+	}
+
+	return isThrottling(err)
+}
+
+// isThrottling returns true if err means that requests are being made too quickly
+func isThrottling(err error) bool {
+	var throttling *types.ThrottlingException
+	if errors.As(err, &throttling) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "Throttling", "ThrottlingException", "TooManyRequestsException", "RequestLimitExceeded":
+			return true
+		}
+	}
+
+	return false
+}
+
+// withRetry calls fn until it succeeds, returns an error that isn't worth
+// retrying, or has been retried MaxRetries times. The delay between attempts
+// grows exponentially, with jitter so that concurrent callers spread out.
+func withRetry(fn func() error) error {
+	return withRetryContext(context.Background(), fn)
+}
+
+// withRetryContext is like withRetry, but stops waiting to retry
+// and returns the context's error once ctx is done.
+// Each attempt waits for Limiter, and tells it whether it was throttled.
+func withRetryContext(ctx context.Context, fn func() error) error {
+	return withRetryOptions(ctx, packageReadOptions(), fn)
+}
+
+// withRetryOptions is like withRetryContext, with the limiter and the number
+// of retries in opts instead of the package variables
+func withRetryOptions(ctx context.Context, opts ReadOptions, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := opts.Limiter.Wait(ctx); err != nil {
+			return err
+		}
+		err := fn()
+		if isThrottling(err) {
+			opts.Limiter.Throttled()
+		} else if err == nil {
+			opts.Limiter.Succeeded()
+		}
+		if err == nil || !isRetryable(err) || attempt >= opts.MaxRetries {
+			return err
+		}
+		delay := retryBaseDelay << attempt
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		config.Debugf("Retrying in %v after error: %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ccapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	smithy "github.com/aws/smithy-go"
+)
+
+func TestWithRetry(t *testing.T) {
+	defer func(d time.Duration, n int) {
+		retryBaseDelay = d
+		MaxRetries = n
+	}(retryBaseDelay, MaxRetries)
+	retryBaseDelay = time.Millisecond
+	MaxRetries = 3
+
+	// Throttling is retried until it succeeds
+	calls := 0
+	err := withRetry(func() error {
+		calls++
+		if calls < 3 {
+			return &types.ThrottlingException{}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("expected success after 3 calls, got %d: %v", calls, err)
+	}
+
+	// Give up after MaxRetries
+	calls = 0
+	err = withRetry(func() error {
+		calls++
+		return &types.ThrottlingException{}
+	})
+	if err == nil || calls != MaxRetries+1 {
+		t.Errorf("expected an error after %d calls, got %d", MaxRetries+1, calls)
+	}
+
+	// NotFound is not retried
+	calls = 0
+	err = withRetry(func() error {
+		calls++
+		return &types.ResourceNotFoundException{}
+	})
+	if !IsNotFound(err) || calls != 1 {
+		t.Errorf("expected a single NotFound call, got %d: %v", calls, err)
+	}
+
+	if !IsNotFound(&smithy.GenericAPIError{Code: "NotFound"}) {
+		t.Errorf("expected a NotFound API error to be NotFound")
+	}
+	if IsNotFound(&smithy.GenericAPIError{Code: "AccessDenied"}) {
+		t.Errorf("expected an AccessDenied API error not to be NotFound")
+	}
+
+	if IsNotFound(errors.New("other")) {
+		t.Errorf("expected a plain error not to be NotFound")
+	}
+}
+
+func TestWithRetryContext(t *testing.T) {
+	defer func(d time.Duration, n int) {
+		retryBaseDelay = d
+		MaxRetries = n
+	}(retryBaseDelay, MaxRetries)
+	retryBaseDelay = time.Hour
+	MaxRetries = 3
+
+	// A done context stops the wait before the next retry
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	err := withRetryContext(ctx, func() error {
+		calls++
+		return &types.ThrottlingException{}
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || calls != 1 {
+		t.Errorf("expected a deadline error after 1 call, got %d: %v", calls, err)
+	}
+}
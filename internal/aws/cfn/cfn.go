@@ -87,7 +87,11 @@ func checkTemplate(template cft.Template) (string, error) {
 }
 
 func getClient() *cloudformation.Client {
-	return cloudformation.NewFromConfig(aws.Config())
+	return cloudformation.NewFromConfig(aws.Config(), func(o *cloudformation.Options) {
+		if endpoint := aws.EndpointURL(); endpoint != nil {
+			o.BaseEndpoint = endpoint
+		}
+	})
 }
 
 // GetStackTemplate returns the template used to launch the named stack
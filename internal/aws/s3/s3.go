@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"path/filepath"
 	"strings"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithy "github.com/aws/smithy-go"
 	"github.com/aws/smithy-go/ptr"
 
 	"github.com/aws-cloudformation/rain/internal/aws"
@@ -39,8 +41,19 @@ var BucketKeyPrefix = ""
 // ExpectedBucketOwner is set by the --s3-owner param to deploy and pkg commands
 var ExpectedBucketOwner = ""
 
+// KMSKeyId is set by the --kms-key-id param to cc commands.
+// If it is set, PutObject, PutObjectIfMatch, and PutObjectWithChecksum
+// encrypt objects with this key. Artifacts written by Upload are not encrypted with it.
+var KMSKeyId = ""
+
 func getClient() *s3.Client {
-	return s3.NewFromConfig(aws.Config())
+	return s3.NewFromConfig(aws.Config(), func(o *s3.Options) {
+		if endpoint := aws.EndpointURL(); endpoint != nil {
+			o.BaseEndpoint = endpoint
+		}
+		// Mocks like LocalStack don't resolve bucket names as host names
+		o.UsePathStyle = aws.HasCustomEndpoint("S3")
+	})
 }
 
 // BucketHasContents returns true if the bucket is not empty
@@ -213,31 +226,11 @@ func Upload(bucketName string, content []byte, extension string) (string, error)
 // If that doesn't exist, we use "rain-artifacts-accountid-region".
 // If a non-blank string is passed in, we create that bucket if it doesn't exist.
 func RainBucket(forceCreation bool) string {
-	accountID, err := sts.GetAccountID()
+	bucketName, err := rainBucketName()
 	if err != nil {
-		panic(fmt.Errorf("unable to get account ID: %w", err))
+		panic(err)
 	}
 
-	// --bucket-name is passed in as an arg to various commands
-	bucketName := BucketName
-
-	if bucketName == "" {
-		storedName, err := ssm.GetParameter(RAIN_BUCKET_SSM_KEY)
-		if err != nil {
-			// This is expected if the key is not found
-			config.Debugf("Could not get %s: %v", RAIN_BUCKET_SSM_KEY, err)
-		}
-		if storedName != "" {
-			config.Debugf("Found bucket name in parameter store: %s", storedName)
-			bucketName = storedName
-		} else {
-			config.Debugf("Bucket name not found in parameter store")
-			bucketName = fmt.Sprintf("rain-artifacts-%s-%s", accountID, aws.Config().Region)
-		}
-	}
-
-	config.Debugf("Artifact bucket: %s", bucketName)
-
 	isBucketExists, err := BucketExists(bucketName)
 	if err != nil {
 		panic(fmt.Errorf("unable to confirm whether artifact bucket exists: %w", err))
@@ -295,8 +288,100 @@ func RainBucket(forceCreation bool) string {
 	return bucketName
 }
 
+// ErrRainBucketNotFound is returned by FindRainBucket when the rain bucket does not exist
+var ErrRainBucketNotFound = errors.New("rain bucket not found")
+
+// FindRainBucket returns the name of the rain deployment bucket in the current region,
+// like RainBucket, but returns an error instead of creating the bucket if it does not exist
+func FindRainBucket() (string, error) {
+	bucketName, err := rainBucketName()
+	if err != nil {
+		return "", err
+	}
+
+	exists, err := BucketExists(bucketName)
+	if err != nil {
+		return "", fmt.Errorf("unable to confirm whether rain bucket %s exists: %w", bucketName, err)
+	}
+	if !exists {
+		return "", fmt.Errorf("%w: %s does not exist in %s", ErrRainBucketNotFound, bucketName, aws.Config().Region)
+	}
+
+	return bucketName, nil
+}
+
+// rainBucketName returns the name of the rain bucket without checking that it exists
+func rainBucketName() (string, error) {
+	// --bucket-name is passed in as an arg to various commands
+	bucketName := BucketName
+	if bucketName != "" {
+		return bucketName, nil
+	}
+
+	accountID, err := sts.GetAccountID()
+	if err != nil {
+		return "", fmt.Errorf("unable to get account ID: %w", err)
+	}
+
+	storedName, err := ssm.GetParameter(RAIN_BUCKET_SSM_KEY)
+	if err != nil {
+		// This is expected if the key is not found
+		config.Debugf("Could not get %s: %v", RAIN_BUCKET_SSM_KEY, err)
+	}
+	if storedName != "" {
+		config.Debugf("Found bucket name in parameter store: %s", storedName)
+		bucketName = storedName
+	} else {
+		config.Debugf("Bucket name not found in parameter store")
+		bucketName = fmt.Sprintf("rain-artifacts-%s-%s", accountID, aws.Config().Region)
+	}
+
+	config.Debugf("Artifact bucket: %s", bucketName)
+
+	return bucketName, nil
+}
+
 // GetObject gets an object by key from an S3 bucket
 func GetObject(bucketName string, key string) ([]byte, error) {
+	body, _, err := GetObjectWithMetadata(bucketName, key)
+	return body, err
+}
+
+// GetObjectWithMetadata gets an object by key from an S3 bucket,
+// along with the user metadata that was stored with it
+func GetObjectWithMetadata(bucketName string, key string) ([]byte, map[string]string, error) {
+	stream, err := GetObjectStream(bucketName, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stream.Body.Close()
+
+	body, err := io.ReadAll(stream.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, stream.Metadata, nil
+}
+
+// ObjectStream is an object whose content is read as it is downloaded
+type ObjectStream struct {
+	// Body is the content of the object, which the caller must close
+	Body io.ReadCloser
+
+	// Metadata is the user metadata stored with the object
+	Metadata map[string]string
+
+	// Size is the length of the content in bytes, or -1 if it is unknown
+	Size int64
+
+	// ETag identifies this version of the object, for PutObjectIfMatch
+	ETag string
+}
+
+// GetObjectStream is like GetObjectWithMetadata, but it returns the object
+// before its content is downloaded, so that a large object can be read
+// without holding all of it in memory
+func GetObjectStream(bucketName string, key string) (*ObjectStream, error) {
 
 	accountId, err := getAccountId()
 	if err != nil {
@@ -310,13 +395,86 @@ func GetObject(bucketName string, key string) ([]byte, error) {
 			ExpectedBucketOwner: awssdk.String(accountId),
 		})
 	if err != nil {
-		return nil, err
+		return nil, explainKMSError(err, "kms:Decrypt", bucketName, key)
 	}
-	body, err := io.ReadAll(result.Body)
+
+	size := int64(-1)
+	if result.ContentLength != nil {
+		size = *result.ContentLength
+	}
+	return &ObjectStream{Body: result.Body, Metadata: result.Metadata, Size: size,
+		ETag: awssdk.ToString(result.ETag)}, nil
+}
+
+// GetObjectWithETag gets an object by key from an S3 bucket,
+// along with the ETag to pass to PutObjectIfMatch when it is replaced
+func GetObjectWithETag(bucketName string, key string) ([]byte, string, error) {
+	stream, err := GetObjectStream(bucketName, key)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	defer stream.Body.Close()
+
+	body, err := io.ReadAll(stream.Body)
+	if err != nil {
+		return nil, "", err
 	}
-	return body, nil
+	return body, stream.ETag, nil
+}
+
+// ChecksumMetadataKey is the user metadata key that PutObjectWithChecksum
+// stores the SHA-256 of an object's content under
+const ChecksumMetadataKey = "rain-sha256"
+
+// ErrChecksumMismatch is returned by VerifyChecksum when an object's
+// content does not match the checksum that was stored with it
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Checksum returns the hex encoded SHA-256 of body
+func Checksum(body []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(body))
+}
+
+// VerifyChecksum checks body against the checksum in metadata that was
+// stored by PutObjectWithChecksum. ok is false if there is no checksum,
+// for example because the object was written by an older version of rain.
+func VerifyChecksum(body []byte, metadata map[string]string) (ok bool, err error) {
+	return VerifyDigest(Checksum(body), metadata)
+}
+
+// VerifyDigest is like VerifyChecksum for content that has already been
+// hashed, for example by a ChecksumReader
+func VerifyDigest(actual string, metadata map[string]string) (ok bool, err error) {
+	expected, found := metadata[ChecksumMetadataKey]
+	if !found {
+		return false, nil
+	}
+	if actual != expected {
+		return false, fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expected, actual)
+	}
+	return true, nil
+}
+
+// ChecksumReader hashes the content that is read through it,
+// so that a stream can be checked with VerifyDigest once it is read
+type ChecksumReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+// NewChecksumReader returns a ChecksumReader that reads from r
+func NewChecksumReader(r io.Reader) *ChecksumReader {
+	h := sha256.New()
+	return &ChecksumReader{r: io.TeeReader(r, h), h: h}
+}
+
+func (c *ChecksumReader) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// Sum returns the hex encoded SHA-256 of what has been read, like Checksum
+func (c *ChecksumReader) Sum() string {
+	return fmt.Sprintf("%x", c.h.Sum(nil))
 }
 
 // GetUnzippedObjectSize gets the uncompressed length in bytes of an object.
@@ -396,6 +554,27 @@ func HeadObject(bucketName string, key string) (*S3ObjectInfo, error) {
 
 // PutObject puts an object into a bucket
 func PutObject(bucketName string, key string, body []byte) error {
+	return putObject(bucketName, key, body, nil, nil)
+}
+
+// PutObjectIfMatch puts an object into a bucket only if it has not changed
+// since it was read with the given ETag, or, if etag is empty, only if it
+// does not exist yet. IsPreconditionFailed is true for the error that is
+// returned if the object was changed in the meantime.
+func PutObjectIfMatch(bucketName string, key string, body []byte, etag string) error {
+	return putObject(bucketName, key, body, nil, &etag)
+}
+
+// PutObjectWithChecksum puts an object into a bucket and stores the SHA-256
+// of its content in the object's metadata, so that it can be checked with
+// VerifyChecksum when it is read back
+func PutObjectWithChecksum(bucketName string, key string, body []byte) error {
+	return putObject(bucketName, key, body, map[string]string{ChecksumMetadataKey: Checksum(body)}, nil)
+}
+
+// putObject puts an object into a bucket. If etag is not nil, the write is
+// conditional on the object still having that ETag, or not existing if it is empty.
+func putObject(bucketName string, key string, body []byte, metadata map[string]string, etag *string) error {
 
 	// Determine the correct content type
 	// This seems to be the default. It breaks web pages served by S3.
@@ -422,15 +601,89 @@ func PutObject(bucketName string, key string, body []byte) error {
 		return err
 	}
 
-	_, err = getClient().PutObject(context.Background(),
-		&s3.PutObjectInput{
-			Bucket:              &bucketName,
-			Key:                 &key,
-			Body:                bytes.NewReader(body),
-			ContentType:         &contentType,
-			ExpectedBucketOwner: awssdk.String(accountId),
-		})
-	return err
+	input := &s3.PutObjectInput{
+		Bucket:              &bucketName,
+		Key:                 &key,
+		Body:                bytes.NewReader(body),
+		ContentType:         &contentType,
+		ExpectedBucketOwner: awssdk.String(accountId),
+		Metadata:            metadata,
+	}
+	if KMSKeyId != "" {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = &KMSKeyId
+	}
+	if etag != nil && *etag != "" {
+		input.IfMatch = etag
+	} else if etag != nil {
+		input.IfNoneMatch = awssdk.String("*")
+	}
+
+	_, err = getClient().PutObject(context.Background(), input)
+	if err != nil {
+		return explainKMSError(err, "kms:GenerateDataKey", bucketName, key)
+	}
+	return nil
+}
+
+// explainKMSError replaces an access denied error caused by a KMS key
+// with a message that says which permission is missing
+func explainKMSError(err error, permission string, bucketName string, key string) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	if !strings.Contains(apiErr.ErrorCode(), "AccessDenied") ||
+		!strings.Contains(strings.ToLower(apiErr.ErrorMessage()), "kms") {
+		return err
+	}
+	return fmt.Errorf("access to s3://%s/%s was denied by its KMS key, check that you have %s permission on the key: %s",
+		bucketName, key, permission, apiErr.ErrorMessage())
+}
+
+// IsNoSuchKey returns true if err is the error returned by GetObject
+// for an object that does not exist
+func IsNoSuchKey(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey"
+}
+
+// IsPreconditionFailed returns true if err is the error returned by
+// PutObjectIfMatch for an object that was changed since it was read
+func IsPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict"
+}
+
+// ListObjects returns the keys of all objects in a bucket that start with prefix
+func ListObjects(bucketName string, prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	input := &s3.ListObjectsV2Input{
+		Bucket: &bucketName,
+		Prefix: &prefix,
+	}
+	for {
+		res, err := getClient().ListObjectsV2(context.Background(), input)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range res.Contents {
+			keys = append(keys, *item.Key)
+		}
+		if res.IsTruncated != nil && *res.IsTruncated {
+			input.ContinuationToken = res.NextContinuationToken
+		} else {
+			break
+		}
+	}
+	return keys, nil
 }
 
 // DeleteObject deletes an object from a bucket
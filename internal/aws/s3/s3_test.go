@@ -0,0 +1,77 @@
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithy "github.com/aws/smithy-go"
+)
+
+func TestExplainKMSError(t *testing.T) {
+	kmsErr := &smithy.GenericAPIError{
+		Code:    "AccessDenied",
+		Message: "User is not authorized to perform: kms:Decrypt",
+	}
+	err := explainKMSError(kmsErr, "kms:Decrypt", "bucket", "key")
+	if !strings.Contains(err.Error(), "check that you have kms:Decrypt permission") {
+		t.Errorf("unexpected message: %v", err)
+	}
+
+	otherErr := &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}
+	if err := explainKMSError(otherErr, "kms:Decrypt", "bucket", "key"); err != otherErr {
+		t.Errorf("expected other access denied errors to be unchanged, got %v", err)
+	}
+
+	plain := errors.New("plain")
+	if err := explainKMSError(plain, "kms:Decrypt", "bucket", "key"); err != plain {
+		t.Errorf("expected non-API errors to be unchanged, got %v", err)
+	}
+}
+
+func TestIsNoSuchKey(t *testing.T) {
+	if !IsNoSuchKey(&types.NoSuchKey{}) {
+		t.Errorf("expected NoSuchKey to match")
+	}
+	if !IsNoSuchKey(&smithy.GenericAPIError{Code: "NoSuchKey"}) {
+		t.Errorf("expected a NoSuchKey API error to match")
+	}
+	if IsNoSuchKey(&smithy.GenericAPIError{Code: "AccessDenied"}) || IsNoSuchKey(errors.New("plain")) {
+		t.Errorf("expected other errors not to match")
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	body := []byte("Resources: {}\n")
+	metadata := map[string]string{ChecksumMetadataKey: Checksum(body)}
+
+	if ok, err := VerifyChecksum(body, metadata); !ok || err != nil {
+		t.Errorf("expected the checksum to match: %v", err)
+	}
+
+	if _, err := VerifyChecksum([]byte("Resources: {"), metadata); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+
+	if ok, err := VerifyChecksum(body, map[string]string{}); ok || err != nil {
+		t.Errorf("expected a missing checksum to be unverified without an error, got %v, %v", ok, err)
+	}
+}
+
+func TestChecksumReader(t *testing.T) {
+	body := []byte("Resources: {}\n")
+	r := NewChecksumReader(bytes.NewReader(body))
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Sum() != Checksum(body) {
+		t.Errorf("expected %s, got %s", Checksum(body), r.Sum())
+	}
+	if ok, err := VerifyDigest(r.Sum(), map[string]string{ChecksumMetadataKey: Checksum(body)}); !ok || err != nil {
+		t.Errorf("expected the digest to match: %v", err)
+	}
+}
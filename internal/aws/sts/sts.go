@@ -9,7 +9,11 @@ import (
 )
 
 func getClient() *sts.Client {
-	return sts.NewFromConfig(aws.Config())
+	return sts.NewFromConfig(aws.Config(), func(o *sts.Options) {
+		if endpoint := aws.EndpointURL(); endpoint != nil {
+			o.BaseEndpoint = endpoint
+		}
+	})
 }
 
 // GetSessionToken returns a session token for the current IAM principle
@@ -0,0 +1,269 @@
+package cc
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/aws/s3"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/console/spinner"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+)
+
+// loadAgainst reads the state file to compare to with --against.
+// against is a local file if one exists at that path,
+// otherwise it is the name of a deployment in the bucket.
+func loadAgainst(against string, opts DriftOptions) (cft.Template, error) {
+	var obj []byte
+	var err error
+
+	if _, statErr := os.Stat(against); statErr == nil {
+		obj, err = os.ReadFile(against)
+	} else {
+		var bucketName string
+		bucketName, err = stateBucket(opts.Bucket, opts.CreateBucket)
+		if err != nil {
+			return cft.Template{}, err
+		}
+		key := stateKey(against, opts.Prefix)
+		spinner.Push("Downloading state file to compare to")
+		obj, err = s3.GetObject(bucketName, key)
+		spinner.Pop()
+	}
+	if err != nil {
+		return cft.Template{}, fmt.Errorf("%w: %v", ErrStateNotFound, err)
+	}
+
+	obj, _, err = decompressState(obj)
+	if err != nil {
+		return cft.Template{}, err
+	}
+
+	template, err := parse.StringStrict(string(obj))
+	if err != nil {
+		return cft.Template{}, err
+	}
+
+	if err := validateState(template); err != nil {
+		return cft.Template{}, fmt.Errorf("%s: %v", against, err)
+	}
+
+	return template, nil
+}
+
+// storedModels returns the Model of each resource in a state file's ResourceModels
+func storedModels(template cft.Template) (map[string]map[string]any, []string, error) {
+	resourceModels, err := template.GetNode(cft.State, "ResourceModels")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrMissingSection, err)
+	}
+
+	models := make(map[string]map[string]any)
+	names := make([]string, 0)
+	for i := 0; i+1 < len(resourceModels.Content); i += 2 {
+		name := resourceModels.Content[i].Value
+		_, model, _ := s11n.GetMapValue(resourceModels.Content[i+1], "Model")
+		if model == nil {
+			return nil, nil, fmt.Errorf("%w: expected State %s to have Model", ErrResourceModelMissing, name)
+		}
+		var m map[string]any
+		if err := model.Decode(&m); err != nil {
+			return nil, nil, err
+		}
+		models[name] = m
+		names = append(names, name)
+	}
+
+	return models, names, nil
+}
+
+// compareStates compares the stored model of each resource in against (old)
+// to its stored model in template (new), without querying live state.
+// Resources that are only in one of the state files are reported as drifted.
+func compareStates(template cft.Template, against cft.Template, opts DriftOptions) ([]*ResourceDrift, error) {
+	if opts.IdentityKeys == nil {
+		opts.IdentityKeys = defaultIdentityKeys
+	}
+
+	resources, err := template.GetSection(cft.Resources)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMissingSection, err)
+	}
+
+	names, err := selectedResources(resources, opts.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceMap, err := template.Resources()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMissingSection, err)
+	}
+
+	againstResources, err := against.Resources()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMissingSection, err)
+	}
+
+	newModels, _, err := storedModels(template)
+	if err != nil {
+		return nil, err
+	}
+	oldModels, oldNames, err := storedModels(against)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resources that have been removed since are listed after the current ones
+	if len(opts.Resources) == 0 {
+		for _, name := range oldNames {
+			if _, ok := resourceMap[name]; !ok {
+				names = append(names, name)
+				resourceMap[name] = againstResources[name]
+			}
+		}
+	}
+
+	names, _ = filterByType(names, resourceMap, opts.IncludeTypes, opts.ExcludeTypes)
+	names, _ = filterRetained(names, resourceMap, opts.SkipRetained)
+
+	// Schemas are only loaded to order composite identifiers that are stored as maps
+	schemas := newSchemaCache()
+
+	results := make([]*ResourceDrift, 0)
+	for _, name := range names {
+		newModel, inNew := newModels[name]
+		oldModel, inOld := oldModels[name]
+
+		rd := &ResourceDrift{Name: name, Retained: isRetained(resourceMap[name])}
+		if _, t, _ := s11n.GetMapValue(resourceMap[name], "Type"); t != nil {
+			rd.Type = t.Value
+		}
+		rd.Identifier = modelIdentifier(template, name, rd.Type, schemas)
+		if rd.Identifier == "" {
+			rd.Identifier = modelIdentifier(against, name, rd.Type, schemas)
+		}
+
+		switch {
+		case !inNew:
+			rd.Warning = "only in the state file it is compared to"
+			newModel = map[string]any{}
+		case !inOld:
+			rd.Warning = "not in the state file it is compared to"
+			oldModel = map[string]any{}
+		}
+
+		rd.scope = opts.Scope.paths(name, rd.Type)
+		d := compareModels(scopeModel(oldModel, rd.scope), scopeModel(newModel, rd.scope), opts)
+		rd.diff = d
+		rd.Drifted = d.Mode() != diff.Unchanged || rd.Warning != ""
+		rd.DriftRatio = diff.DriftRatio(d)
+		rd.differ, rd.compared = diff.LeafCounts(d)
+		rd.Differences = newPropertyDiffs(d, scopeModel(oldModel, rd.scope))
+		rd.liveModel = newModel
+		rd.stateModel = oldModel
+		results = append(results, rd)
+	}
+	classifyAll(results, opts.Severity)
+
+	return results, nil
+}
+
+// modelIdentifier returns the Identifier stored for a resource in a state file,
+// with the parts of a composite identifier joined in the order of the schema
+// for the type the model was stored with, or typeName if it has none.
+// It returns an empty string if the identifier can't be put in that order.
+func modelIdentifier(template cft.Template, name string, typeName string, schemas *schemaCache) string {
+	resourceModels, err := template.GetNode(cft.State, "ResourceModels")
+	if err != nil {
+		return ""
+	}
+	_, model, _ := s11n.GetMapValue(resourceModels, name)
+	if model == nil {
+		return ""
+	}
+	_, id, _ := s11n.GetMapValue(model, "Identifier")
+	if id == nil {
+		return ""
+	}
+	if _, storedType, _ := s11n.GetMapValue(model, "Type"); storedType != nil && storedType.Value != "" {
+		typeName = storedType.Value
+	}
+	joined, err := resourceIdentifier(typeName, id, schemas)
+	if err != nil {
+		config.Debugf("unable to show the identifier of %s: %v", name, err)
+		return ""
+	}
+	return joined
+}
+
+// printStateDiff shows how the stored model of a resource differs
+// between the state file it is compared to and the current one
+func printStateDiff(w io.Writer, rd *ResourceDrift) {
+	if !rd.Drifted {
+		printOk(w, rd)
+		return
+	}
+
+	printResourceDiff(w, rd, diffLabels{
+		status:     "Changed!",
+		old:        "Compared to",
+		new:        "Current",
+		newHeading: "Current",
+	})
+	fmt.Fprintln(w)
+}
+
+// runAgainst compares the state file of the named deployment to the one in --against.
+// With --output json or yaml, it returns a report instead of printing the differences.
+func runAgainst(w io.Writer, name string, template cft.Template) (bool, *DriftReport, error) {
+	opts := driftOptions()
+
+	against, err := loadAgainst(driftAgainst, opts)
+	if err != nil {
+		return false, nil, err
+	}
+
+	results, err := compareStates(template, against, opts)
+	if err != nil {
+		return false, nil, err
+	}
+
+	driftHighestSeverity = maxSeverity(driftHighestSeverity, highestSeverity(results))
+
+	report := &DriftReport{Name: name, Resources: results, DriftRatio: driftRatio(results), Processed: len(results)}
+	if resources, err := template.GetSection(cft.Resources); err == nil {
+		report.Total = len(resources.Content) / 2
+	}
+	if reportOutput() {
+		return report.HasDrift(), report, nil
+	}
+
+	fmt.Fprint(w, console.Blue("Compared to:      "))
+	fmt.Fprint(w, console.Cyan(fmt.Sprintf("%s\n", driftAgainst)))
+	fmt.Fprintln(w)
+
+	if driftSummary {
+		return printDriftSummary(w, results), nil, nil
+	}
+
+	changed := 0
+	for _, rd := range results {
+		printStateDiff(w, rd)
+		if rd.Drifted {
+			changed++
+		}
+	}
+	if driftDiffOnly {
+		fmt.Fprintf(w, "%d/%d resources Ok\n", okCount(results), len(results))
+	} else {
+		fmt.Fprintf(w, "Compared %d resources, %d changed\n", len(results), changed)
+	}
+
+	return changed > 0, nil, nil
+}
@@ -0,0 +1,165 @@
+package cc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/console"
+)
+
+func TestCompareStates(t *testing.T) {
+	current, err := parse.String(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Queue:
+    Type: AWS::SQS::Queue
+State:
+  ResourceModels:
+    Bucket:
+      Identifier: b
+      Model:
+        BucketName: b
+        Versioning: Enabled
+    Queue:
+      Identifier: q
+      Model:
+        QueueName: q
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := parse.String(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Topic:
+    Type: AWS::SNS::Topic
+State:
+  ResourceModels:
+    Bucket:
+      Identifier: b
+      Model:
+        BucketName: b
+        Versioning: Suspended
+    Topic:
+      Identifier: t
+      Model:
+        TopicName: t
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := compareStates(current, backup, DriftOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		name    string
+		typ     string
+		drifted bool
+		warning bool
+	}{
+		{"Bucket", "AWS::S3::Bucket", true, false},
+		{"Queue", "AWS::SQS::Queue", true, true},
+		{"Topic", "AWS::SNS::Topic", true, true},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, e := range expected {
+		rd := results[i]
+		if rd.Name != e.name || rd.Type != e.typ || rd.Drifted != e.drifted || (rd.Warning != "") != e.warning {
+			t.Errorf("unexpected result %d: %+v", i, rd)
+		}
+	}
+	if len(results[0].Differences) != 1 || results[0].Differences[0].Path != "Versioning" {
+		t.Errorf("unexpected differences: %+v", results[0].Differences)
+	}
+
+	// A state file compared to itself has no changes
+	results, err = compareStates(current, current, DriftOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rd := range results {
+		if rd.Drifted {
+			t.Errorf("expected %s to be unchanged", rd.Name)
+		}
+	}
+}
+
+func TestModelIdentifier(t *testing.T) {
+	template, err := parse.String(`
+Resources:
+  Service:
+    Type: AWS::ECS::Service
+State:
+  ResourceModels:
+    Service:
+      Identifier:
+        Cluster: my-cluster
+        ServiceArn: arn:aws:ecs:service
+      Model: {}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas := &schemaCache{schemas: map[string]*typeSchema{
+		"AWS::ECS::Service": {PrimaryIdentifier: []string{"ServiceArn", "Cluster"}},
+	}}
+
+	expected := "arn:aws:ecs:service|my-cluster"
+	if actual := modelIdentifier(template, "Service", "AWS::ECS::Service", schemas); actual != expected {
+		t.Errorf("%q != %q", actual, expected)
+	}
+}
+
+func TestPrintStateDiff(t *testing.T) {
+	defer func(n bool, d bool) { console.NoColour, driftDiffOnly = n, d }(console.NoColour, driftDiffOnly)
+	console.NoColour = true
+
+	old := map[string]any{"QueueName": "a"}
+	current := map[string]any{"QueueName": "b"}
+	rd := &ResourceDrift{Name: "Queue", Type: "AWS::SQS::Queue", Identifier: "q", Drifted: true,
+		diff: diff.CompareMaps(old, current), stateModel: old, liveModel: current}
+
+	buf := &bytes.Buffer{}
+	printStateDiff(buf, rd)
+	for _, want := range []string{"Queue", "Changed!", "1 properties differ", "========== Current =========="} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected the diff to contain %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "Stored state") {
+		t.Errorf("expected no stored state diff:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	printResourceDiff(buf, rd, diffLabels{status: "Drift detected!", old: "Stored", new: "Live",
+		oldHeading: "Stored state", newHeading: "Live state"})
+	for _, want := range []string{"Drift detected!", "========== Live state ==========", "========== Stored state =========="} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected the diff to contain %q:\n%s", want, buf.String())
+		}
+	}
+
+	unchanged := &ResourceDrift{Name: "Queue", Type: "AWS::SQS::Queue", Identifier: "q"}
+	buf.Reset()
+	driftDiffOnly = true
+	printStateDiff(buf, unchanged)
+	if buf.Len() != 0 {
+		t.Errorf("expected no output with --diff-only, got %q", buf.String())
+	}
+	driftDiffOnly = false
+	printStateDiff(buf, unchanged)
+	if !strings.Contains(buf.String(), "Ok!") {
+		t.Errorf("expected an Ok line, got %q", buf.String())
+	}
+}
@@ -0,0 +1,198 @@
+package cc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/internal/aws/ccapi"
+	"github.com/aws-cloudformation/rain/internal/aws/s3"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/console/spinner"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// driftBootstrap is set by the --bootstrap flag on cc drift
+var driftBootstrap bool
+
+// bootstrapModel is the live state found for a resource by --bootstrap.
+// Identifier is empty for a resource that could not be found.
+type bootstrapModel struct {
+	Name       string
+	Type       string
+	Identifier string
+	Model      map[string]any
+}
+
+// bootstrapIdentifier returns the identifier of a resource that has no state,
+// made from the properties in its template that are its primary identifier
+func bootstrapIdentifier(resource *yaml.Node, schema *typeSchema) (string, error) {
+	if len(schema.PrimaryIdentifier) == 0 {
+		return "", fmt.Errorf("its schema has no primaryIdentifier")
+	}
+
+	_, props, _ := s11n.GetMapValue(resource, "Properties")
+	parts := make([]string, 0)
+	for _, name := range schema.PrimaryIdentifier {
+		var part *yaml.Node
+		if props != nil {
+			_, part, _ = s11n.GetMapValue(props, name)
+		}
+		if part == nil {
+			return "", fmt.Errorf("%s is not set in its Properties", name)
+		}
+		if part.Kind != yaml.ScalarNode {
+			return "", fmt.Errorf("%s is not a plain value (line %d)", name, part.Line)
+		}
+		parts = append(parts, part.Value)
+	}
+
+	return ccapi.JoinIdentifier(parts), nil
+}
+
+// addBootstrapState adds a State section to template with the models
+// that were found, in the same shape that a deployment writes it.
+// The resources that have no model are written without an Identifier,
+// so that drift reports them as incomplete.
+func addBootstrapState(template cft.Template, models []bootstrapModel, filePath string, now time.Time) error {
+	stateMap := cft.AppendStateMap(template)
+	node.Add(stateMap, "LastWriteTime", now.Format(time.RFC3339))
+	addCommon(stateMap, filePath)
+	resourceModels := node.AddMap(stateMap, "ResourceModels")
+
+	for _, m := range models {
+		resourceStateMap := node.AddMap(resourceModels, m.Name)
+		node.Add(resourceStateMap, "Identifier", m.Identifier)
+		if m.Type != "" {
+			node.Add(resourceStateMap, "Type", m.Type)
+		}
+		modelMap := node.AddMap(resourceStateMap, "Model")
+		if m.Model == nil {
+			continue
+		}
+		var n yaml.Node
+		if err := n.Encode(m.Model); err != nil {
+			return fmt.Errorf("unable to encode the model of %s: %v", m.Name, err)
+		}
+		modelMap.Content = append(modelMap.Content, n.Content...)
+	}
+
+	return nil
+}
+
+// runBootstrap writes a State section to a state file that has none,
+// from the live state of the resources that can be found
+func runBootstrap(w io.Writer, name string, opts DriftOptions) error {
+	template, src, err := readState(name, opts)
+	if err != nil {
+		return err
+	}
+
+	resources, err := template.GetSection(cft.Resources)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMissingSection, err)
+	}
+
+	schemas := opts.schemas
+	if schemas == nil {
+		schemas = newSchemaCache()
+	}
+
+	fmt.Fprintln(w, console.Yellow(fmt.Sprintf("The state file for %s has no State section, querying live state to bootstrap it", name)))
+	fmt.Fprintln(w)
+
+	models := make([]bootstrapModel, 0)
+	skipped := 0
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		resourceName := resources.Content[i].Value
+		m, err := bootstrapResource(resourceName, resources.Content[i+1], opts, schemas)
+		if err != nil {
+			fmt.Fprintln(w, console.Yellow(fmt.Sprintf("    %s: skipped, %v", resourceName, err)))
+			models = append(models, bootstrapModel{Name: resourceName})
+			skipped++
+			continue
+		}
+		fmt.Fprintln(w, console.Green(fmt.Sprintf("    %s: %s", resourceName, m.Identifier)))
+		models = append(models, *m)
+	}
+	fmt.Fprintln(w)
+
+	found := len(models) - skipped
+	if found == 0 {
+		return fmt.Errorf("unable to find any of the resources in %s to bootstrap its state", name)
+	}
+
+	prompt := fmt.Sprintf("Write a State section with %d resources (%d skipped)?", found, skipped)
+	if !yes && !console.Confirm(true, prompt) {
+		fmt.Fprintln(w, "Bootstrap cancelled. No changes have been made to the state file")
+		return nil
+	}
+
+	if err := addBootstrapState(template, models, "", time.Now()); err != nil {
+		return err
+	}
+
+	str := format.String(template, format.Options{JSON: false, Unsorted: false})
+	body, err := encodeState(str, src.compressed || compressState)
+	if err == nil && opts.StateFile != "" {
+		err = os.WriteFile(opts.StateFile, body, 0644)
+	} else if err == nil {
+		err = s3.PutObjectWithChecksum(src.bucket, src.key, body)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write bootstrapped state file: %v", err)
+	}
+
+	fmt.Fprintf(w, "State file bootstrapped with %d resources\n", found)
+	return nil
+}
+
+// bootstrapResource reads the live model of a resource that has no state
+func bootstrapResource(resourceName string, resource *yaml.Node, opts DriftOptions, schemas *schemaCache) (*bootstrapModel, error) {
+	_, t, _ := s11n.GetMapValue(resource, "Type")
+	if t == nil {
+		return nil, fmt.Errorf("it has no Type")
+	}
+
+	schema, err := schemas.get(t.Value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load schema for %s: %v", t.Value, err)
+	}
+
+	id, err := bootstrapIdentifier(resource, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	opts.traceCCAPI("GetResource request for %s: TypeName=%s Identifier=%s", resourceName, t.Value, id)
+	spinner.Push(fmt.Sprintf("Querying %s", resourceName))
+	live, err := ccapi.GetResourceWithOptions(ctx, id, t.Value, opts.readOptions())
+	spinner.Pop()
+	if ccapi.IsNotFound(err) {
+		return nil, fmt.Errorf("Cloud Control API could not find %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	opts.traceCCAPI("GetResource response for %s: %s", resourceName, live.Properties)
+
+	model, err := parseLiveModel(t.Value, id, live.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bootstrapModel{Name: resourceName, Type: t.Value, Identifier: id, Model: model}, nil
+}
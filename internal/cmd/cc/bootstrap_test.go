@@ -0,0 +1,73 @@
+package cc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+)
+
+func TestBootstrap(t *testing.T) {
+	template, err := parse.String(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: my-bucket
+  Named:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Sub ${AWS::StackName}-bucket
+  Unnamed:
+    Type: AWS::S3::Bucket
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validateState(template); !errors.Is(err, ErrMissingState) {
+		t.Errorf("expected ErrMissingState, got %v", err)
+	}
+
+	resources, err := template.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := &typeSchema{PrimaryIdentifier: []string{"BucketName"}}
+
+	if id, err := bootstrapIdentifier(resources["Bucket"], schema); err != nil || id != "my-bucket" {
+		t.Errorf("expected my-bucket, got %q, %v", id, err)
+	}
+	for _, name := range []string{"Named", "Unnamed"} {
+		if id, err := bootstrapIdentifier(resources[name], schema); err == nil {
+			t.Errorf("%s: expected an error, got %q", name, id)
+		}
+	}
+
+	models := []bootstrapModel{
+		{Name: "Bucket", Type: "AWS::S3::Bucket", Identifier: "my-bucket", Model: map[string]any{"BucketName": "my-bucket"}},
+		{Name: "Named"},
+		{Name: "Unnamed"},
+	}
+	now := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := addBootstrapState(template, models, "", now); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validateState(template); err != nil {
+		t.Errorf("expected the bootstrapped state to be valid, got %v", err)
+	}
+	if id := modelIdentifier(template, "Bucket", "AWS::S3::Bucket", nil); id != "my-bucket" {
+		t.Errorf("expected my-bucket, got %q", id)
+	}
+	if typeName, _ := template.GetStringValue("State", "ResourceModels", "Bucket", "Type"); typeName != "AWS::S3::Bucket" {
+		t.Errorf("expected the type to be stored with the model, got %q", typeName)
+	}
+	if id := modelIdentifier(template, "Named", "AWS::S3::Bucket", nil); id != "" {
+		t.Errorf("expected no identifier for a skipped resource, got %q", id)
+	}
+	if lastWrite, _ := template.GetStringValue("State", "LastWriteTime"); lastWrite != "2024-02-03T04:05:06Z" {
+		t.Errorf("unexpected LastWriteTime %q", lastWrite)
+	}
+}
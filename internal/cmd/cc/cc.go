@@ -4,6 +4,7 @@ import (
 	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/internal/aws/s3"
 	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/console/spinner"
 	"github.com/aws-cloudformation/rain/plugins/deployconfig"
 	"github.com/spf13/cobra"
 )
@@ -35,7 +36,10 @@ func addCommonParams(c *cobra.Command) {
 
 	c.Flags().StringVar(&s3.BucketName, "s3-bucket", "", "Name of the S3 bucket that is used to upload assets")
 	c.Flags().StringVar(&s3.BucketKeyPrefix, "s3-prefix", "", "Prefix to add to objects uploaded to S3 bucket")
+	c.Flags().StringVar(&s3.KMSKeyId, "kms-key-id", "", "KMS key used to encrypt the state file and drift history when they are written to the S3 bucket")
+	c.Flags().BoolVar(&compressState, "compress-state", false, "Write the state file to the S3 bucket gzip-compressed")
 	c.Flags().BoolVar(&config.Debug, "debug", false, "Output debugging information")
+	c.Flags().BoolVar(&spinner.NoSpinner, "no-spinner", false, "Don't show progress spinners, e.g. when the output is captured in CI logs")
 	c.Flags().BoolVarP(&Experimental, "experimental", "x", false, "Acknowledge that this is an experimental feature")
 }
 
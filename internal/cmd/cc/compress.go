@@ -0,0 +1,72 @@
+package cc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// compressState is set by the --compress-state flag on cc commands
+var compressState bool
+
+// gzipMagic are the first bytes of a gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompressState returns the YAML in a state file that was downloaded
+// or read from disk. State files can be stored gzip-compressed, which is
+// detected from the content, so uncompressed state files are returned as they are.
+func decompressState(obj []byte) (body []byte, compressed bool, err error) {
+	if !bytes.HasPrefix(obj, gzipMagic) {
+		return obj, false, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(obj))
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to decompress state file: %v", err)
+	}
+	defer r.Close()
+
+	body, err = io.ReadAll(r)
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to decompress state file: %v", err)
+	}
+
+	return body, true, nil
+}
+
+// decompressReader is like decompressState for a state file that is
+// parsed as it is read. The caller must close the returned reader.
+func decompressReader(r io.Reader) (io.ReadCloser, bool, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		return io.NopCloser(br), false, nil
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to decompress state file: %v", err)
+	}
+
+	return gz, true, nil
+}
+
+// encodeState returns the bytes to store for a state file,
+// gzip-compressed if compress is true
+func encodeState(str string, compress bool) ([]byte, error) {
+	if !compress {
+		return []byte(str), nil
+	}
+
+	buf := bytes.Buffer{}
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(str)); err != nil {
+		return nil, fmt.Errorf("unable to compress state file: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("unable to compress state file: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
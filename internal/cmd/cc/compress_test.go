@@ -0,0 +1,87 @@
+package cc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressState(t *testing.T) {
+	state := "Resources: {}\nState:\n  Lock: abc\n"
+
+	for _, compress := range []bool{false, true} {
+		obj, err := encodeState(state, compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, compressed, err := decompressState(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed != compress {
+			t.Errorf("expected compressed to be %v", compress)
+		}
+		if string(body) != state {
+			t.Errorf("%#v\n!=\n%#v\n", string(body), state)
+		}
+	}
+
+	if _, _, err := decompressState([]byte{0x1f, 0x8b, 0x00}); err == nil {
+		t.Errorf("expected an error for a truncated gzip stream")
+	}
+}
+
+func TestReadStateStreamed(t *testing.T) {
+	defer func(n int64) { streamThreshold = n }(streamThreshold)
+
+	state := "Resources: {}\nState:\n  FilePath: a.yaml\n  LastWriteTime: \"2024-01-02T03:04:05Z\"\n  ResourceModels: {}\n"
+	fileName := filepath.Join(t.TempDir(), "state.yaml")
+
+	for _, threshold := range []int64{8 << 20, 0} {
+		streamThreshold = threshold
+		for _, compress := range []bool{false, true} {
+			obj, err := encodeState(state, compress)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(fileName, obj, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			template, src, err := loadState("test", DriftOptions{StateFile: fileName})
+			if err != nil {
+				t.Fatalf("threshold %d, compressed %v: %v", threshold, compress, err)
+			}
+			if src.compressed != compress {
+				t.Errorf("threshold %d: expected compressed to be %v", threshold, compress)
+			}
+			if p, _ := template.GetStringValue("State", "FilePath"); p != "a.yaml" {
+				t.Errorf("threshold %d, compressed %v: unexpected FilePath %q", threshold, compress, p)
+			}
+		}
+	}
+}
+
+func TestReadStateStreamedTruncated(t *testing.T) {
+	defer func(n int64) { streamThreshold = n }(streamThreshold)
+	streamThreshold = 0
+
+	state := "Resources: {}\nState:\n  FilePath: a.yaml\n  LastWriteTime: \"2024-01-02T03:04:05Z\"\n  ResourceModels: {}\n"
+	// The decoder stops after the first document, so the rest of the stream
+	// is only read when it is drained
+	obj, err := encodeState(state+"---\n"+strings.Repeat("- padding\n", 100000), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The YAML is complete, but the gzip trailer is missing
+	fileName := filepath.Join(t.TempDir(), "state.yaml")
+	if err := os.WriteFile(fileName, obj[:len(obj)-8], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadState("test", DriftOptions{StateFile: fileName}); err == nil {
+		t.Errorf("expected an error for a truncated gzip stream")
+	}
+}
@@ -0,0 +1,136 @@
+package cc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// actionNames are how each action is written in a --decisions file
+var actionNames = map[action]string{
+	changeLiveState: "live-state",
+	changeStateFile: "state-file",
+	doNothing:       "nothing",
+}
+
+// decision is the choice that was made for a drifted resource
+type decision struct {
+	Deployment string `json:"deployment"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Action     string `json:"action"`
+}
+
+// decisionFile is the contents of a --decisions file
+type decisionFile struct {
+	Decisions []decision `json:"decisions"`
+}
+
+// decisionSet holds the choices read from, or to be written to, a --decisions file.
+// Resources are matched by deployment, logical id, and type, so that a decision
+// still applies after the resource's identifier or properties change, but not
+// to a different resource that reuses the name, in the same deployment or,
+// with --all, in another one.
+type decisionSet struct {
+	// replay is true if the file existed, in which case its decisions are
+	// used instead of asking, and it is not changed
+	replay bool
+
+	decisions map[string]decision
+}
+
+// decisionKey returns the key a resource's decision is stored under
+func decisionKey(deployment string, name string, typeName string) string {
+	return deployment + " " + name + " " + typeName
+}
+
+// loadDecisions reads a --decisions file. If it does not exist,
+// the decisions that are made are recorded so they can be saved to it.
+func loadDecisions(fileName string) (*decisionSet, error) {
+	d := &decisionSet{decisions: make(map[string]decision)}
+
+	f, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read decisions %s: %v", fileName, err)
+	}
+
+	var file decisionFile
+	if err := json.Unmarshal(f, &file); err != nil {
+		return nil, fmt.Errorf("unable to parse decisions %s: %v", fileName, err)
+	}
+
+	for _, dec := range file.Decisions {
+		if _, ok := parseAction(dec.Action); !ok {
+			return nil, fmt.Errorf("%s: unknown action '%s' for %s", fileName, dec.Action, dec.Name)
+		}
+		d.decisions[decisionKey(dec.Deployment, dec.Name, dec.Type)] = dec
+	}
+	d.replay = true
+
+	return d, nil
+}
+
+// parseAction returns the action written as name in a --decisions file
+func parseAction(name string) (action, bool) {
+	for a, n := range actionNames {
+		if n == name {
+			return a, true
+		}
+	}
+	return doNothing, false
+}
+
+// lookup returns the decision that was made for a resource when decisions
+// are being replayed. ok is false if the user should be asked instead.
+// A resource with no decision in the file is left as it is.
+func (d *decisionSet) lookup(deployment string, name string, typeName string) (a action, recorded bool, ok bool) {
+	if d == nil || !d.replay {
+		return doNothing, false, false
+	}
+	dec, recorded := d.decisions[decisionKey(deployment, name, typeName)]
+	if !recorded {
+		return doNothing, false, true
+	}
+	a, _ = parseAction(dec.Action)
+	return a, true, true
+}
+
+// record keeps the decision that was made for a resource, so it can be saved
+func (d *decisionSet) record(deployment string, name string, typeName string, a action) {
+	if d == nil || d.replay {
+		return
+	}
+	d.decisions[decisionKey(deployment, name, typeName)] = decision{
+		Deployment: deployment, Name: name, Type: typeName, Action: actionNames[a]}
+}
+
+// save writes the recorded decisions to fileName.
+// Nothing is written if the decisions were replayed from it.
+func (d *decisionSet) save(fileName string) error {
+	if d == nil || d.replay {
+		return nil
+	}
+
+	file := decisionFile{Decisions: make([]decision, 0, len(d.decisions))}
+	for _, dec := range d.decisions {
+		file.Decisions = append(file.Decisions, dec)
+	}
+	sort.Slice(file.Decisions, func(i, j int) bool {
+		a, b := file.Decisions[i], file.Decisions[j]
+		return decisionKey(a.Deployment, a.Name, a.Type) < decisionKey(b.Deployment, b.Name, b.Type)
+	})
+
+	out, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fileName, append(out, '\n'), 0644); err != nil {
+		return fmt.Errorf("unable to write decisions %s: %v", fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package cc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecisions(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "decisions.json")
+
+	// The first run records the choices
+	d, err := loadDecisions(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := d.lookup("app", "Bucket", "AWS::S3::Bucket"); ok {
+		t.Errorf("expected to be asked when there is no decisions file")
+	}
+	d.record("app", "Bucket", "AWS::S3::Bucket", changeStateFile)
+	d.record("app", "Role", "AWS::IAM::Role", changeLiveState)
+	d.record("other", "Bucket", "AWS::S3::Bucket", changeLiveState)
+	if err := d.save(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next run makes them again
+	d, err = loadDecisions(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		deployment string
+		name       string
+		typeName   string
+		expected   action
+		recorded   bool
+	}{
+		{"app", "Bucket", "AWS::S3::Bucket", changeStateFile, true},
+		{"app", "Role", "AWS::IAM::Role", changeLiveState, true},
+		{"other", "Bucket", "AWS::S3::Bucket", changeLiveState, true},
+		{"other", "Role", "AWS::IAM::Role", doNothing, false},
+		{"app", "Bucket", "AWS::SQS::Queue", doNothing, false},
+		{"app", "Queue", "AWS::SQS::Queue", doNothing, false},
+	}
+	for _, c := range cases {
+		a, recorded, ok := d.lookup(c.deployment, c.name, c.typeName)
+		if !ok || a != c.expected || recorded != c.recorded {
+			t.Errorf("%s %s %s: %v %v %v", c.deployment, c.name, c.typeName, a, recorded, ok)
+		}
+	}
+
+	// Replayed decisions are not written back
+	d.record("app", "Queue", "AWS::SQS::Queue", changeLiveState)
+	if err := os.Remove(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.save(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Errorf("expected replayed decisions not to be saved")
+	}
+
+	// No decisions file means nothing is recorded
+	var none *decisionSet
+	none.record("app", "Bucket", "AWS::S3::Bucket", changeStateFile)
+	if _, _, ok := none.lookup("app", "Bucket", "AWS::S3::Bucket"); ok {
+		t.Errorf("expected to be asked without --decisions")
+	}
+}
+
+func TestDecisionsUnknownAction(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "decisions.json")
+	err := os.WriteFile(fileName, []byte(`{"decisions": [{"deployment": "app", "name": "Bucket", "type": "AWS::S3::Bucket", "action": "delete"}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadDecisions(fileName); err == nil {
+		t.Errorf("expected an error for an unknown action")
+	}
+}
@@ -3,7 +3,9 @@ package cc
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/cft/format"
@@ -50,6 +52,12 @@ func deploy(cmd *cobra.Command, args []string) {
 	template := PackageTemplate(fn, true)
 	spinner.Pop()
 
+	// Transforms are stored as they are written, since only CloudFormation runs them
+	if transforms := templateTransforms(template); len(transforms) > 0 {
+		fmt.Fprintln(os.Stderr, console.Yellow(fmt.Sprintf("WARNING: %s uses transforms that rain does not run (%s), so they are deployed and stored as written",
+			base, strings.Join(transforms, ", "))))
+	}
+
 	// Get parameters and tags
 	stack := types.Stack{} // Not relevant here
 	stack.Parameters = make([]types.Parameter, 0)
@@ -116,7 +124,7 @@ func deploy(cmd *cobra.Command, args []string) {
 				panic(fmt.Errorf("unable to remove state file: %v", err))
 			}
 		} else {
-			err := writeState(template, nil, bucketName, name, absPath)
+			err := writeState(template, nil, bucketName, name, absPath, stateResult.Compressed)
 			if err != nil {
 				panic(fmt.Errorf("unable to unlock state file: %v", err))
 			}
@@ -152,7 +160,7 @@ func deploy(cmd *cobra.Command, args []string) {
 		fmt.Println("Deployment completed successfully!")
 
 		// Unlock the state file and record current values
-		err := writeState(template, results, bucketName, name, absPath)
+		err := writeState(template, results, bucketName, name, absPath, stateResult.Compressed)
 		if err != nil {
 			panic(fmt.Errorf("unable to write state file: %v", err))
 		}
@@ -165,6 +173,8 @@ var CCDeployCmd = &cobra.Command{
 	Short: "Deploy a local template directly using the Cloud Control API (Experimental!)",
 	Long: `Creates or updates resources directly using Cloud Control API from the template file <template>.
 You must pass the --experimental (-x) flag to use this command, to acknowledge that it is experimental and likely to be unstable!
+
+Transforms, like AWS::Serverless-2016-10-31 or Fn::Transform, are only run by CloudFormation. A template that uses them is deployed as it is written, with a warning.
 `,
 	Args:                  cobra.ExactArgs(2),
 	DisableFlagsInUseLine: true,
@@ -0,0 +1,447 @@
+package cc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/aws/ccapi"
+	"github.com/aws-cloudformation/rain/internal/aws/s3"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/console/spinner"
+)
+
+// DriftOptions controls what DetectDrift checks and how.
+// The zero value checks every resource in the deployment's state file
+// in the rain bucket, one at a time and without retries, matching tags by their Key.
+type DriftOptions struct {
+	// StateFile is a local state file to read instead of the one in the rain bucket
+	StateFile string
+
+	// Bucket is the bucket that holds the state file, if it is not the rain bucket
+	Bucket string
+
+	// Prefix replaces the deployments/ folder that state files are stored under
+	Prefix string
+
+	// Resources are the logical ids of the resources to check. All resources are checked if it is empty.
+	Resources []string
+
+	// IncludeTypes limits the check to resources of these types, if it is set
+	IncludeTypes []string
+
+	// ExcludeTypes are resource types that are not checked
+	ExcludeTypes []string
+
+	// SkipRetained leaves out resources with a DeletionPolicy of Retain or
+	// RetainExceptOnCreate. They are checked, and marked as retained, otherwise.
+	SkipRetained bool
+
+	// Ignore are property paths, like Tags/0/Value, that are left out of the comparison
+	Ignore []string
+
+	// IdentityKeys maps a property path to the key that identifies the elements
+	// of the list at that path. If it is nil, tags are matched by their Key.
+	IdentityKeys map[string]string
+
+	// StoredKeysOnly only compares the map keys that are in the stored model,
+	// so that properties that are only in the live model are not drift
+	StoredKeysOnly bool
+
+	// IgnoreValueCase treats strings that only differ by case as unchanged
+	IgnoreValueCase bool
+
+	// FilterTags limits the results to resources whose live model has all of
+	// these tags. The tags are only known once each resource is queried, so
+	// this does not reduce the number of requests.
+	FilterTags map[string]string
+
+	// ManagedTagPrefixes are the tag key prefixes, like aws:, of tags that
+	// are left out of the live model before it is compared. Tags are not
+	// filtered if it is empty.
+	ManagedTagPrefixes []string
+
+	// PseudoParameters are the values of the pseudo parameters, like
+	// AWS::Region, that stored models refer to. If it is nil, they are
+	// the values for the current session.
+	PseudoParameters *cft.PseudoParameters
+
+	// Scope limits the comparison of some resources to a few property paths.
+	// The whole models are compared if it is nil.
+	Scope *ScopeConfig
+
+	// Severity rates the drift of each property. Drift is not rated if it is nil.
+	Severity *SeverityConfig
+
+	// DetectOrphans also lists live resources that are not in the state file
+	DetectOrphans bool
+
+	// Concurrency is the maximum number of resources to query at once
+	Concurrency int
+
+	// Timeout limits how long to wait for the live state of each resource.
+	// There is no limit if it is zero.
+	Timeout time.Duration
+
+	// RoleArn is a role to assume to read live state, for example in another
+	// account than the one with the rain bucket. The state file is read with
+	// the current credentials.
+	RoleArn string
+
+	// ExternalID is passed when assuming RoleArn, if it is set
+	ExternalID string
+
+	// AccountId is the AWS::AccountId that stored models refer to. If it is
+	// empty, it is the account of RoleArn, or of the current credentials.
+	AccountId string
+
+	// Limiter spaces out Cloud Control API requests. There is no limit if it is nil.
+	Limiter *ccapi.RateLimiter
+
+	// MaxRetries is how many times a throttled Cloud Control API query is retried
+	MaxRetries int
+
+	// Verbose writes each Cloud Control API request and response to stderr
+	Verbose bool
+
+	// CreateBucket creates the rain bucket if it does not exist.
+	// Otherwise a missing rain bucket is an error.
+	CreateBucket bool
+
+	// NoVerify skips checking the state file in the bucket against the
+	// checksum that was stored with it when it was written
+	NoVerify bool
+
+	// schemas are the resource type schemas loaded by earlier checks,
+	// so that --watch doesn't load them again. A new cache is used if it is nil.
+	schemas *schemaCache
+}
+
+// defaultIdentityKeys are used when DriftOptions.IdentityKeys is nil
+var defaultIdentityKeys = map[string]string{"Tags": "Key"}
+
+// driftOptions returns the options set by the cc drift flags
+func driftOptions() DriftOptions {
+	return DriftOptions{
+		StateFile:          driftStateFile,
+		Bucket:             driftBucket,
+		Prefix:             driftPrefix,
+		Resources:          driftResources,
+		IncludeTypes:       driftIncludeTypes,
+		ExcludeTypes:       driftExcludeTypes,
+		SkipRetained:       driftSkipRetained,
+		Ignore:             driftIgnore,
+		IdentityKeys:       driftIdentityKeys,
+		IgnoreValueCase:    driftIgnoreValueCase,
+		StoredKeysOnly:     driftStoredKeysOnly,
+		DetectOrphans:      driftDetectOrphans,
+		FilterTags:         driftFilterTags,
+		Scope:              driftScope,
+		Severity:           driftSeverity,
+		ManagedTagPrefixes: managedTagPrefixes(),
+		Concurrency:        driftConcurrency,
+		Timeout:            driftTimeout,
+		CreateBucket:       driftCreateBucket,
+		NoVerify:           driftNoVerify,
+		RoleArn:            ccapi.RoleArn,
+		ExternalID:         ccapi.ExternalID,
+		AccountId:          driftAccountId,
+		Limiter:            driftLimiter,
+		MaxRetries:         ccapi.MaxRetries,
+		Verbose:            driftVerbose,
+	}
+}
+
+// readOptions returns the settings for reading live state with opts
+func (opts DriftOptions) readOptions() ccapi.ReadOptions {
+	return ccapi.ReadOptions{
+		RoleArn:    opts.RoleArn,
+		ExternalID: opts.ExternalID,
+		Limiter:    opts.Limiter,
+		MaxRetries: opts.MaxRetries,
+	}
+}
+
+// compareModels compares a resource's old and new models,
+// with the ignored paths, identity keys, and case rule in opts
+func compareModels(old, new map[string]any, opts DriftOptions) diff.Diff {
+	compareOpts := []diff.CompareOption{diff.Ignore(opts.Ignore...), diff.IdentityKeys(opts.IdentityKeys)}
+	if opts.IgnoreValueCase {
+		compareOpts = append(compareOpts, diff.IgnoreCase())
+	}
+	return diff.CompareMaps(old, new, compareOpts...)
+}
+
+// managedTagPrefixes returns the prefixes set by --managed-tag-prefix
+// if --ignore-managed-tags is set
+func managedTagPrefixes() []string {
+	if !driftIgnoreManagedTags {
+		return nil
+	}
+	return driftManagedTagPrefixes
+}
+
+// DetectDrift compares the state file of the named deployment to the live
+// state of its resources and returns the results. Nothing is printed,
+// apart from the spinner, which callers can turn off with spinner.Disable,
+// and nothing is changed.
+func DetectDrift(name string, opts DriftOptions) (*DriftReport, error) {
+	template, _, err := loadState(name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := driftReport(name, template, opts)
+	if err != nil {
+		return nil, err
+	}
+	report.setSession(opts)
+
+	return report, nil
+}
+
+// stateBucket returns bucket, or the rain bucket if bucket is empty.
+// The rain bucket is only created if create is true, since there
+// can't be any state files to check in a bucket that didn't exist.
+func stateBucket(bucket string, create bool) (string, error) {
+	if bucket != "" {
+		return bucket, nil
+	}
+	if create {
+		return s3.RainBucket(true), nil
+	}
+
+	bucketName, err := s3.FindRainBucket()
+	if errors.Is(err, s3.ErrRainBucketNotFound) {
+		return "", fmt.Errorf("%w; deploy something with rain cc deploy first, or pass --bucket", err)
+	}
+	return bucketName, err
+}
+
+// stateKey returns the object key of the named deployment's state file.
+// The key is under prefix, or under the rain deployments folder if prefix is empty.
+func stateKey(name string, prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return getStateFileKey(name)
+	}
+	return fmt.Sprintf("%s/%s.yaml", prefix, name)
+}
+
+// stateSource is where a state file was read from,
+// so that changes can be written back the same way
+type stateSource struct {
+	// bucket and key are empty for a local --state-file
+	bucket string
+	key    string
+
+	// compressed is true if the state file was gzip-compressed
+	compressed bool
+}
+
+// loadState reads the state file for the named deployment from opts.StateFile,
+// or from the bucket and prefix in opts if it is empty.
+// Compressed state files are decompressed.
+func loadState(name string, opts DriftOptions) (cft.Template, stateSource, error) {
+	template, src, err := readState(name, opts)
+	if err != nil {
+		return cft.Template{}, stateSource{}, err
+	}
+
+	if err := validateState(template); err != nil {
+		return cft.Template{}, stateSource{}, err
+	}
+
+	return template, src, nil
+}
+
+// streamThreshold is the size in bytes above which a state file is parsed as
+// it is read, instead of being read into memory first. The text of a state file
+// for thousands of resources is then never held in memory next to its nodes.
+var streamThreshold int64 = 8 << 20
+
+// readState reads and parses the state file like loadState, without checking
+// that it has what drift needs
+func readState(name string, opts DriftOptions) (cft.Template, stateSource, error) {
+
+	var body io.ReadCloser
+	var metadata map[string]string
+	var bucketName, key string
+	size := int64(-1)
+
+	if opts.StateFile != "" {
+		f, err := os.Open(opts.StateFile)
+		if err != nil {
+			return cft.Template{}, stateSource{}, fmt.Errorf("%w: %v", ErrStateNotFound, err)
+		}
+		if info, err := f.Stat(); err == nil {
+			size = info.Size()
+		}
+		body = f
+	} else {
+		spinner.Push("Downloading state file")
+
+		var err error
+		bucketName, err = stateBucket(opts.Bucket, opts.CreateBucket)
+		if err != nil {
+			spinner.Pop()
+			return cft.Template{}, stateSource{}, err
+		}
+
+		key = stateKey(name, opts.Prefix)
+
+		stream, err := s3.GetObjectStream(bucketName, key)
+		if err != nil {
+			spinner.Pop()
+			return cft.Template{}, stateSource{}, fmt.Errorf("%w: %v", ErrStateNotFound, err)
+		}
+		body, metadata, size = stream.Body, stream.Metadata, stream.Size
+	}
+	defer body.Close()
+
+	checksum := s3.NewChecksumReader(body)
+	template, compressed, err := parseState(checksum, size)
+	if bucketName != "" {
+		spinner.Pop()
+	}
+	if err != nil {
+		return cft.Template{}, stateSource{}, err
+	}
+
+	if bucketName != "" && !opts.NoVerify {
+		verifyState(checksum.Sum(), metadata, bucketName, key)
+	}
+
+	return template, stateSource{bucket: bucketName, key: key, compressed: compressed}, nil
+}
+
+// parseState parses a state file of size bytes, or of unknown size if it
+// is negative, as it is read from r if it is larger than streamThreshold.
+// All of r is read, so that it can be checked against its checksum.
+func parseState(r io.Reader, size int64) (cft.Template, bool, error) {
+	if size >= 0 && size <= streamThreshold {
+		obj, err := io.ReadAll(r)
+		if err != nil {
+			return cft.Template{}, false, fmt.Errorf("%w: %v", ErrStateNotFound, err)
+		}
+
+		obj, compressed, err := decompressState(obj)
+		if err != nil {
+			return cft.Template{}, compressed, err
+		}
+
+		config.Debugf("State file: %s", obj)
+
+		template, err := parse.StringStrict(string(obj))
+		return template, compressed, err
+	}
+
+	config.Debugf("Parsing the state file as it is read, since it is larger than %d bytes", streamThreshold)
+
+	yamlReader, compressed, err := decompressReader(r)
+	if err != nil {
+		return cft.Template{}, compressed, err
+	}
+
+	defer yamlReader.Close()
+
+	template, err := parse.ReaderStrict(yamlReader)
+	if err != nil {
+		return cft.Template{}, compressed, err
+	}
+
+	// The rest of a gzip stream is read to find out if it was truncated
+	if _, err := io.Copy(io.Discard, yamlReader); err != nil {
+		return cft.Template{}, compressed, fmt.Errorf("unable to decompress state file: %v", err)
+	}
+
+	// Anything after the first document still counts towards the checksum
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return cft.Template{}, compressed, fmt.Errorf("%w: %v", ErrStateNotFound, err)
+	}
+
+	return template, compressed, nil
+}
+
+// verifyState warns if a state file does not match the checksum stored
+// with it, which is a sign of a partial write or of the file being changed
+// outside of rain. The warning is written to stderr, so that it is seen
+// even when the report is printed as JSON.
+func verifyState(sum string, metadata map[string]string, bucketName string, key string) {
+	ok, err := s3.VerifyDigest(sum, metadata)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, console.Red(fmt.Sprintf(
+			"WARNING: the state file s3://%s/%s does not match the checksum it was written with (%v). "+
+				"It may have been partially written or changed outside of rain.", bucketName, key, err)))
+		return
+	}
+	if !ok {
+		config.Debugf("State file s3://%s/%s has no checksum to verify", bucketName, key)
+	}
+}
+
+// driftReport checks each resource in the state file for drift
+// without printing anything or asking the user what to do
+func driftReport(name string, template cft.Template, opts DriftOptions) (*DriftReport, error) {
+
+	if opts.IdentityKeys == nil {
+		opts.IdentityKeys = defaultIdentityKeys
+	}
+
+	resources, err := template.GetSection(cft.Resources)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMissingSection, err)
+	}
+
+	resourceModels, err := template.GetNode(cft.State, "ResourceModels")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMissingSection, err)
+	}
+
+	names, err := selectedResources(resources, opts.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceMap, err := template.Resources()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMissingSection, err)
+	}
+
+	names, skipped := filterByType(names, resourceMap, opts.IncludeTypes, opts.ExcludeTypes)
+	names, skippedRetained := filterRetained(names, resourceMap, opts.SkipRetained)
+
+	results, err := detectAll(names, resourceMap, resourceModels, opts)
+	if err != nil {
+		return nil, err
+	}
+	processed := len(results)
+	results, skippedByTag := filterByTag(results, opts.FilterTags)
+
+	report := &DriftReport{
+		Name:            name,
+		Total:           len(resources.Content) / 2,
+		Processed:       processed,
+		Resources:       results,
+		DriftRatio:      driftRatio(results),
+		SkippedByType:   skipped,
+		SkippedRetained: skippedRetained,
+		SkippedByTag:    skippedByTag,
+	}
+
+	if opts.DetectOrphans {
+		report.Orphans, err = findOrphans(resourceMap, resourceModels, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
@@ -1,124 +1,776 @@
 package cc
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/cft/diff"
 	"github.com/aws-cloudformation/rain/cft/format"
-	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/aws"
 	"github.com/aws-cloudformation/rain/internal/aws/ccapi"
-	"github.com/aws-cloudformation/rain/internal/aws/cfn"
 	"github.com/aws-cloudformation/rain/internal/aws/s3"
 	"github.com/aws-cloudformation/rain/internal/config"
 	"github.com/aws-cloudformation/rain/internal/console"
 	"github.com/aws-cloudformation/rain/internal/console/spinner"
-	"github.com/aws-cloudformation/rain/internal/node"
 	"github.com/aws-cloudformation/rain/internal/s11n"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
 
+// driftOutput is set by the --output flag on cc drift
+var driftOutput string
+
+// driftResources is set by the --resource flag on cc drift
+var driftResources []string
+
+// driftStateFile is set by the --state-file flag on cc drift
+var driftStateFile string
+
+// driftBucket is set by the --bucket flag on cc drift
+var driftBucket string
+
+// driftPrefix is set by the --prefix flag on cc drift
+var driftPrefix string
+
+// driftIncludeTypes is set by the --include-type flag on cc drift
+var driftIncludeTypes []string
+
+// driftExcludeTypes is set by the --exclude-type flag on cc drift
+var driftExcludeTypes []string
+
+// driftIgnore is set by the --ignore flag on cc drift
+var driftIgnore []string
+
+// driftSummary is set by the --summary flag on cc drift
+var driftSummary bool
+
+// driftDetectOrphans is set by the --detect-orphans flag on cc drift
+var driftDetectOrphans bool
+
+// driftSince is set by the --since flag on cc drift
+var driftSince time.Duration
+
+// driftIdentityKeys is set by the --identity-key flag on cc drift
+var driftIdentityKeys map[string]string
+
+// driftAll is set by the --all flag on cc drift
+var driftAll bool
+
+// driftPlan is set by the --plan flag on cc drift
+var driftPlan bool
+
+// driftUnified is set by the --unified flag on cc drift.
+// It is the number of context lines, or -1 to show the full models.
+var driftUnified int = -1
+
+// driftScore is set by the --score flag on cc drift
+var driftScore bool
+
+// driftRecord is set by the --record flag on cc drift
+var driftRecord bool
+
+// driftAgainst is set by the --against flag on cc drift
+var driftAgainst string
+
+// driftVerbose is set by the --verbose flag on cc drift
+var driftVerbose bool
+
+// driftCreateBucket is set by the --create-bucket flag on cc drift
+var driftCreateBucket bool
+
+// driftIgnoreManagedTags is set by the --ignore-managed-tags flag on cc drift
+var driftIgnoreManagedTags bool
+
+// driftFilterTags is set by the --filter-tag flag on cc drift
+var driftFilterTags map[string]string
+
+// driftManagedTagPrefixes is set by the --managed-tag-prefix flag on cc drift
+var driftManagedTagPrefixes []string
+
+// driftCollapse is set by the --collapse flag on cc drift
+var driftCollapse float64
+
+// driftContext is set by the --context flag on cc drift
+var driftContext int = 40
+
+// driftTimeout is set by the --timeout flag on cc drift
+var driftTimeout time.Duration = 30 * time.Second
+
+// driftNoVerify is set by the --no-verify flag on cc drift
+var driftNoVerify bool
+
+// driftNoWrap is set by the --no-wrap flag on cc drift
+var driftNoWrap bool
+
+// driftRate is set by the --rate flag on cc drift
+var driftRate float64 = 10
+
+// driftLimiter paces CCAPI reads at driftRate
+var driftLimiter *ccapi.RateLimiter
+
+// driftIgnoreValueCase is set by the --ignore-value-case flag on cc drift
+var driftIgnoreValueCase bool
+
+// driftStoredKeysOnly is set by the --stored-keys-only flag on cc drift
+var driftStoredKeysOnly bool
+
+// driftDiffOnly is set by the --diff-only flag on cc drift
+var driftDiffOnly bool
+
+// driftSkipRetained is set by the --skip-retained flag on cc drift
+var driftSkipRetained bool
+
+// driftDecisionsFile is set by the --decisions flag on cc drift
+var driftDecisionsFile string
+
+// driftDecisions are read from, or recorded for, the file in --decisions
+var driftDecisions *decisionSet
+
+// driftConcurrency is set by the --concurrency flag on cc drift
+var driftConcurrency int = 5
+
+// driftSeverityConfig is set by the --severity-config flag on cc drift
+var driftSeverityConfig string
+
+// driftSeverity is read from the file in --severity-config
+var driftSeverity *SeverityConfig
+
+// driftFailOn is set by the --fail-on flag on cc drift
+var driftFailOn string = "none"
+
+// Exit codes used by cc drift. Errors use driftExitError unless --fail-on
+// is none, and the others are only used with --fail-on drift.
+const (
+	driftExitError = 1
+	driftExitDrift = 2
+
+	// With --severity-config, the exit code rises with the highest severity
+	driftExitWarn     = 3
+	driftExitCritical = 4
+
+	// A resource whose type changed can't be compared
+	driftExitTypeChanged = 5
+)
+
+// driftHighestSeverity is the highest severity of the drift seen in any deployment
+var driftHighestSeverity string
+
+// driftTypeChanged is true if the type of any resource in any deployment changed
+var driftTypeChanged bool
+
+// driftExitCode returns the exit code for drift of the given severity
+func driftExitCode(severity string) int {
+	switch severity {
+	case SeverityWarn:
+		return driftExitWarn
+	case SeverityCritical:
+		return driftExitCritical
+	default:
+		return driftExitDrift
+	}
+}
+
 func runDrift(cmd *cobra.Command, args []string) {
 
-	name := args[0]
+	// The JSON and YAML reports are meant for scripts, so fail on drift unless asked not to
+	if reportOutput() && !cmd.Flags().Changed("fail-on") {
+		driftFailOn = "drift"
+	}
+
+	if reportOutput() {
+		// Nothing but the report should be written to stdout
+		spinner.Disable()
+		console.NoColour = true
+	}
+
+	names, err := driftNames(args)
+	if err != nil {
+		spinner.Stop()
+		console.Errorf("%v", err)
+		os.Exit(driftExitError)
+	}
+
+	// Diffs, summaries and reports are written to w, which is the pager if there is one
+	var w io.Writer = os.Stdout
+
+	if driftWatch {
+		runWatch(w, names[0])
+		return
+	}
+
+	if usePager() {
+		w, err = startPager()
+		if err != nil {
+			console.Errorf("%v", err)
+			os.Exit(driftExitError)
+		}
+	}
+
+	drifted := false
+	failed := false
+	reports := make([]*DriftReport, 0)
+
+	for _, name := range names {
+		if driftAll && !reportOutput() {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, console.Bold(fmt.Sprintf("========== %s ==========", name)))
+		}
+
+		d, report, err := drift(w, name)
+		if err != nil {
+			spinner.Stop()
+			if driftAll {
+				console.Errorf("%s: %v", name, err)
+			} else {
+				console.Errorf("%v", err)
+			}
+			failed = true
+			continue
+		}
+
+		drifted = drifted || d
+		if report != nil {
+			reports = append(reports, report)
+		}
+	}
+
+	if reportOutput() && (driftAll || len(reports) > 0) {
+		var out []byte
+		if driftAll {
+			out, err = formatReport(reports, driftOutput)
+		} else {
+			out, err = formatReport(reports[0], driftOutput)
+		}
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintln(w, string(out))
+	}
+
+	stopPager()
+
+	if code := driftExitStatus(failed, drifted); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// driftExitStatus returns the exit status for --fail-on, given whether any
+// deployment failed to be checked and whether any had drifted
+func driftExitStatus(failed bool, drifted bool) int {
+	if failed && driftFailOn != "none" {
+		return driftExitError
+	}
+	if driftFailOn != "drift" {
+		return 0
+	}
+	if driftTypeChanged {
+		return driftExitTypeChanged
+	}
+	if drifted {
+		return driftExitCode(driftHighestSeverity)
+	}
+	return 0
+}
+
+// driftNames checks the arguments and flags and returns the names
+// of the deployments to check
+func driftNames(args []string) ([]string, error) {
 
 	if !Experimental {
-		panic("Please add the --experimental arg to use this feature")
+		return nil, errors.New("Please add the --experimental arg to use this feature")
+	}
+
+	if !slices.Contains([]string{"none", "error", "drift"}, driftFailOn) {
+		return nil, fmt.Errorf("unsupported --fail-on value '%s'", driftFailOn)
+	}
+
+	if driftScopeConfig != "" {
+		cfg, err := loadScopeConfig(driftScopeConfig)
+		if err != nil {
+			return nil, err
+		}
+		driftScope = cfg
+	}
+
+	if driftSeverityConfig != "" {
+		cfg, err := loadSeverityConfig(driftSeverityConfig)
+		if err != nil {
+			return nil, err
+		}
+		driftSeverity = cfg
+	}
+
+	if driftRate < 0 {
+		return nil, fmt.Errorf("--rate can't be negative, got %v", driftRate)
+	}
+	if driftRate > 0 {
+		driftLimiter = ccapi.NewRateLimiter(driftRate)
+	}
+
+	if driftDecisionsFile != "" {
+		d, err := loadDecisions(driftDecisionsFile)
+		if err != nil {
+			return nil, err
+		}
+		driftDecisions = d
+	}
+
+	if ccapi.ExternalID != "" && ccapi.RoleArn == "" {
+		return nil, errors.New("--external-id is only used with --assume-role")
+	}
+
+	if driftWatch {
+		if driftAll || driftOutput != "" || driftAgainst != "" {
+			return nil, errors.New("--watch can't be used with --all, --output, or --against")
+		}
+		if driftInterval <= 0 {
+			return nil, fmt.Errorf("--interval must be more than 0, got %v", driftInterval)
+		}
+	}
+
+	if driftBootstrap && (reportOutput() || driftAgainst != "" || driftWatch) {
+		return nil, errors.New("--bootstrap can't be used with --output, --against, or --watch")
+	}
+
+	if driftPager && !driftNoPager && (driftWatch || asksQuestions()) {
+		return nil, errors.New("--pager can only be used when drift does not ask what to do: with --summary, --against, or --decisions and --yes")
+	}
+
+	if driftCollapse < 0 || driftCollapse > 1 {
+		return nil, fmt.Errorf("--collapse must be between 0 and 1, got %v", driftCollapse)
+	}
+
+	if driftOutput != "" && !reportOutput() {
+		return nil, fmt.Errorf("unsupported output format '%s'", driftOutput)
+	}
+
+	if !driftAll {
+		if len(args) != 1 {
+			return nil, errors.New("expected the name of a deployment, or --all")
+		}
+		return args, nil
 	}
 
-	spinner.Push("Downloading state file")
+	if len(args) > 0 {
+		return nil, errors.New("--all checks every deployment, so don't pass a deployment name")
+	}
+	if driftStateFile != "" {
+		return nil, errors.New("--all can't be used with --state-file")
+	}
+
+	spinner.Push("Listing deployments")
+	defer spinner.Pop()
+
+	bucketName, err := stateBucket(driftBucket, driftCreateBucket)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimSuffix(stateKey("", driftPrefix), ".yaml")
+	keys, err := s3.ListObjects(bucketName, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list deployments in %s: %v", bucketName, err)
+	}
 
-	bucketName := s3.RainBucket(false)
+	return deploymentNames(keys, prefix), nil
+}
+
+// deploymentNames returns the names of the state files directly under prefix
+func deploymentNames(keys []string, prefix string) []string {
+	names := make([]string, 0)
+	for _, key := range keys {
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		if name, ok = strings.CutSuffix(name, ".yaml"); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
 
-	key := getStateFileKey(name)
+// drift checks the named deployment and reports whether any resource has drifted.
+// With --output json or yaml, it returns a report instead of printing the drift.
+func drift(w io.Writer, name string) (bool, *DriftReport, error) {
 
-	obj, err := s3.GetObject(bucketName, key)
+	template, src, err := loadState(name, driftOptions())
+	if errors.Is(err, ErrMissingState) && driftBootstrap {
+		return false, nil, runBootstrap(w, name, driftOptions())
+	}
 	if err != nil {
-		panic(fmt.Errorf("unable to download state: %v", err))
+		return false, nil, err
 	}
 
-	config.Debugf("State file: %s", obj)
+	warnTransforms(name, template)
+
+	if driftAgainst != "" {
+		return runAgainst(w, name, template)
+	}
+
+	if driftSince > 0 {
+		recent, lastWrite, err := writtenWithin(template, driftSince, time.Now())
+		if err != nil {
+			return false, nil, err
+		}
+		if recent {
+			msg := fmt.Sprintf("Skipping drift detection: %s was deployed at %s, less than %v ago",
+				name, lastWrite.Format(time.RFC3339), driftSince)
+			if reportOutput() {
+				// Keep stdout free for the report
+				fmt.Fprintln(os.Stderr, msg)
+				return false, &DriftReport{Name: name, Resources: []*ResourceDrift{}}, nil
+			}
+			fmt.Fprintln(w, msg)
+			return false, nil, nil
+		}
+	}
+
+	if reportOutput() {
+		opts := driftOptions()
+		report, err := driftReport(name, template, opts)
+		if err != nil {
+			return false, nil, err
+		}
+		report.setSession(opts)
+		if driftRecord {
+			if err := recordDrift(template, report.Resources, opts.StateFile, src); err != nil {
+				return false, nil, err
+			}
+		}
+		driftHighestSeverity = maxSeverity(driftHighestSeverity, highestSeverity(report.Resources))
+		driftTypeChanged = driftTypeChanged || anyTypeChanged(report.Resources)
+		return report.HasDrift(), report, nil
+	}
+
+	drifted, err := runDriftOnState(w, name, template, src)
+	return drifted, nil, err
+}
 
-	template, err := parse.String(string(obj))
+// writtenWithin reports whether the state file was written less than d before now
+func writtenWithin(template cft.Template, d time.Duration, now time.Time) (bool, time.Time, error) {
+	lastWrite, err := template.GetNode(cft.State, "LastWriteTime")
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("%w: %v", ErrMissingSection, err)
+	}
+	t, err := time.Parse(time.RFC3339, lastWrite.Value)
 	if err != nil {
-		panic(err)
+		return false, time.Time{}, fmt.Errorf("unable to parse LastWriteTime %s: %v", lastWrite.Value, err)
+	}
+	return now.Sub(t) < d, t, nil
+}
+
+// selectedResources returns the logical ids of the resources to check,
+// in template order. All resources are selected unless only (--resource) is set.
+func selectedResources(resources *yaml.Node, only []string) ([]string, error) {
+	all := make([]string, 0)
+	for i := 0; i < len(resources.Content); i += 2 {
+		all = append(all, resources.Content[i].Value)
 	}
 
-	spinner.Pop()
+	if len(only) == 0 {
+		return all, nil
+	}
+
+	for _, name := range only {
+		if !slices.Contains(all, name) {
+			return nil, fmt.Errorf("resource %s is not in the state file. Available resources: %s",
+				name, strings.Join(all, ", "))
+		}
+	}
+
+	selected := make([]string, 0)
+	for _, name := range all {
+		if slices.Contains(only, name) {
+			selected = append(selected, name)
+		}
+	}
+	return selected, nil
+}
+
+// filterByType returns the names of the resources whose types are in include
+// (if it is set) and not in exclude, and how many were skipped
+func filterByType(names []string, resources map[string]*yaml.Node, include []string, exclude []string) ([]string, int) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return names, 0
+	}
+
+	kept := make([]string, 0)
+	for _, name := range names {
+		typeName := ""
+		if _, t, _ := s11n.GetMapValue(resources[name], "Type"); t != nil {
+			typeName = t.Value
+		}
+		if len(include) > 0 && !slices.Contains(include, typeName) {
+			continue
+		}
+		if slices.Contains(exclude, typeName) {
+			continue
+		}
+		kept = append(kept, name)
+	}
+
+	return kept, len(names) - len(kept)
+}
+
+// isRetained returns true if the resource has a DeletionPolicy that keeps
+// it when it is deleted, so it might outlive the deployment
+func isRetained(resource *yaml.Node) bool {
+	_, policy, _ := s11n.GetMapValue(resource, "DeletionPolicy")
+	return policy != nil && (policy.Value == "Retain" || policy.Value == "RetainExceptOnCreate")
+}
+
+// filterRetained returns the names of the resources that are not retained,
+// and how many were skipped, if skip is true
+func filterRetained(names []string, resources map[string]*yaml.Node, skip bool) ([]string, int) {
+	if !skip {
+		return names, 0
+	}
+
+	kept := make([]string, 0)
+	for _, name := range names {
+		if !isRetained(resources[name]) {
+			kept = append(kept, name)
+		}
+	}
+
+	return kept, len(names) - len(kept)
+}
+
+// detectAll checks each named resource for drift, running up to
+// opts.Concurrency CCAPI queries at a time. The results are in the same order as names.
+func detectAll(names []string, resources map[string]*yaml.Node, resourceModels *yaml.Node, opts DriftOptions) ([]*ResourceDrift, error) {
+
+	type job struct {
+		name  string
+		node  *yaml.Node
+		model *yaml.Node
+	}
+
+	jobs := make([]job, 0)
+	for _, resourceName := range names {
+		resourceNode := resources[resourceName]
+		_, resourceModel, _ := s11n.GetMapValue(resourceModels, resourceName)
+		if resourceModel == nil {
+			return nil, fmt.Errorf("%w: expected %s to have a ResourceModel", ErrResourceModelMissing, resourceName)
+		}
+		jobs = append(jobs, job{resourceName, resourceNode, resourceModel})
+	}
+
+	concurrency := max(opts.Concurrency, 1)
 
-	if err := runDriftOnState(name, template, bucketName, key); err != nil {
-		panic(err)
+	// Schemas are only loaded for resources with composite identifiers
+	schemas := opts.schemas
+	if schemas == nil {
+		schemas = newSchemaCache()
 	}
+
+	// Messages from concurrent queries are coalesced into a single status
+	if concurrency > 1 {
+		// Load the AWS config up front, since doing it concurrently is unsafe
+		aws.Config()
+		spinner.StartGroup(fmt.Sprintf("Querying %d resources", len(jobs)))
+		defer spinner.EndGroup()
+	}
+
+	results := make([]*ResourceDrift, len(jobs))
+	errs := make([]error, len(jobs))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				j := jobs[i]
+				spinner.Push(fmt.Sprintf("Querying resource %d of %d: %s", i+1, len(jobs), j.name))
+				results[i], errs[i] = detectResourceDrift(j.name, j.node, j.model, opts, schemas)
+				if results[i] != nil {
+					results[i].Retained = isRetained(j.node)
+				}
+				spinner.Pop()
+			}
+		}()
+	}
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	classifyAll(results, opts.Severity)
+
+	return results, nil
 }
 
-func runDriftOnState(name string, template cft.Template, bucketName string, key string) error {
+// runDriftOnState shows the drift for each resource and applies the changes
+// the user selects. It reports whether any resource had drifted.
+// The template must already have been checked by validateState.
+func runDriftOnState(w io.Writer, name string, template cft.Template, src stateSource) (bool, error) {
 
 	resources, err := template.GetSection(cft.Resources)
 	if err != nil {
-		return err
+		return false, fmt.Errorf("%w: %v", ErrMissingSection, err)
 	}
 
 	_, err = template.GetSection(cft.State)
 	if err != nil {
-		return err
+		return false, fmt.Errorf("%w: %v", ErrMissingSection, err)
 	}
 
+	opts := driftOptions()
+
 	// Display deployment meta-data
 
-	fmt.Println()
-	fmt.Println("Checking for drift on existing deployment")
-	fmt.Println()
-	fmt.Print(console.Blue("Deployment name:  "))
-	fmt.Print(console.Cyan(fmt.Sprintf("%s\n", name)))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Checking for drift on existing deployment")
+	fmt.Fprintln(w)
+	fmt.Fprint(w, console.Blue("Deployment name:  "))
+	fmt.Fprint(w, console.Cyan(fmt.Sprintf("%s\n", name)))
+
+	fmt.Fprint(w, console.Blue("State file:       "))
+	if opts.StateFile != "" {
+		fmt.Fprint(w, console.Cyan(fmt.Sprintf("%s\n", opts.StateFile)))
+	} else {
+		fmt.Fprint(w, console.Cyan(fmt.Sprintf("s3://%s/%s\n", src.bucket, src.key)))
+	}
+
+	localPath, _ := template.GetStringValue(string(cft.State), "FilePath")
+	fmt.Fprint(w, console.Blue("Local path:       "))
+	fmt.Fprint(w, console.Cyan(fmt.Sprintf("%s\n", localPath)))
+
+	lastWriteTime, _ := template.GetStringValue(string(cft.State), "LastWriteTime")
+	fmt.Fprint(w, console.Blue("Last write time:  "))
+	fmt.Fprint(w, console.Cyan(fmt.Sprintf("%s\n", lastWriteTime)))
 
-	fmt.Print(console.Blue("State file:       "))
-	fmt.Print(console.Cyan(fmt.Sprintf("s3://%s/%s\n", bucketName, key)))
+	// Live state is read from the account of --assume-role, if it is used
+	session := opts.pseudoParameters()
+	if session.AccountId != "" {
+		fmt.Fprint(w, console.Blue("Account:          "))
+		fmt.Fprint(w, console.Cyan(fmt.Sprintf("%s\n", session.AccountId)))
+	}
+	fmt.Fprint(w, console.Blue("Region:           "))
+	fmt.Fprint(w, console.Cyan(fmt.Sprintf("%s\n", session.Region)))
 
-	localPath, err := template.GetNode(cft.State, "FilePath")
+	resourceModels, err := template.GetNode(cft.State, "ResourceModels")
 	if err != nil {
-		panic(err)
+		return false, fmt.Errorf("%w: %v", ErrMissingSection, err)
 	}
-	fmt.Print(console.Blue("Local path:       "))
-	fmt.Print(console.Cyan(fmt.Sprintf("%s\n", localPath.Value)))
 
-	lastWrite, err := template.GetNode(cft.State, "LastWriteTime")
+	names, err := selectedResources(resources, opts.Resources)
 	if err != nil {
-		panic(err)
+		return false, err
 	}
-	fmt.Print(console.Blue("Last write time:  "))
-	fmt.Print(console.Cyan(fmt.Sprintf("%s\n", lastWrite.Value)))
 
-	resourceModels, err := template.GetNode(cft.State, "ResourceModels")
+	resourceMap, err := template.Resources()
 	if err != nil {
-		panic(err)
+		return false, fmt.Errorf("%w: %v", ErrMissingSection, err)
 	}
 
-	fmt.Println()
+	names, skipped := filterByType(names, resourceMap, opts.IncludeTypes, opts.ExcludeTypes)
+	names, skippedRetained := filterRetained(names, resourceMap, opts.SkipRetained)
 
-	selections := make([]selection, 0)
+	if len(opts.Resources) > 0 || skipped > 0 || skippedRetained > 0 {
+		fmt.Fprint(w, console.Blue("Resources:        "))
+		fmt.Fprint(w, console.Cyan(fmt.Sprintf("%d of %d\n", len(names), len(resourceMap))))
+	}
 
-	// Query each resource and stop to ask how to handle drift after each one
-	for i := 0; i < len(resources.Content); i += 2 {
-		resourceName := resources.Content[i].Value
-		resourceNode := resources.Content[i+1]
-		_, resourceModel, _ := s11n.GetMapValue(resourceModels, resourceName)
-		if resourceModel == nil {
-			panic(fmt.Errorf("expected %s to have a ResourceModel", resourceName))
+	fmt.Fprintln(w)
+
+	results, err := detectAll(names, resourceMap, resourceModels, opts)
+	if err != nil {
+		return false, err
+	}
+
+	// Store a reference to each resource in the global map for later if we
+	// need to resolve intrinsics
+	for _, rd := range results {
+		resMap[rd.Name] = rd.resource
+	}
+
+	results, skippedByTag := filterByTag(results, opts.FilterTags)
+	driftHighestSeverity = maxSeverity(driftHighestSeverity, highestSeverity(results))
+	driftTypeChanged = driftTypeChanged || anyTypeChanged(results)
+
+	if driftRecord {
+		if err := recordDrift(template, results, opts.StateFile, src); err != nil {
+			return false, err
 		}
+	}
 
-		selection, err := handleDrift(resourceName, resourceNode, resourceModel)
+	orphans := make([]*OrphanResource, 0)
+	if opts.DetectOrphans {
+		spinner.Push("Listing live resources that are not in the state file")
+		orphans, err = findOrphans(resourceMap, resourceModels, opts)
+		spinner.Pop()
 		if err != nil {
-			panic(err)
+			return false, err
+		}
+	}
+
+	if driftSummary {
+		drifted := printDriftSummary(w, results)
+		fmt.Fprintf(w, "Processed %d of %d resources in the state file\n", len(results)+skippedByTag, len(resourceMap))
+		printSkippedByType(w, skipped)
+		printSkippedRetained(w, skippedRetained)
+		printSkippedByTag(w, skippedByTag)
+		fmt.Fprintln(w)
+		printOrphans(w, orphans)
+		return drifted || len(orphans) > 0, nil
+	}
+
+	selections := make([]selection, 0)
+
+	// Show each resource in template order and ask how to handle drift after each one
+	for _, rd := range results {
+		selection, err := handleDrift(w, name, rd)
+		if err != nil {
+			return false, err
 		}
 		selections = append(selections, selection)
 	}
 
+	if err := driftDecisions.save(driftDecisionsFile); err != nil {
+		return false, err
+	}
+
+	drifted := 0
+	for _, selection := range selections {
+		if selection.Drifted {
+			drifted++
+		}
+	}
+	if driftDiffOnly {
+		fmt.Fprintf(w, "%d/%d resources Ok\n", okCount(results), len(results))
+	} else {
+		fmt.Fprintf(w, "Checked %d resources, %d drifted\n", len(selections), drifted)
+	}
+	if driftScore {
+		fmt.Fprintf(w, "Deployment drift score: %.0f%%\n", driftRatio(results)*100)
+	}
+	printSkippedByType(w, skipped)
+	printSkippedRetained(w, skippedRetained)
+	printSkippedByTag(w, skippedByTag)
+	fmt.Fprintln(w)
+	printOrphans(w, orphans)
+	hasDrift := drifted > 0 || len(orphans) > 0
+
 	// Check to see if the user elected to change anything
 	hasChanges := false
 	for _, selection := range selections {
@@ -130,77 +782,74 @@ func runDriftOnState(name string, template cft.Template, bucketName string, key
 
 	// Summarize all changes that will be made and ask the user to confirm
 	if !hasChanges {
-		fmt.Println("No changes were made to your infrastructure or to the state file.")
-		return nil
+		fmt.Fprintln(w, "No changes were made to your infrastructure or to the state file.")
+		return hasDrift, nil
 	}
 
-	fmt.Println("The following changes will be made:")
-	fmt.Println()
+	if driftPlan {
+		fmt.Fprintln(w, "The following changes would be made:")
+	} else {
+		fmt.Fprintln(w, "The following changes will be made:")
+	}
+	fmt.Fprintln(w)
 	for _, selection := range selections {
 		switch selection.Action {
 		case changeLiveState:
-			fmt.Println("   ⚡ Change Live State for", selection.ResourceName)
+			fmt.Fprintln(w, "   ⚡ Change Live State for", selection.ResourceName)
 		case changeStateFile:
-			fmt.Println("   📄 Change state file for", selection.ResourceName)
+			fmt.Fprintln(w, "   📄 Change state file for", selection.ResourceName)
 		}
 	}
-	fmt.Println()
-
-	// Confirm and then actually make the changes
-	if !console.Confirm(true, "Do you wish to continue?") {
-		fmt.Println("Deployment cancelled. No changes have been made to the state file or to live state")
-		return nil
-	}
+	fmt.Fprintln(w)
 
 	// Set the global template reference for resolving intrinsics
 	deployedTemplate = template
 
+	// Load each type's schema once, no matter how many resources share it
+	schemas := newSchemaCache()
+	liveTypes := make([]string, 0)
+	for _, selection := range selections {
+		if selection.Action == changeLiveState && !slices.Contains(liveTypes, selection.ResourceType) {
+			liveTypes = append(liveTypes, selection.ResourceType)
+		}
+	}
+	spinner.Push("Loading resource type schemas")
+	err = schemas.warm(liveTypes)
+	spinner.Pop()
+	if err != nil {
+		// Each resource reports its own failure below
+		config.Debugf("unable to pre-load schemas: %v", err)
+	}
+
+	if driftPlan {
+		printPlan(w, selections, schemas)
+		fmt.Fprintln(w, "This is a plan only. No changes have been made to the state file or to live state")
+		return hasDrift, nil
+	}
+
+	// Confirm and then actually make the changes
+	if !yes && !console.Confirm(true, "Do you wish to continue?") {
+		fmt.Fprintln(w, "Deployment cancelled. No changes have been made to the state file or to live state")
+		return hasDrift, nil
+	}
+
 	hasStateFileChanges := false
 	for _, selection := range selections {
 		switch selection.Action {
 		case changeLiveState:
 			spinner.Push(fmt.Sprintf("   ⚡ Changing Live State for %s", selection.ResourceName))
 
-			// Download the schema
-			schema, err := cfn.GetTypeSchema(selection.ResourceType, cfn.UseCacheNormally)
-			if err != nil {
-				console.Errorf("unable to load schema for %s: %v", selection.ResourceName, err)
-				break
-			}
-
-			// Look at the schema to get read only props and remove them
-			var schemaMap map[string]any
-			json.Unmarshal([]byte(schema), &schemaMap)
-
-			roProps := make([]string, 0)
-
-			readOnly, exists := schemaMap["readOnlyProperties"]
-			if exists {
-				config.Debugf("readOnly: %v", readOnly)
-				for _, p := range readOnly.([]any) {
-					roProps = append(roProps, strings.Replace(p.(string), "/properties/", "", 1))
-				}
-			}
-
-			// Resolve intrinsics
-			resolvedNode, err := Resolve(selection.DeploymentResource)
+			resolvedNode, priorJson, err := updateInputs(selection, schemas)
 			if err != nil {
-				console.Errorf("Unable to resolve %s: %v", selection.ResourceName, err)
+				spinner.Pop()
+				console.Errorf("%v", err)
 				break
 			}
 
-			newPriorMap := make(map[string]any)
-			for k, v := range selection.LiveModel {
-				if !slices.Contains(roProps, k) {
-					newPriorMap[k] = v
-				}
-			}
-
-			priorJson, _ := json.Marshal(newPriorMap)
-
 			model, err := ccapi.UpdateResource(selection.ResourceName,
-				selection.ResourceIdentifier, resolvedNode, string(priorJson))
+				selection.ResourceIdentifier, resolvedNode, priorJson)
 			if err != nil {
+				spinner.Pop()
 				msg := "unable to update live state for %s: %v"
 				console.Errorf(msg, selection.ResourceName, err)
 				break
@@ -209,7 +858,7 @@ func runDriftOnState(name string, template cft.Template, bucketName string, key
 
 			spinner.Pop()
 
-			fmt.Println(console.Green(fmt.Sprintf("Updated %s", selection.ResourceName)))
+			fmt.Fprintln(w, console.Green(fmt.Sprintf("Updated %s", selection.ResourceName)))
 
 		case changeStateFile:
 			hasStateFileChanges = true
@@ -218,25 +867,241 @@ func runDriftOnState(name string, template cft.Template, bucketName string, key
 
 			_, resourceModel, _ := s11n.GetMapValue(resourceModels, selection.ResourceName)
 			config.Debugf("About to change state ResoureModel for %s: %v", selection.ResourceName, selection.LiveModel)
-			var replacementNode yaml.Node
-			replacementNode.Encode(selection.LiveModel)
-			node.SetMapValue(resourceModel, "Model", &replacementNode)
+
+			// Change the stored model in place, so that comments in it are kept
+			var err error
+			if _, stored, _ := s11n.GetMapValue(resourceModel, "Model"); stored != nil {
+				err = updateModel(stored, selection.LiveModel)
+			} else {
+				var replacementNode yaml.Node
+				if err = replacementNode.Encode(selection.LiveModel); err == nil {
+					err = s11n.SetMapValue(resourceModel, "Model", &replacementNode)
+				}
+			}
+			if err != nil {
+				spinner.Pop()
+				console.Errorf("unable to change state file for %s: %v", selection.ResourceName, err)
+				break
+			}
 
 			spinner.Pop()
 		}
 	}
 
 	if hasStateFileChanges {
+		lastWrite, err := template.GetNode(cft.State, "LastWriteTime")
+		if err != nil {
+			return hasDrift, fmt.Errorf("%w: %v", ErrMissingSection, err)
+		}
 		lastWrite.Value = time.Now().Format(time.RFC3339)
 		str := format.String(template, format.Options{JSON: false, Unsorted: false})
-		err = s3.PutObject(bucketName, key, []byte(str))
+
+		// A compressed state file stays compressed
+		var body []byte
+		body, err = encodeState(str, src.compressed || compressState)
+		if err == nil && opts.StateFile != "" {
+			err = os.WriteFile(opts.StateFile, body, 0644)
+		} else if err == nil {
+			err = s3.PutObjectWithChecksum(src.bucket, src.key, body)
+		}
 		if err != nil {
-			console.Errorf("unable to write updated state file to bucket: %v", err)
+			console.Errorf("unable to write updated state file: %v", err)
 		} else {
-			fmt.Println("State file updated successfully")
+			fmt.Fprintln(w, "State file updated successfully")
+		}
+	}
+	return hasDrift, nil
+}
+
+// updateInputs returns the resolved resource and the prior live model
+// that UpdateResource needs to change the live state of a resource.
+// Read only properties are left out of the prior model.
+func updateInputs(selection selection, schemas *schemaCache) (*yaml.Node, string, error) {
+
+	// Get the schema to find read only props and remove them
+	schema, err := schemas.get(selection.ResourceType)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to load schema for %s: %v", selection.ResourceName, err)
+	}
+	roProps := schema.ReadOnlyProperties
+
+	// The template is stored as it was written, and rain can't run the macro
+	if hasTransform(selection.DeploymentResource.Node) {
+		return nil, "", fmt.Errorf("unable to change the live state of %s, since its template uses %s; change the state file instead",
+			selection.ResourceName, fnTransform)
+	}
+	if macros := sectionTransforms(deployedTemplate); len(macros) > 0 {
+		return nil, "", fmt.Errorf("unable to change the live state of %s, since its template uses %s; change the state file instead",
+			selection.ResourceName, strings.Join(macros, ", "))
+	}
+
+	// Resolve intrinsics
+	resolvedNode, err := Resolve(selection.DeploymentResource)
+	if err != nil {
+		return nil, "", fmt.Errorf("Unable to resolve %s: %v", selection.ResourceName, err)
+	}
+
+	newPriorMap := make(map[string]any)
+	for k, v := range selection.LiveModel {
+		if !slices.Contains(roProps, k) {
+			newPriorMap[k] = v
+		}
+	}
+
+	priorJson, _ := json.Marshal(newPriorMap)
+
+	return resolvedNode, string(priorJson), nil
+}
+
+// printPlan shows what each selection would do without changing anything:
+// the patch that would be sent to CCAPI, or the model that would be written to the state file
+func printPlan(w io.Writer, selections []selection, schemas *schemaCache) {
+	for _, selection := range selections {
+		switch selection.Action {
+		case changeLiveState:
+			resolvedNode, priorJson, err := updateInputs(selection, schemas)
+			if err != nil {
+				console.Errorf("%v", err)
+				continue
+			}
+			_, props, _ := s11n.GetMapValue(resolvedNode, "Properties")
+			patch, err := ccapi.CreatePatch(props, priorJson)
+			if err != nil {
+				console.Errorf("unable to create a patch for %s: %v", selection.ResourceName, err)
+				continue
+			}
+			fmt.Fprintln(w, "   ⚡ Patch document for", selection.ResourceName)
+			fmt.Fprintln(w, patch)
+			fmt.Fprintln(w)
+
+		case changeStateFile:
+			out, err := yaml.Marshal(map[string]any{"Model": selection.LiveModel})
+			if err != nil {
+				console.Errorf("unable to encode the live model for %s: %v", selection.ResourceName, err)
+				continue
+			}
+			fmt.Fprintln(w, "   📄 New ResourceModels entry for", selection.ResourceName)
+			fmt.Fprintln(w, string(out))
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// printSkippedByType notes how many resources the type filters left out
+func printSkippedByType(w io.Writer, skipped int) {
+	if skipped > 0 {
+		fmt.Fprintf(w, "Skipped %d resources by type filter\n", skipped)
+	}
+}
+
+// printSkippedRetained notes how many retained resources --skip-retained left out
+func printSkippedRetained(w io.Writer, skipped int) {
+	if skipped > 0 {
+		fmt.Fprintf(w, "Skipped %d retained resources\n", skipped)
+	}
+}
+
+// printChanges lists the path of each changed value. differences are
+// in the same order as changes, and label each one with its severity.
+func printChanges(w io.Writer, changes []diff.Change, differences []PropertyDiff) {
+	for i, c := range changes {
+		severity := ""
+		if i < len(differences) {
+			severity = differences[i].Severity
+		}
+		fmt.Fprintf(w, "      %s%s %s\n", severityLabel(severity), c.Mode, c.PathString())
+	}
+}
+
+// printSkippedByTag notes how many resources --filter-tag left out
+func printSkippedByTag(w io.Writer, skipped int) {
+	if skipped > 0 {
+		fmt.Fprintf(w, "Skipped %d resources by tag filter\n", skipped)
+	}
+}
+
+// printDriftSummary prints a table with one row for each resource followed by the totals,
+// and reports whether any resource had drifted
+func printDriftSummary(w io.Writer, results []*ResourceDrift) bool {
+	header := []string{"Name", "Type", "Identifier", "Status"}
+	if driftScore {
+		header = append(header, "Score")
+	}
+
+	drifted := 0
+	rows := make([][]string, 0)
+	for _, rd := range results {
+		status := console.Green("Ok")
+		if rd.Incomplete {
+			status = console.Yellow("Incomplete")
+		} else if rd.Error != "" {
+			status = console.Yellow("Error")
+		} else if rd.TypeChanged {
+			drifted++
+			status = console.Red("Type changed" + severitySuffix(rd.Severity))
+		} else if rd.Deleted {
+			drifted++
+			status = console.Red("Deleted" + severitySuffix(rd.Severity))
+		} else if rd.Drifted {
+			drifted++
+			status = colorSeverity(rd.Severity, "Drift"+severitySuffix(rd.Severity))
+		}
+		if rd.Retained {
+			status += console.Cyan(" (retained)")
+		}
+		row := []string{rd.Name, rd.Type, rd.Identifier, status}
+		if driftScore {
+			row = append(row, formatScore(rd))
+		}
+		rows = append(rows, row)
+	}
+	fmt.Fprint(w, console.Table(header, rows))
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Checked %d resources, %d drifted\n", len(results), drifted)
+	if driftScore {
+		fmt.Fprintf(w, "Deployment drift score: %.0f%%\n", driftRatio(results)*100)
+	}
+	return drifted > 0
+}
+
+// anyTypeChanged returns true if the type of any of the resources changed
+func anyTypeChanged(results []*ResourceDrift) bool {
+	for _, rd := range results {
+		if rd.TypeChanged {
+			return true
+		}
+	}
+	return false
+}
+
+// okCount returns the number of resources that were checked and have not drifted
+func okCount(results []*ResourceDrift) int {
+	ok := 0
+	for _, rd := range results {
+		if !rd.Drifted && !rd.Incomplete && rd.Error == "" {
+			ok++
 		}
 	}
-	return nil
+	return ok
+}
+
+// formatScore describes the drift score of a resource, like "25% (2 of 8 values)"
+func formatScore(rd *ResourceDrift) string {
+	return fmt.Sprintf("%.0f%% (%d of %d values)", rd.DriftRatio*100, rd.differ, rd.compared)
+}
+
+// driftRatio returns the fraction of all compared values that differ,
+// so that resources with bigger models carry more weight
+func driftRatio(results []*ResourceDrift) float64 {
+	differ, compared := 0, 0
+	for _, rd := range results {
+		differ += rd.differ
+		compared += rd.compared
+	}
+	if compared == 0 {
+		return 0
+	}
+	return float64(differ) / float64(compared)
 }
 
 type action int
@@ -249,6 +1114,7 @@ const (
 
 type selection struct {
 	ResourceName       string
+	Drifted            bool
 	Action             action
 	Text               string
 	LiveModel          map[string]any
@@ -259,66 +1125,217 @@ type selection struct {
 	DeploymentResource *Resource
 }
 
-func handleDrift(resourceName string, resourceNode *yaml.Node, model *yaml.Node) (selection, error) {
-
-	retval := selection{ResourceName: resourceName, Action: doNothing}
+// detectResourceDrift queries CCAPI for the live state of a resource and
+// compares it to the model stored in the state file
+func detectResourceDrift(resourceName string, resourceNode *yaml.Node, model *yaml.Node, opts DriftOptions, schemas *schemaCache) (*ResourceDrift, error) {
 
 	_, t, _ := s11n.GetMapValue(resourceNode, "Type")
 	if t == nil {
-		return retval, fmt.Errorf("resource %s expected to have Type", resourceName)
+		return nil, fmt.Errorf("resource %s expected to have Type", resourceName)
 	}
-	_, id, _ := s11n.GetMapValue(model, "Identifier")
-	if id == nil {
-		return retval, fmt.Errorf("resource model %s expected to have Identifier", resourceName)
+	// A resource whose creation did not finish might not have an identifier yet.
+	// There is nothing to query, but the other resources can still be checked.
+	_, idNode, _ := s11n.GetMapValue(model, "Identifier")
+	if idNode == nil || (idNode.Kind == yaml.ScalarNode && idNode.Value == "") {
+		config.Debugf("%s has no Identifier", resourceName)
+		return &ResourceDrift{
+			Name:       resourceName,
+			Type:       t.Value,
+			Incomplete: true,
+			Warning:    "the resource model has no Identifier, so the resource may not have been created",
+			node:       resourceNode,
+			resource:   &Resource{Name: resourceName, Type: t.Value, Node: resourceNode},
+		}, nil
 	}
-	title := fmt.Sprintf("%s (%s %s)", resourceName, t.Value, id.Value)
 
-	spinner.Push(fmt.Sprintf("Querying CCAPI: %s", title))
+	// The identifier belongs to the type the resource was deployed as,
+	// which is recorded with its model, and might not be the type in the template
+	storedType := t.Value
+	if _, typeNode, _ := s11n.GetMapValue(model, "Type"); typeNode != nil && typeNode.Value != "" {
+		storedType = typeNode.Value
+	}
 
-	liveModelJson, err := ccapi.GetResource(id.Value, t.Value)
+	id, err := resourceIdentifier(storedType, idNode, schemas)
 	if err != nil {
-		return retval, err
+		return nil, fmt.Errorf("resource model %s: %v", resourceName, err)
+	}
+
+	// A resource that was deleted outside of rain has drifted, it's not an error
+	deleted := false
+	liveModelJson := "{}"
+	arn := ""
+	opts.traceCCAPI("GetResource request for %s: TypeName=%s Identifier=%s", resourceName, storedType, id)
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	// A query that fails or times out is reported for this resource, so that the others can still be checked
+	queryError := ""
+	live, err := ccapi.GetResourceWithOptions(ctx, id, storedType, opts.readOptions())
+	if ccapi.IsNotFound(err) {
+		config.Debugf("%s was not found: %v", resourceName, err)
+		deleted = true
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		config.Debugf("%s timed out: %v", resourceName, err)
+		queryError = fmt.Sprintf("the Cloud Control API query timed out after %v", opts.Timeout)
+	} else if err != nil {
+		config.Debugf("%s failed: %v", resourceName, err)
+		queryError = fmt.Sprintf("the Cloud Control API query failed: %v", err)
+	} else {
+		liveModelJson = live.Properties
+		arn = live.Arn
+		opts.traceCCAPI("GetResource response for %s: %s", resourceName, liveModelJson)
 	}
-	spinner.Pop()
 
 	_, stateModel, _ := s11n.GetMapValue(model, "Model")
 	if stateModel == nil {
-		return retval, fmt.Errorf("expected State %s to have Model", resourceName)
+		return nil, fmt.Errorf("%w: expected State %s to have Model", ErrResourceModelMissing, resourceName)
 	}
 
-	var liveModelMap map[string]any
-	err = json.Unmarshal([]byte(liveModelJson), &liveModelMap)
+	// A missing or unreadable live model is treated like a deleted resource
+	warning := ""
+	liveModelMap, err := parseLiveModel(t.Value, id, liveModelJson)
 	if err != nil {
-		return retval, err
+		config.Debugf("%s: %v", resourceName, err)
+		deleted = true
+		warning = err.Error()
+		liveModelJson = "{}"
+		liveModelMap = make(map[string]any)
 	}
 
+	// Tags that AWS adds itself were never in the template
+	liveModelMap = withoutManagedTags(liveModelMap, opts.ManagedTagPrefixes)
+
 	var modelMap map[string]any
 	err = stateModel.Decode(&modelMap)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
+	modelMap = resolveStoredModel(modelMap, opts)
 	stateModelJsonb, _ := json.Marshal(modelMap)
 	stateModelJson := string(stateModelJsonb)
 
+	// The template's resource can't be compared to a model of another type
+	typeChanged := storedType != t.Value
+	if typeChanged {
+		warning = fmt.Sprintf("the resource was deployed as %s, but the template has type %s", storedType, t.Value)
+		liveModelMap = modelMap
+		liveModelJson = stateModelJson
+	}
+
+	// Without live state there is nothing to compare, so the stored model stands in for it
+	if queryError != "" {
+		liveModelMap = modelMap
+		liveModelJson = stateModelJson
+	}
+
+	// Properties that were never stored, like a read only Arn, are left out
+	if opts.StoredKeysOnly && !deleted {
+		liveModelMap = storedKeysOnly(modelMap, liveModelMap).(map[string]any)
+	}
+
 	// In order to resolve intrinsics, we need to store the resources
 	// in the global resMap as *Resource pointers
 	r := &Resource{
 		Name:       resourceName,
 		Type:       t.Value,
 		Node:       resourceNode,
-		Identifier: id.Value,
+		Identifier: id,
 		Model:      stateModelJson,
 		PriorJson:  liveModelJson,
 	}
 
-	retval.DeploymentResource = r
+	// With --scope-config, only the chosen properties are compared,
+	// but the whole models are kept for the changes that are made
+	scope := opts.Scope.paths(resourceName, t.Value)
+	d := compareModels(scopeModel(modelMap, scope), scopeModel(liveModelMap, scope), opts)
+	differ, compared := diff.LeafCounts(d)
+
+	return &ResourceDrift{
+		Name:        resourceName,
+		Type:        t.Value,
+		Identifier:  id,
+		Arn:         arn,
+		Drifted:     deleted || typeChanged || d.Mode() != diff.Unchanged,
+		Deleted:     deleted,
+		TypeChanged: typeChanged,
+		Warning:     warning,
+		Error:       queryError,
+		DriftRatio:  diff.DriftRatio(d),
+		Differences: newPropertyDiffs(d, scopeModel(modelMap, scope)),
+		diff:        d,
+		liveModel:   liveModelMap,
+		stateModel:  modelMap,
+		scope:       scope,
+		node:        resourceNode,
+		resource:    r,
+
+		differ:         differ,
+		compared:       compared,
+		caseMismatches: keyCaseMismatches(stateModel, liveModelMap),
+	}, nil
+}
 
-	// Also store a reference in the global map for later if we
-	// need to resolve intrinsics
-	resMap[resourceName] = r
+// traceCCAPI writes a Cloud Control API request or response to stderr if opts.Verbose is set,
+// so that it is kept apart from the report and the rest of the --debug output
+func (opts DriftOptions) traceCCAPI(message string, parts ...any) {
+	if opts.Verbose {
+		fmt.Fprintln(os.Stderr, console.Grey("CCAPI "+fmt.Sprintf(message, parts...)))
+	} else {
+		config.Debugf("CCAPI "+message, parts...)
+	}
+}
+
+// parseLiveModel decodes the JSON model returned by Cloud Control API,
+// returning an error that names the resource if the model is empty or not a JSON object
+func parseLiveModel(typeName string, identifier string, liveModelJson string) (map[string]any, error) {
+	if strings.TrimSpace(liveModelJson) == "" {
+		return nil, fmt.Errorf("CloudControl returned no model for %s %s; the resource may have been deleted",
+			typeName, identifier)
+	}
 
-	d := diff.CompareMaps(modelMap, liveModelMap)
+	var liveModelMap map[string]any
+	err := json.Unmarshal([]byte(liveModelJson), &liveModelMap)
+	if err != nil || liveModelMap == nil {
+		return nil, fmt.Errorf("CloudControl returned an invalid model for %s %s; the resource may have been deleted: %q",
+			typeName, identifier, liveModelJson)
+	}
+
+	return liveModelMap, nil
+}
+
+// keyCaseMismatches returns a description of each top level key in the
+// stored model whose casing differs from the matching key in the live model.
+// These show up in the diff as one property removed and another added.
+func keyCaseMismatches(stateModel *yaml.Node, liveModel map[string]any) []string {
+	retval := make([]string, 0)
+	for key := range liveModel {
+		storedKey, _, mismatch, err := s11n.GetMapValueFold(stateModel, key)
+		if err == nil && mismatch {
+			retval = append(retval, fmt.Sprintf("%s (live: %s)", storedKey.Value, key))
+		}
+	}
+	slices.Sort(retval)
+	return retval
+}
+
+// handleDrift shows the drift for a resource in the named deployment and asks the user what to do about it
+func handleDrift(w io.Writer, deployment string, rd *ResourceDrift) (selection, error) {
+
+	resourceName := rd.Name
+	resourceNode := rd.node
+	retval := selection{ResourceName: resourceName, Action: doNothing}
+
+	retval.DeploymentResource = rd.resource
+	retval.Drifted = rd.Drifted
+	title := resourceTitle(rd)
+	d := rd.diff
+	liveModelMap := rd.liveModel
+	modelMap := rd.stateModel
 
 	liveIcon := "⚡"
 	storedIcon := "📄"
@@ -334,71 +1351,200 @@ func handleDrift(resourceName string, resourceNode *yaml.Node, model *yaml.Node)
 	// 	resourceIcon = "-> "
 	// }
 
-	if d.Mode() == diff.Unchanged {
-		fmt.Println(console.Green(resourceIcon + title + "... Ok!"))
+	if rd.Incomplete {
+		// There is no identifier to query, so there is nothing to choose
+		fmt.Fprintln(w, console.Yellow(resourceIcon+title+"... Incomplete!"))
+		fmt.Fprintln(w, "    "+rd.Warning)
+		fmt.Fprintln(w)
+		return retval, nil
+	} else if rd.Error != "" {
+		// The live state is unknown, so there is nothing to choose
+		fmt.Fprintln(w, console.Yellow(resourceIcon+title+"... Error!"))
+		fmt.Fprintln(w, "    "+rd.Error)
+		fmt.Fprintln(w)
+		return retval, nil
+	} else if rd.TypeChanged {
+		// The live state is a different kind of resource, so neither side can be copied to the other
+		fmt.Fprintln(w, console.Red(severityLabel(rd.Severity)+resourceIcon+title+"... Resource type changed!"))
+		fmt.Fprintln(w, "    "+rd.Warning)
+		fmt.Fprintln(w)
+	} else if rd.Deleted {
+		// There is no live state to change or copy, so there is nothing to choose
+		fmt.Fprintln(w, console.Red(severityLabel(rd.Severity)+resourceIcon+title+"... Deleted outside of rain (drift)!"))
+		if rd.Warning != "" {
+			fmt.Fprintln(w, "    "+rd.Warning)
+		} else {
+			fmt.Fprintln(w, "    Cloud Control API could not find the resource, so it was deleted outside of rain")
+		}
+		fmt.Fprintln(w)
+	} else if d.Mode() == diff.Unchanged {
+		if !printOk(w, rd) {
+			return retval, nil
+		}
 	} else {
-		fmt.Println(console.Red(resourceIcon + title + "... Drift detected!"))
-		fmt.Println()
+		printResourceDiff(w, rd, diffLabels{
+			status:     "Drift detected!",
+			old:        storedIcon + " Stored state",
+			new:        liveIcon + " Live state",
+			oldHeading: storedIcon + " Stored state " + storedIcon,
+			newHeading: liveIcon + " Live state " + liveIcon,
+		})
+
+		// Use the recorded decision, or ask the user what to do
+		act, recorded, replay := driftDecisions.lookup(deployment, rd.Name, rd.Type)
+		if replay && recorded {
+			fmt.Fprintf(w, "Using the decision in %s for %s: %s\n", driftDecisionsFile, resourceName, actionNames[act])
+		} else if replay {
+			fmt.Fprintf(w, "There is no decision in %s for %s, so it is left as it is\n", driftDecisionsFile, resourceName)
+		} else {
+			var err error
+			act, err = promptAction(w, resourceName, checkIcon)
+			if err != nil {
+				return retval, err
+			}
+			driftDecisions.record(deployment, rd.Name, rd.Type, act)
+		}
 
-		// Show a diff of the live state and stored state
-		fmt.Println("    ========== " + liveIcon + " Live state " + liveIcon + " ==========")
-		fmt.Println("   ", colorDiff(d.Format(true)))
-		reverse := diff.CompareMaps(liveModelMap, modelMap)
-		fmt.Println("    ========== " + storedIcon + " Stored state " + storedIcon + " ==========")
-		fmt.Println("   ", colorDiff(reverse.Format(true)))
+		retval.Action = act
+		retval.LiveModel = liveModelMap
+		retval.StateModel = modelMap
+		retval.ResourceIdentifier = rd.Identifier
+		retval.ResourceNode = resourceNode
+		retval.ResourceType = rd.Type
 
-		// Ask the user that they want to do
+	}
 
-		selections := []selection{
-			{Action: changeLiveState, Text: "Change the live state so it matches the state file (changes your infrastructure!)"},
-			{Action: changeStateFile, Text: "Change the state file so that it matches live state"},
-			{Action: doNothing, Text: "Do nothing"},
-		}
+	fmt.Fprintln(w)
+	return retval, nil
+}
 
-		activeFormat := " {{ .Text | magenta }}"
-		selectedFormat := " {{ .Text | blue }}"
+// printOk shows that a resource has not drifted, and returns false
+// if nothing was shown because --diff-only is set
+func printOk(w io.Writer, rd *ResourceDrift) bool {
+	if driftDiffOnly {
+		return false
+	}
+	fmt.Fprintln(w, console.Green("🔎 "+resourceTitle(rd)+"... Ok!"))
+	return true
+}
 
-		if console.NoColour {
-			activeFormat = " {{ .Text }}"
-			selectedFormat = " {{ .Text }}"
-		}
+// diffLabels are the words printResourceDiff uses for a changed resource
+// and its two models, which are the stored and live models for drift,
+// or the two stored models for --against
+type diffLabels struct {
+	// status follows the resource's title, e.g. Drift detected!
+	status string
+
+	// old and new label the models in a --unified diff
+	old string
+	new string
+
+	// oldHeading and newHeading head the diffs of each model against
+	// the other. The old model's diff is not shown if oldHeading is empty.
+	oldHeading string
+	newHeading string
+}
 
-		prompt := promptui.Select{
-			Label: fmt.Sprintf("What would you like to do with %s?", resourceName),
-			Items: selections,
-			Templates: &promptui.SelectTemplates{
-				Label:    "{{ . }}",
-				Active:   checkIcon + activeFormat,
-				Inactive: "   {{ .Text }}",
-				Selected: checkIcon + selectedFormat,
-			},
-		}
+// printResourceDiff shows how the two models of a changed resource differ:
+// a summary of the changed properties, then a diff limited to the scoped
+// properties, as a --unified diff or a formatted one
+func printResourceDiff(w io.Writer, rd *ResourceDrift, labels diffLabels) {
+	summary := rd.diff.Summary()
+	fmt.Fprintln(w, colorSeverity(rd.Severity, severityLabel(rd.Severity)+"🔎 "+resourceTitle(rd)+"... "+labels.status))
+	if rd.Warning != "" {
+		fmt.Fprintln(w, "    "+rd.Warning)
+	}
+	if rd.Arn != "" {
+		fmt.Fprintf(w, "    ARN: %s\n", rd.Arn)
+	}
+	fmt.Fprintf(w, "    %d properties differ (%s)\n", summary.Total(), summary)
+	if driftScore {
+		fmt.Fprintf(w, "    Drift score: %s\n", formatScore(rd))
+	}
+	printChanges(w, summary.Changes, rd.Differences)
+	if len(rd.caseMismatches) > 0 {
+		fmt.Fprintln(w, console.Yellow("    Warning: stored keys differ from live keys only by case: "+
+			strings.Join(rd.caseMismatches, ", ")))
+	}
+	fmt.Fprintln(w)
 
-		idx, _, err := prompt.Run()
+	if driftUnified >= 0 {
+		fmt.Fprintln(w, "    --- "+labels.old)
+		fmt.Fprintln(w, "    +++ "+labels.new)
+		printUnified(w, diff.UnifiedDiff(rd.diff, driftUnified))
+		return
+	}
 
-		if err != nil {
-			fmt.Printf("Prompt failed %v\n", err)
-			return retval, err
-		}
+	fmt.Fprintln(w, "    ========== "+labels.newHeading+" ==========")
+	printDiff(w, diff.FormatCollapsed(rd.diff, true, driftCollapse))
+	if labels.oldHeading != "" {
+		oldModel := scopeModel(rd.stateModel, rd.scope)
+		newModel := scopeModel(rd.liveModel, rd.scope)
+		reverse := compareModels(newModel, oldModel, driftOptions())
+		fmt.Fprintln(w, "    ========== "+labels.oldHeading+" ==========")
+		printDiff(w, diff.FormatCollapsed(reverse, true, driftCollapse))
+	}
+}
 
-		retval.Action = selections[idx].Action
-		retval.LiveModel = liveModelMap
-		retval.StateModel = modelMap
-		retval.ResourceIdentifier = id.Value
-		retval.ResourceNode = resourceNode
-		retval.ResourceType = t.Value
+// promptAction asks the user what to do with a drifted resource
+func promptAction(w io.Writer, resourceName string, checkIcon string) (action, error) {
+	selections := []selection{
+		{Action: changeLiveState, Text: "Change the live state so it matches the state file (changes your infrastructure!)"},
+		{Action: changeStateFile, Text: "Change the state file so that it matches live state"},
+		{Action: doNothing, Text: "Do nothing"},
+	}
+
+	activeFormat := " {{ .Text | magenta }}"
+	selectedFormat := " {{ .Text | blue }}"
 
+	if !console.HasColour() {
+		activeFormat = " {{ .Text }}"
+		selectedFormat = " {{ .Text }}"
 	}
 
-	fmt.Println()
-	return retval, nil
+	prompt := promptui.Select{
+		Label: fmt.Sprintf("What would you like to do with %s?", resourceName),
+		Items: selections,
+		Templates: &promptui.SelectTemplates{
+			Label:    "{{ . }}",
+			Active:   checkIcon + activeFormat,
+			Inactive: "   {{ .Text }}",
+			Selected: checkIcon + selectedFormat,
+		},
+	}
+
+	idx, _, err := prompt.Run()
+
+	if err != nil {
+		fmt.Fprintf(w, "Prompt failed %v\n", err)
+		return doNothing, err
+	}
+
+	return selections[idx].Action, nil
 }
 
-// colorDiff hacks the diff output to colorize it
-func colorDiff(s string) string {
+// resourceTitle names a resource in the drift output,
+// e.g. MyBucket (AWS::S3::Bucket my-bucket), and notes if it is retained
+func resourceTitle(rd *ResourceDrift) string {
+	title := fmt.Sprintf("%s (%s %s)", rd.Name, rd.Type, rd.Identifier)
+	if rd.Retained {
+		title += " [retained]"
+	}
+	return title
+}
+
+// colorDiff hacks the diff output to colorize it.
+// Added lines are green, removed lines are red, and changed lines are yellow.
+// Values that changed type are magenta, since they are usually the most important.
+// Lines wider than width are wrapped, with the continuation lines indented
+// under the start of the value, unless width is 0 or less.
+func colorDiff(s string, width int) string {
 	lines := strings.Split(s, "\n")
 	f := "%s "
-	unchanged := fmt.Sprintf(f, diff.Unchanged)
+	added := fmt.Sprintf(f, diff.Added)
+	removed := fmt.Sprintf(f, diff.Removed)
+	changed := fmt.Sprintf(f, diff.Changed)
+	typeChanged := fmt.Sprintf(f, diff.TypeChanged)
 	ret := make([]string, 0)
 	for _, line := range lines {
 		// Lines look like these:
@@ -407,36 +1553,244 @@ func colorDiff(s string) string {
 		tokens := strings.SplitAfterN(line, " ", 2)
 		if len(tokens) != 2 {
 			ret = append(ret, console.Yellow(line)) // Shouldn't happen
-		} else {
-			if tokens[0] == unchanged {
-				ret = append(ret, console.Green(tokens[1]))
-			} else {
-				if console.NoColour {
-					ret = append(ret, "! "+tokens[1])
-				} else {
-					ret = append(ret, console.Red(tokens[1]))
-				}
+			continue
+		}
+
+		wrapped := console.Wrap(tokens[1], width, valueIndent(tokens[1]))
+
+		var marker string
+		var colour func(...interface{}) string
+		switch tokens[0] {
+		case added:
+			marker, colour = "+ ", console.Green
+		case removed:
+			marker, colour = "- ", console.Red
+		case changed:
+			marker, colour = "! ", console.Yellow
+		case typeChanged:
+			marker, colour = "~ ", console.Magenta
+		default:
+			// Unchanged values and the parents of changed values
+			for _, w := range wrapped {
+				ret = append(ret, console.Plain(w))
+			}
+			continue
+		}
+
+		// Only the first line gets a marker, so the continuation lines stay aligned
+		for i, w := range wrapped {
+			switch {
+			case console.HasColour():
+				ret = append(ret, colour(w))
+			case i == 0:
+				ret = append(ret, marker+w)
+			default:
+				ret = append(ret, w)
 			}
 		}
 	}
 	retval := strings.Join(ret, "\n    ")
-	if console.NoColour {
-		// Offset the ! so it stands out and the props are still aligned
-		retval = strings.Replace(retval, "    ! ", "  ! ", -1)
+	if !console.HasColour() {
+		// Offset the markers so they stand out and the props are still aligned
+		for _, marker := range []string{"+ ", "- ", "! ", "~ "} {
+			retval = strings.Replace(retval, "    "+marker, "  "+marker, -1)
+		}
 	}
 	return retval
 }
 
+// valueIndent returns how far a line of diff output is indented to where its value starts,
+// which is after the key for a key and value, or after the list marker for a list item
+func valueIndent(line string) int {
+	trimmed := strings.TrimLeft(line, " ")
+	indent := len(line) - len(trimmed)
+	if strings.HasPrefix(trimmed, "- ") {
+		return indent + 2
+	}
+	if i := strings.Index(trimmed, ": "); i >= 0 {
+		return indent + len([]rune(trimmed[:i])) + 2
+	}
+	return indent + 2
+}
+
+// diffWidth returns the width that lines of diff output are wrapped to, after the
+// indentation they are printed with, or 0 for --no-wrap
+func diffWidth() int {
+	if driftNoWrap {
+		return 0
+	}
+	return console.Width() - 4
+}
+
+// colorUnified indents and colours the output of diff.Unified,
+// with removed lines in red, added lines in green, and hunk headers in cyan
+func colorUnified(s string) string {
+	ret := make([]string, 0)
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			line = console.Cyan(line)
+		case strings.HasPrefix(line, "-"):
+			line = console.Red(line)
+		case strings.HasPrefix(line, "+"):
+			line = console.Green(line)
+		}
+		ret = append(ret, "    "+line)
+	}
+	return strings.Join(ret, "\n")
+}
+
+// diffLimit returns the number of changed lines of each diff to show, set by
+// --context, or 0 to show the whole diff. --verbose shows the whole diff.
+func diffLimit() int {
+	if driftVerbose {
+		return 0
+	}
+	return max(driftContext, 0)
+}
+
+// limitDiff returns the lines of s up to and including the limit-th line
+// that changed returns true for, and the number of lines that were left out.
+// Nothing is left out if limit is 0.
+func limitDiff(s string, limit int, changed func(line string) bool) (string, int) {
+	if limit <= 0 {
+		return s, 0
+	}
+
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	count := 0
+	for i, line := range lines {
+		if !changed(line) {
+			continue
+		}
+		count++
+		if count == limit {
+			return strings.Join(lines[:i+1], "\n"), len(lines) - i - 1
+		}
+	}
+
+	return s, 0
+}
+
+// isChangedLine returns true for a line of diff.Format output
+// that shows a value that was added, removed, or changed
+func isChangedLine(line string) bool {
+	for _, mode := range []diff.Mode{diff.Added, diff.Removed, diff.Changed, diff.TypeChanged} {
+		if strings.HasPrefix(line, fmt.Sprintf("%s ", mode)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnifiedChange returns true for a removed or added line of diff.Unified output
+func isUnifiedChange(line string) bool {
+	return strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+")
+}
+
+// printDiff prints the output of diff.Format, limited by --context
+func printDiff(w io.Writer, s string) {
+	s, omitted := limitDiff(s, diffLimit(), isChangedLine)
+	fmt.Fprintln(w, "   ", colorDiff(s, diffWidth()))
+	printOmitted(w, omitted)
+}
+
+// printUnified prints the output of diff.Unified, limited by --context
+func printUnified(w io.Writer, s string) {
+	s, omitted := limitDiff(s, diffLimit(), isUnifiedChange)
+	fmt.Fprintln(w, colorUnified(s))
+	printOmitted(w, omitted)
+}
+
+// printOmitted says how many lines of a diff were not shown
+func printOmitted(w io.Writer, omitted int) {
+	if omitted > 0 {
+		fmt.Fprintln(w, console.Grey(fmt.Sprintf("    (… %d more lines …)", omitted)))
+	}
+}
+
 var CCDriftCmd = &cobra.Command{
-	Use:   "drift <name>",
+	Use:   "drift <name> | --all",
 	Short: "Compare the state file to the live state of the resources",
 	Long: `When deploying templates with the cc command, a state file is created and stored in the rain assets bucket. This command outputs a diff of that file and the actual state of the resources, according to Cloud Control API. You can then apply the changes by changing the live state, or by modifying the state file.
+
+For each resource that has drifted, you will be asked what to do:
+
+  1. Change the live state so it matches the state file
+  2. Change the state file so that it matches live state
+  3. Do nothing
+
+Your choices are summarized at the end and nothing is changed until you confirm. Pass --yes to skip the confirmation, or --plan to review what the choices would do without making them.
+
+A resource that was deleted outside of rain, or whose type in the template no longer matches its stored model, is reported as drift. A resource that can't be queried, for example because it has no Identifier or the query failed, is reported with an error and the other resources are still checked.
+
+With --summary, --against, or --output json or yaml, no questions are asked and nothing is changed.
+
+Use --fail-on to control whether errors and drift change the exit status, for example to fail a CI pipeline step:
+
+  none   Exit with status 0, even if drift is found or can't be checked (the default)
+  error  Exit with status 1 if drift can't be checked
+  drift  Also exit with status 2 if any resource has drifted, or 5 if the type of a resource changed
+
+With --severity-config and --fail-on drift, the exit status is 2 if the highest severity of the drift is info, 3 if it is warn, and 4 if it is critical. Invalid arguments and flags always exit with status 1.
+
+With --output json or yaml, --fail-on defaults to drift.
 `,
-	Args:                  cobra.ExactArgs(1),
+	Args:                  cobra.MaximumNArgs(1),
 	DisableFlagsInUseLine: true,
 	Run:                   runDrift,
 }
 
 func init() {
+	CCDriftCmd.Flags().BoolVarP(&yes, "yes", "y", false, "don't ask for confirmation before applying the selected changes")
+	CCDriftCmd.Flags().StringSliceVar(&driftResources, "resource", []string{}, "Only check the resource with this logical id; repeat the flag to check several resources")
+	CCDriftCmd.Flags().StringVar(&driftStateFile, "state-file", "", "Read the state from this local file instead of the rain bucket; chosen state file changes are written back to it")
+	CCDriftCmd.Flags().StringVar(&driftBucket, "bucket", "", "Read the state file from this bucket instead of the rain bucket")
+	CCDriftCmd.Flags().StringVar(&driftPrefix, "prefix", "", "Read the state file from this folder in the bucket instead of deployments/")
+	CCDriftCmd.Flags().StringSliceVar(&driftIncludeTypes, "include-type", []string{}, "Only check resources of this type, e.g. AWS::S3::Bucket; repeat the flag to check several types")
+	CCDriftCmd.Flags().StringSliceVar(&driftExcludeTypes, "exclude-type", []string{}, "Don't check resources of this type; repeat the flag to skip several types")
+	CCDriftCmd.Flags().StringSliceVar(&driftIgnore, "ignore", []string{}, "Don't report drift for this property path, e.g. Tags/0/Value; repeat the flag to ignore several paths")
+	CCDriftCmd.Flags().BoolVar(&driftSummary, "summary", false, "Print one line for each resource instead of the full diff, without asking what to do")
+	CCDriftCmd.Flags().IntVar(&ccapi.MaxRetries, "max-retries", 3, "Maximum number of times to retry a throttled CCAPI query")
+	CCDriftCmd.Flags().BoolVar(&driftDetectOrphans, "detect-orphans", false, "Also list live resources of the deployment's types that are not in the state file")
+	CCDriftCmd.Flags().DurationVar(&driftSince, "since", 0, "Skip drift detection if the deployment was written less than this long ago, e.g. 30m")
+	CCDriftCmd.Flags().StringToStringVar(&driftIdentityKeys, "identity-key", defaultIdentityKeys, "Match the elements of the list at a property path by a key instead of by position, as path=key")
+	CCDriftCmd.Flags().BoolVar(&driftAll, "all", false, "Check every deployment in the rain bucket instead of a single named deployment; with --output json or yaml, the reports are printed as a list")
+	CCDriftCmd.Flags().BoolVar(&driftPlan, "plan", false, "Show what the selected changes would do without making them")
+	CCDriftCmd.Flags().IntVar(&driftUnified, "unified", -1, "Show drift as a unified diff with this many lines of context, like diff -u")
+	CCDriftCmd.Flags().Lookup("unified").NoOptDefVal = "3"
+	CCDriftCmd.Flags().BoolVar(&driftScore, "score", false, "Show the fraction of each resource's properties that have drifted, and a total for the deployment")
+	CCDriftCmd.Flags().BoolVar(&driftRecord, "record", false, "Append a summary of the results to the drift history next to the state file")
+	CCDriftCmd.Flags().StringVar(&driftAgainst, "against", "", "Compare the state file to another state file, a local path or a deployment name, instead of to live state")
+	CCDriftCmd.Flags().BoolVar(&driftVerbose, "verbose", false, "Log each Cloud Control API request and the raw live model it returns")
+	CCDriftCmd.Flags().BoolVar(&driftCreateBucket, "create-bucket", false, "Create the rain bucket if it does not exist, instead of failing")
+	CCDriftCmd.Flags().BoolVar(&driftIgnoreManagedTags, "ignore-managed-tags", false, "Leave tags that AWS adds itself, like aws:cloudformation:stack-name, out of the comparison")
+	CCDriftCmd.Flags().StringSliceVar(&driftManagedTagPrefixes, "managed-tag-prefix", defaultManagedTagPrefixes, "A tag key prefix that --ignore-managed-tags leaves out; repeat the flag for several prefixes")
+	CCDriftCmd.Flags().IntVar(&driftContext, "context", 40, "Show each diff up to this many changed lines, or 0 to show the whole diff")
+	CCDriftCmd.Flags().DurationVar(&driftTimeout, "timeout", 30*time.Second, "Maximum time to wait for the live state of each resource, or 0 to wait indefinitely")
+	CCDriftCmd.Flags().BoolVar(&driftBootstrap, "bootstrap", false, "Write a new State section from live state if the state file has none")
+	CCDriftCmd.Flags().BoolVar(&driftNoVerify, "no-verify", false, "Do not check the state file against the checksum it was written with")
+	CCDriftCmd.Flags().BoolVar(&driftNoWrap, "no-wrap", false, "Do not wrap long values in diffs to the width of the terminal")
+	CCDriftCmd.Flags().BoolVar(&driftPager, "pager", false, "Show the output in $PAGER, or less -R, when it is a terminal; only with --summary, --against, or --decisions and --yes")
+	CCDriftCmd.Flags().BoolVar(&driftNoPager, "no-pager", false, "Print the output directly, even with --pager")
+	CCDriftCmd.Flags().StringVar(&config.EndpointURL, "endpoint-url", "", "Send Cloud Control API, S3, STS, and CloudFormation requests to this URL instead of the AWS endpoints, e.g. for LocalStack")
+	CCDriftCmd.Flags().Float64Var(&driftCollapse, "collapse", 0, "Show a block as one line if at least this fraction of its values changed, e.g. 1 for blocks where everything changed; not used with --unified")
+	CCDriftCmd.Flags().StringToStringVar(&driftFilterTags, "filter-tag", nil, "Only report resources whose live state has this tag, as key=value; repeat the flag to require several tags")
+	CCDriftCmd.Flags().StringVar(&driftScopeConfig, "scope-config", "", "YAML file that maps resource types and logical ids to the only property paths to compare")
+	CCDriftCmd.Flags().StringVar(&driftSeverityConfig, "severity-config", "", "YAML file with a Default severity and Rules that map a Path, and optionally a Type, to the severity of their drift: info, warn, or critical")
+	CCDriftCmd.Flags().Float64Var(&driftRate, "rate", 10, "Maximum number of Cloud Control API requests per second to start with, which is lowered while requests are throttled; 0 for no limit")
+	CCDriftCmd.Flags().BoolVar(&driftIgnoreValueCase, "ignore-value-case", false, "Treat string values that only differ by case, like ENABLED and Enabled, as unchanged")
+	CCDriftCmd.Flags().BoolVar(&driftStoredKeysOnly, "stored-keys-only", false, "Only compare the properties that are in the stored model, so that read only properties that are only in the live state are not drift")
+	CCDriftCmd.Flags().BoolVar(&driftDiffOnly, "diff-only", false, "Only show the resources that drifted, or could not be checked, followed by how many were Ok")
+	CCDriftCmd.Flags().BoolVar(&driftSkipRetained, "skip-retained", false, "Don't check resources with a DeletionPolicy of Retain or RetainExceptOnCreate")
+	CCDriftCmd.Flags().StringVar(&driftDecisionsFile, "decisions", "", "Record the choice made for each drifted resource in this JSON file, or make the choices in it again if it exists")
+	CCDriftCmd.Flags().StringVar(&ccapi.RoleArn, "assume-role", "", "Assume the role with this ARN for Cloud Control API requests; the state file is read with your own credentials")
+	CCDriftCmd.Flags().StringVar(&ccapi.ExternalID, "external-id", "", "The external id to pass when assuming the --assume-role role")
+	CCDriftCmd.Flags().StringVar(&driftAccountId, "account-id", "", "The AWS::AccountId that stored models refer to, instead of the account of the credentials or of --assume-role")
+	CCDriftCmd.Flags().BoolVar(&driftWatch, "watch", false, "Keep checking for drift every --interval and show a summary of each check, until interrupted")
+	CCDriftCmd.Flags().DurationVar(&driftInterval, "interval", 5*time.Minute, "How long to wait between the checks made by --watch")
+	CCDriftCmd.Flags().IntVar(&driftConcurrency, "concurrency", 5, "Maximum number of resources to query in parallel")
+	CCDriftCmd.Flags().StringVar(&driftFailOn, "fail-on", "none", "Set to error to exit with status 1 if drift can't be checked, or to drift to also exit with status 2 if any resource has drifted")
+	CCDriftCmd.Flags().StringVarP(&driftOutput, "output", "o", "", "Output format; set to 'json' or 'yaml' for a machine-readable report instead of the interactive diff")
 	addCommonParams(CCDriftCmd)
 }
@@ -0,0 +1,611 @@
+package cc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+)
+
+func TestSelectedResources(t *testing.T) {
+	template, err := parse.String(`
+Resources:
+  A:
+    Type: AWS::S3::Bucket
+  B:
+    Type: AWS::S3::Bucket
+  C:
+    Type: AWS::S3::Bucket
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, err := template.GetSection(cft.Resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := selectedResources(resources, []string{})
+	if err != nil || !slices.Equal(names, []string{"A", "B", "C"}) {
+		t.Errorf("expected all resources, got %v (%v)", names, err)
+	}
+
+	// Selected resources stay in template order
+	names, err = selectedResources(resources, []string{"C", "A"})
+	if err != nil || !slices.Equal(names, []string{"A", "C"}) {
+		t.Errorf("expected A and C, got %v (%v)", names, err)
+	}
+
+	if _, err = selectedResources(resources, []string{"D"}); err == nil {
+		t.Errorf("expected an error for a missing resource")
+	}
+}
+
+func TestFilterByType(t *testing.T) {
+	template, err := parse.String(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Role:
+    Type: AWS::IAM::Role
+  Queue:
+    Type: AWS::SQS::Queue
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, err := template.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := []string{"Bucket", "Role", "Queue"}
+
+	cases := []struct {
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{[]string{}, []string{}, all},
+		{[]string{"AWS::S3::Bucket", "AWS::IAM::Role"}, []string{}, []string{"Bucket", "Role"}},
+		{[]string{}, []string{"AWS::SQS::Queue"}, []string{"Bucket", "Role"}},
+		{[]string{"AWS::S3::Bucket", "AWS::IAM::Role"}, []string{"AWS::IAM::Role"}, []string{"Bucket"}},
+	}
+
+	for _, c := range cases {
+		names, skipped := filterByType(all, resources, c.include, c.exclude)
+		if !slices.Equal(names, c.expected) || skipped != len(all)-len(c.expected) {
+			t.Errorf("%v %v: got %v, %d skipped", c.include, c.exclude, names, skipped)
+		}
+	}
+}
+
+func TestFilterRetained(t *testing.T) {
+	template, err := parse.String(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    DeletionPolicy: Retain
+  Role:
+    Type: AWS::IAM::Role
+    DeletionPolicy: Delete
+  Table:
+    Type: AWS::DynamoDB::Table
+    DeletionPolicy: RetainExceptOnCreate
+  Queue:
+    Type: AWS::SQS::Queue
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, err := template.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := []string{"Bucket", "Role", "Table", "Queue"}
+
+	if names, skipped := filterRetained(all, resources, false); !slices.Equal(names, all) || skipped != 0 {
+		t.Errorf("expected nothing to be skipped, got %v, %d skipped", names, skipped)
+	}
+
+	expected := []string{"Role", "Queue"}
+	if names, skipped := filterRetained(all, resources, true); !slices.Equal(names, expected) || skipped != 2 {
+		t.Errorf("got %v, %d skipped", names, skipped)
+	}
+}
+
+func TestColorDiff(t *testing.T) {
+	defer func(n bool) { console.NoColour = n }(console.NoColour)
+	console.NoColour = true
+
+	input := "(=) A: 1\n(+) B: 2\n(-) C: 3\n(>) D: 4"
+	expected := "A: 1\n  + B: 2\n  - C: 3\n  ! D: 4"
+
+	if actual := colorDiff(input, 0); actual != expected {
+		t.Errorf("%q\n!=\n%q", actual, expected)
+	}
+
+	// Continuation lines are indented under the value, without a marker
+	input = "(=) A: 1\n(>)   Policy: one two three"
+	expected = "A: 1\n  !   Policy: one two\n              three"
+
+	if actual := colorDiff(input, 20); actual != expected {
+		t.Errorf("%q\n!=\n%q", actual, expected)
+	}
+}
+
+func TestKeyCaseMismatches(t *testing.T) {
+	template, err := parse.String(`
+BucketName: a
+tags: []
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live := map[string]any{"BucketName": "a", "Tags": []any{}, "Arn": "b"}
+
+	actual := keyCaseMismatches(template.Node.Content[0], live)
+	expected := []string{"tags (live: Tags)"}
+	if !slices.Equal(actual, expected) {
+		t.Errorf("%#v\n!=\n%#v\n", actual, expected)
+	}
+}
+
+func TestDriftReportErrors(t *testing.T) {
+	noState, err := parse.String(`
+Resources:
+  A:
+    Type: AWS::S3::Bucket
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = driftReport("test", noState, DriftOptions{})
+	if !errors.Is(err, ErrMissingSection) {
+		t.Errorf("expected ErrMissingSection, got %v", err)
+	}
+
+	noModel, err := parse.String(`
+Resources:
+  A:
+    Type: AWS::S3::Bucket
+State:
+  ResourceModels:
+    B:
+      Identifier: b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = driftReport("test", noModel, DriftOptions{})
+	if !errors.Is(err, ErrResourceModelMissing) {
+		t.Errorf("expected ErrResourceModelMissing, got %v", err)
+	}
+}
+
+func TestDriftReportIncomplete(t *testing.T) {
+	template, err := parse.String(`
+Resources:
+  A:
+    Type: AWS::S3::Bucket
+State:
+  ResourceModels:
+    A:
+      Model:
+        BucketName: a
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := driftReport("test", template, DriftOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Resources) != 1 {
+		t.Fatalf("expected 1 resource, got %d", len(report.Resources))
+	}
+	rd := report.Resources[0]
+	if !rd.Incomplete || rd.Drifted || rd.Type != "AWS::S3::Bucket" {
+		t.Errorf("unexpected result: %+v", rd)
+	}
+	if report.HasDrift() {
+		t.Errorf("an incomplete resource should not count as drift")
+	}
+}
+
+func TestDriftReportCounts(t *testing.T) {
+	template, err := parse.String(`
+Resources:
+  A:
+    Type: AWS::S3::Bucket
+  B:
+    Type: AWS::SQS::Queue
+  C:
+    Type: AWS::S3::Bucket
+State:
+  ResourceModels:
+    A:
+      Model: {}
+    B:
+      Model: {}
+    C:
+      Model: {}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := driftReport("test", template, DriftOptions{ExcludeTypes: []string{"AWS::SQS::Queue"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 3 || report.Processed != 2 || report.SkippedByType != 1 {
+		t.Errorf("unexpected counts: %d total, %d processed, %d skipped",
+			report.Total, report.Processed, report.SkippedByType)
+	}
+}
+
+func TestDiffOnly(t *testing.T) {
+	defer func(n bool, d bool) {
+		console.NoColour, driftDiffOnly = n, d
+	}(console.NoColour, driftDiffOnly)
+	buf := &bytes.Buffer{}
+	console.NoColour = true
+
+	model := map[string]any{"BucketName": "a"}
+	ok := &ResourceDrift{Name: "A", Type: "AWS::S3::Bucket", Identifier: "a",
+		diff: diff.CompareMaps(model, model)}
+
+	driftDiffOnly = true
+	if _, err := handleDrift(buf, "test", ok); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output for an unchanged resource, got %q", buf.String())
+	}
+
+	driftDiffOnly = false
+	if _, err := handleDrift(buf, "test", ok); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Ok!") {
+		t.Errorf("expected an Ok line, got %q", buf.String())
+	}
+
+	results := []*ResourceDrift{
+		ok,
+		{Name: "B", Drifted: true},
+		{Name: "C", Error: "timed out"},
+		{Name: "D", Incomplete: true},
+		{Name: "E"},
+	}
+	if n := okCount(results); n != 2 {
+		t.Errorf("expected 2 resources to be Ok, got %d", n)
+	}
+}
+
+func TestPrintDriftSummary(t *testing.T) {
+	defer func(n bool) { console.NoColour = n }(console.NoColour)
+	buf := &bytes.Buffer{}
+	console.NoColour = true
+
+	results := []*ResourceDrift{
+		{Name: "A", Type: "AWS::S3::Bucket", Identifier: "a"},
+		{Name: "B", Type: "AWS::S3::Bucket", Identifier: "b", Drifted: true},
+	}
+	if !printDriftSummary(buf, results) {
+		t.Errorf("expected drift")
+	}
+	for _, want := range []string{"A", "AWS::S3::Bucket", "Ok", "Drift", "Checked 2 resources, 1 drifted"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected the summary to contain %q:\n%s", want, buf.String())
+		}
+	}
+	if printDriftSummary(buf, results[:1]) {
+		t.Errorf("expected no drift")
+	}
+	deleted := []*ResourceDrift{
+		{Name: "C", Type: "AWS::S3::Bucket", Identifier: "c", Drifted: true, Deleted: true},
+	}
+	if !printDriftSummary(buf, deleted) {
+		t.Errorf("expected a deleted resource to count as drift")
+	}
+}
+
+func TestWrittenWithin(t *testing.T) {
+	template, err := parse.String(`
+Resources: {}
+State:
+  LastWriteTime: "2024-05-01T12:00:00Z"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 5, 1, 12, 20, 0, 0, time.UTC)
+
+	recent, _, err := writtenWithin(template, 30*time.Minute, now)
+	if err != nil || !recent {
+		t.Errorf("expected the deployment to be recent (%v)", err)
+	}
+
+	recent, _, err = writtenWithin(template, 10*time.Minute, now)
+	if err != nil || recent {
+		t.Errorf("expected the deployment not to be recent (%v)", err)
+	}
+}
+
+func TestDeploymentNames(t *testing.T) {
+	keys := []string{
+		"deployments/a.yaml",
+		"deployments/b.yaml",
+		"deployments/nested/c.yaml",
+		"deployments/notes.txt",
+		"other/d.yaml",
+	}
+	actual := deploymentNames(keys, "deployments/")
+	if !slices.Equal(actual, []string{"a", "b"}) {
+		t.Errorf("expected a and b, got %v", actual)
+	}
+}
+
+func TestDetectDriftStateFile(t *testing.T) {
+	_, err := DetectDrift("test", DriftOptions{StateFile: "does-not-exist.yaml"})
+	if !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("expected ErrStateNotFound, got %v", err)
+	}
+}
+
+func TestColorUnified(t *testing.T) {
+	defer func(n bool) { console.NoColour = n }(console.NoColour)
+	console.NoColour = true
+
+	input := "@@ -1,2 +1,2 @@\n A: 1\n-B: 2\n+B: 3\n"
+	expected := "    @@ -1,2 +1,2 @@\n     A: 1\n    -B: 2\n    +B: 3"
+
+	if actual := colorUnified(input); actual != expected {
+		t.Errorf("%#v\n!=\n%#v\n", actual, expected)
+	}
+}
+
+func TestParseLiveModel(t *testing.T) {
+	for _, input := range []string{"", "  ", "null", "Internal Failure", "[1]"} {
+		if _, err := parseLiveModel("AWS::S3::Bucket", "b", input); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+
+	m, err := parseLiveModel("AWS::S3::Bucket", "b", `{"BucketName": "b"}`)
+	if err != nil || m["BucketName"] != "b" {
+		t.Errorf("unexpected result %v (%v)", m, err)
+	}
+}
+
+func TestStateKey(t *testing.T) {
+	cases := map[string]string{
+		"":            "deployments/app.yaml",
+		"teams/web":   "teams/web/app.yaml",
+		"/teams/web/": "teams/web/app.yaml",
+	}
+
+	for prefix, expected := range cases {
+		if actual := stateKey("app", prefix); actual != expected {
+			t.Errorf("%s: %#v != %#v", prefix, actual, expected)
+		}
+	}
+}
+
+func TestDriftRatio(t *testing.T) {
+	results := []*ResourceDrift{
+		{Name: "A", DriftRatio: 0.5, differ: 1, compared: 2},
+		{Name: "B", DriftRatio: 0, differ: 0, compared: 6},
+	}
+
+	if r := driftRatio(results); r != 0.125 {
+		t.Errorf("expected 0.125, got %v", r)
+	}
+
+	if s := formatScore(results[0]); s != "50% (1 of 2 values)" {
+		t.Errorf("unexpected score %s", s)
+	}
+
+	if r := driftRatio([]*ResourceDrift{}); r != 0 {
+		t.Errorf("expected 0 with no resources, got %v", r)
+	}
+}
+
+func TestLimitDiff(t *testing.T) {
+	input := "@@ -1,4 +1,4 @@\n A: 1\n-B: 2\n+B: 3\n C: 4\n-D: 5\n+D: 6\n"
+
+	cases := []struct {
+		limit    int
+		expected string
+		omitted  int
+	}{
+		{0, input, 0},
+		{1, "@@ -1,4 +1,4 @@\n A: 1\n-B: 2", 4},
+		{3, "@@ -1,4 +1,4 @@\n A: 1\n-B: 2\n+B: 3\n C: 4\n-D: 5", 1},
+		{10, input, 0},
+	}
+
+	for _, c := range cases {
+		actual, omitted := limitDiff(input, c.limit, isUnifiedChange)
+		if actual != c.expected || omitted != c.omitted {
+			t.Errorf("%d: %#v, %d\n!=\n%#v, %d\n", c.limit, actual, omitted, c.expected, c.omitted)
+		}
+	}
+
+	if !isChangedLine("(>) A: 1") || isChangedLine("(=) A: 1") || isChangedLine("(|) A:") {
+		t.Errorf("unexpected isChangedLine result")
+	}
+}
+
+// mockCloudControl sends Cloud Control API requests to a server that answers
+// GetResource with the JSON properties in models, keyed by "type identifier",
+// or with the error code in failures. Any other resource is not found.
+func mockCloudControl(t *testing.T, models map[string]string, failures map[string]string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stored models are resolved for the account of the credentials
+		if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprint(w, `<GetCallerIdentityResponse><GetCallerIdentityResult><Account>123456789012</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`)
+			return
+		}
+		var input struct{ TypeName, Identifier string }
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			t.Errorf("unable to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		key := input.TypeName + " " + input.Identifier
+		if code, ok := failures[key]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"__type":"%s","message":"%s"}`, code, code)
+			return
+		}
+		props, ok := models[key]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type":"ResourceNotFoundException","message":"not found"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"TypeName": input.TypeName,
+			"ResourceDescription": map[string]any{
+				"Identifier": input.Identifier,
+				"Properties": props,
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+
+	endpoint := config.EndpointURL
+	config.EndpointURL = server.URL
+	t.Cleanup(func() { config.EndpointURL = endpoint })
+}
+
+func TestDetectResourceDriftTypeChanged(t *testing.T) {
+	mockCloudControl(t, map[string]string{
+		"AWS::SQS::Queue q": `{"QueueName":"q"}`,
+	}, nil)
+
+	detect := func(templateType string) *ResourceDrift {
+		template, err := parse.String(fmt.Sprintf(`
+Resources:
+  Queue:
+    Type: %s
+State:
+  ResourceModels:
+    Queue:
+      Identifier: q
+      Type: AWS::SQS::Queue
+      Model:
+        QueueName: q
+`, templateType))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resources, err := template.Resources()
+		if err != nil {
+			t.Fatal(err)
+		}
+		models, err := template.GetNode(cft.State, "ResourceModels")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, model, _ := s11n.GetMapValue(models, "Queue")
+		rd, err := detectResourceDrift("Queue", resources["Queue"], model, DriftOptions{}, newSchemaCache())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rd
+	}
+
+	if rd := detect("AWS::SQS::Queue"); rd.TypeChanged || rd.Drifted || rd.Deleted {
+		t.Errorf("expected no drift: %+v", rd)
+	}
+
+	rd := detect("AWS::SNS::Topic")
+	if !rd.TypeChanged || !rd.Drifted || rd.Deleted || rd.Type != "AWS::SNS::Topic" {
+		t.Errorf("expected the type to have changed: %+v", rd)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := handleDrift(buf, "test", rd); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Resource type changed!") ||
+		!strings.Contains(buf.String(), "deployed as AWS::SQS::Queue") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestDetectAllQueryError(t *testing.T) {
+	mockCloudControl(t, map[string]string{
+		"AWS::SQS::Queue a": `{"QueueName":"a"}`,
+	}, map[string]string{
+		"AWS::SQS::Queue b": "AccessDeniedException",
+	})
+
+	template, err := parse.String(`
+Resources:
+  A:
+    Type: AWS::SQS::Queue
+  B:
+    Type: AWS::SQS::Queue
+State:
+  ResourceModels:
+    A:
+      Identifier: a
+      Model:
+        QueueName: a
+    B:
+      Identifier: b
+      Model:
+        QueueName: b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := driftReport("test", template, DriftOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Resources) != 2 {
+		t.Fatalf("expected both resources to be checked, got %d", len(report.Resources))
+	}
+	for _, rd := range report.Resources {
+		switch rd.Name {
+		case "A":
+			if rd.Error != "" || rd.Drifted {
+				t.Errorf("unexpected result for A: %+v", rd)
+			}
+		case "B":
+			if !strings.Contains(rd.Error, "AccessDeniedException") || rd.Drifted {
+				t.Errorf("unexpected result for B: %+v", rd)
+			}
+		}
+	}
+}
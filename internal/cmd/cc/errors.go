@@ -0,0 +1,23 @@
+package cc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by cc drift. They are usually wrapped with more
+// detail, so use errors.Is to check for them.
+var (
+	// ErrStateNotFound means the state file could not be downloaded
+	ErrStateNotFound = errors.New("unable to download state")
+
+	// ErrMissingSection means the state file is missing something drift needs
+	ErrMissingSection = errors.New("state file is incomplete")
+
+	// ErrMissingState means the state file has no State section, usually because
+	// it was deployed before state was tracked. It is also an ErrMissingSection.
+	ErrMissingState = fmt.Errorf("%w: state file missing required key 'State'", ErrMissingSection)
+
+	// ErrResourceModelMissing means a resource has no model in the state file
+	ErrResourceModelMissing = errors.New("resource model missing")
+)
@@ -0,0 +1,123 @@
+package cc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/aws/s3"
+	"github.com/aws-cloudformation/rain/internal/config"
+)
+
+// driftHistoryEntry is one line of a deployment's drift history,
+// written by cc drift --record
+type driftHistoryEntry struct {
+	Time          string                 `json:"time"`
+	LastWriteTime string                 `json:"lastWriteTime,omitempty"`
+	Resources     int                    `json:"resources"`
+	Drifted       int                    `json:"drifted"`
+	Statuses      []driftHistoryResource `json:"statuses"`
+}
+
+// driftHistoryResource is the status of a single resource in a drift history entry
+type driftHistoryResource struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Identifier string `json:"identifier"`
+
+	// Status is "ok", "drifted", "deleted", "type-changed", "incomplete", or "error"
+	Status string `json:"status"`
+}
+
+// newDriftHistoryEntry summarizes the results of a drift check made at now
+func newDriftHistoryEntry(template cft.Template, results []*ResourceDrift, now time.Time) driftHistoryEntry {
+	lastWriteTime, _ := template.GetStringValue(string(cft.State), "LastWriteTime")
+
+	entry := driftHistoryEntry{
+		Time:          now.UTC().Format(time.RFC3339),
+		LastWriteTime: lastWriteTime,
+		Resources:     len(results),
+		Statuses:      make([]driftHistoryResource, 0),
+	}
+
+	for _, rd := range results {
+		status := "ok"
+		if rd.Incomplete {
+			status = "incomplete"
+		} else if rd.Error != "" {
+			status = "error"
+		} else if rd.TypeChanged {
+			status = "type-changed"
+		} else if rd.Deleted {
+			status = "deleted"
+		} else if rd.Drifted {
+			status = "drifted"
+		}
+		if rd.Drifted {
+			entry.Drifted++
+		}
+		entry.Statuses = append(entry.Statuses, driftHistoryResource{
+			Name:       rd.Name,
+			Type:       rd.Type,
+			Identifier: rd.Identifier,
+			Status:     status,
+		})
+	}
+
+	return entry
+}
+
+// historyPath returns the location of the drift history next to a state file,
+// e.g. deployments/name.drift-history.jsonl for deployments/name.yaml
+func historyPath(statePath string) string {
+	return strings.TrimSuffix(statePath, ".yaml") + ".drift-history.jsonl"
+}
+
+// historyRetries is how many times recordDrift reads the drift history again
+// when another run changed it before it could be written
+const historyRetries = 5
+
+// recordDrift appends a summary of results to the drift history next to the
+// state file, either the local stateFile, if it is set, or the state file in src
+func recordDrift(template cft.Template, results []*ResourceDrift, stateFile string, src stateSource) error {
+	line, err := json.Marshal(newDriftHistoryEntry(template, results, time.Now()))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if stateFile != "" {
+		path := historyPath(stateFile)
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("unable to record drift history in %s: %v", path, err)
+		}
+		defer f.Close()
+		if _, err := f.Write(line); err != nil {
+			return fmt.Errorf("unable to record drift history in %s: %v", path, err)
+		}
+		return nil
+	}
+
+	// S3 objects can't be appended to, so download the history and upload it
+	// again, as long as no other run has changed it in the meantime
+	historyKey := historyPath(src.key)
+	for i := 0; ; i++ {
+		history, etag, err := s3.GetObjectWithETag(src.bucket, historyKey)
+		if err != nil && !s3.IsNoSuchKey(err) {
+			return fmt.Errorf("unable to read drift history s3://%s/%s: %v", src.bucket, historyKey, err)
+		}
+
+		err = s3.PutObjectIfMatch(src.bucket, historyKey, append(history, line...), etag)
+		if err == nil {
+			return nil
+		}
+		if !s3.IsPreconditionFailed(err) || i == historyRetries {
+			return fmt.Errorf("unable to record drift history s3://%s/%s: %v", src.bucket, historyKey, err)
+		}
+		config.Debugf("drift history s3://%s/%s was changed by another run, trying again", src.bucket, historyKey)
+	}
+}
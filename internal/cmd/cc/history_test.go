@@ -0,0 +1,156 @@
+package cc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/aws/s3"
+	"github.com/aws-cloudformation/rain/internal/config"
+)
+
+func TestNewDriftHistoryEntry(t *testing.T) {
+	template, err := parse.String(`
+State:
+  LastWriteTime: "2024-01-02T03:04:05Z"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []*ResourceDrift{
+		{Name: "A", Type: "AWS::S3::Bucket", Identifier: "a"},
+		{Name: "B", Type: "AWS::S3::Bucket", Identifier: "b", Drifted: true},
+		{Name: "C", Type: "AWS::SQS::Queue", Identifier: "c", Drifted: true, Deleted: true},
+		{Name: "D", Type: "AWS::SQS::Queue", Incomplete: true},
+		{Name: "E", Type: "AWS::SQS::Queue", Identifier: "e", Drifted: true, TypeChanged: true},
+	}
+
+	now := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	entry := newDriftHistoryEntry(template, results, now)
+
+	if entry.Time != "2024-02-03T04:05:06Z" || entry.LastWriteTime != "2024-01-02T03:04:05Z" {
+		t.Errorf("unexpected times: %+v", entry)
+	}
+	if entry.Resources != 5 || entry.Drifted != 3 {
+		t.Errorf("unexpected counts: %+v", entry)
+	}
+	statuses := make([]string, 0)
+	for _, s := range entry.Statuses {
+		statuses = append(statuses, s.Status)
+	}
+	if strings.Join(statuses, ",") != "ok,drifted,deleted,incomplete,type-changed" {
+		t.Errorf("unexpected statuses: %v", statuses)
+	}
+
+	if p := historyPath("deployments/app.yaml"); p != "deployments/app.drift-history.jsonl" {
+		t.Errorf("unexpected history path %s", p)
+	}
+}
+
+func TestRecordDriftLocal(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "app.yaml")
+
+	template, err := parse.String("State: {}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []*ResourceDrift{{Name: "A", Drifted: true}}
+
+	for i := 0; i < 2; i++ {
+		if err := recordDrift(template, results, stateFile, stateSource{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := os.ReadFile(filepath.Join(dir, "app.drift-history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(history)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(lines))
+	}
+	var entry driftHistoryEntry
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil || entry.Drifted != 1 {
+		t.Errorf("unexpected entry %s (%v)", lines[1], err)
+	}
+}
+
+func TestRecordDriftConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	version := 0
+	racing := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		etag := fmt.Sprintf(`"%d"`, version)
+		switch r.Method {
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			w.Write(body)
+		case http.MethodPut:
+			// Another run writes the history between this run's read and write
+			if racing {
+				racing = false
+				objects[r.URL.Path] = append(objects[r.URL.Path], []byte("{}\n")...)
+				version++
+			}
+			_, exists := objects[r.URL.Path]
+			if (r.Header.Get("If-None-Match") == "*" && exists) ||
+				(r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != fmt.Sprintf(`"%d"`, version)) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code><Message>changed</Message></Error>`)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+			version++
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+	defer func(e, o string) { config.EndpointURL, s3.ExpectedBucketOwner = e, o }(config.EndpointURL, s3.ExpectedBucketOwner)
+	config.EndpointURL = server.URL
+	s3.ExpectedBucketOwner = "123456789012"
+
+	template, err := parse.String("State: {}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []*ResourceDrift{{Name: "A", Drifted: true}}
+	src := stateSource{bucket: "bucket", key: "deployments/app.yaml"}
+	if err := recordDrift(template, results, "", src); err != nil {
+		t.Fatal(err)
+	}
+
+	history := objects["/bucket/deployments/app.drift-history.jsonl"]
+	lines := strings.Split(strings.TrimSpace(string(history)), "\n")
+	if len(lines) != 2 || lines[0] != "{}" {
+		t.Errorf("expected the other run's entry to be kept:\n%s", history)
+	}
+}
@@ -0,0 +1,51 @@
+package cc
+
+import (
+	"fmt"
+
+	"github.com/aws-cloudformation/rain/internal/aws/ccapi"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// resourceIdentifier returns the CCAPI identifier stored in a resource model.
+// The Identifier is usually a string. Resources with a composite primary
+// identifier, like ECS services, can instead store a list of the parts in
+// schema order, or a map of property names to values, which is put in order
+// using the type's primaryIdentifier. The parts are joined with |.
+func resourceIdentifier(typeName string, id *yaml.Node, schemas *schemaCache) (string, error) {
+	switch id.Kind {
+	case yaml.ScalarNode:
+		return id.Value, nil
+
+	case yaml.SequenceNode:
+		parts := make([]string, 0)
+		for _, part := range id.Content {
+			if part.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("expected each part of the %s identifier to be a string (line %d)", typeName, part.Line)
+			}
+			parts = append(parts, part.Value)
+		}
+		return ccapi.JoinIdentifier(parts), nil
+
+	case yaml.MappingNode:
+		schema, err := schemas.get(typeName)
+		if err != nil {
+			return "", fmt.Errorf("unable to load schema for %s: %v", typeName, err)
+		}
+		if len(schema.PrimaryIdentifier) == 0 {
+			return "", fmt.Errorf("the schema for %s has no primaryIdentifier to order the identifier by", typeName)
+		}
+		parts := make([]string, 0)
+		for _, name := range schema.PrimaryIdentifier {
+			_, part, _ := s11n.GetMapValue(id, name)
+			if part == nil || part.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("expected the %s identifier to have a value for %s (line %d)", typeName, name, id.Line)
+			}
+			parts = append(parts, part.Value)
+		}
+		return ccapi.JoinIdentifier(parts), nil
+	}
+
+	return "", fmt.Errorf("unexpected %s identifier (line %d)", typeName, id.Line)
+}
@@ -0,0 +1,47 @@
+package cc
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestResourceIdentifier(t *testing.T) {
+	schemas := &schemaCache{schemas: map[string]*typeSchema{
+		"AWS::ECS::Service": {PrimaryIdentifier: []string{"ServiceArn", "Cluster"}},
+	}}
+
+	cases := []struct {
+		id       string
+		expected string
+		fails    bool
+	}{
+		{"my-bucket", "my-bucket", false},
+		{"[arn:aws:ecs:service, my-cluster]", "arn:aws:ecs:service|my-cluster", false},
+		{"{Cluster: my-cluster, ServiceArn: arn:aws:ecs:service}", "arn:aws:ecs:service|my-cluster", false},
+		{"{Cluster: my-cluster}", "", true},
+		{"[[a, b]]", "", true},
+	}
+
+	for _, c := range cases {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(c.id), &node); err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := resourceIdentifier("AWS::ECS::Service", node.Content[0], schemas)
+		if c.fails {
+			if err == nil {
+				t.Errorf("expected %s to fail", c.id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.id, err)
+			continue
+		}
+		if id != c.expected {
+			t.Errorf("%#v\n!=\n%#v\n", id, c.expected)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package cc
+
+import (
+	"slices"
+
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// updateModel changes n in place so that it holds value, which is a model
+// decoded from JSON. Map keys and list elements that are still there keep
+// their nodes, so comments in the state file survive, and only the scalars
+// that changed are replaced. New map keys are added in sorted order after
+// the existing ones, and keys that are no longer in value are removed.
+func updateModel(n *yaml.Node, value any) error {
+	switch v := value.(type) {
+	case map[string]any:
+		if n.Kind != yaml.MappingNode {
+			return replaceNode(n, value)
+		}
+
+		// Remove the keys that are gone, then update or add the rest
+		for i := len(n.Content) - 2; i >= 0; i -= 2 {
+			if _, ok := v[n.Content[i].Value]; !ok {
+				if err := s11n.DeleteMapValue(n, n.Content[i].Value); err != nil {
+					return err
+				}
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			_, existing, _ := s11n.GetMapValue(n, key)
+			if existing != nil {
+				if err := updateModel(existing, v[key]); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var added yaml.Node
+			if err := added.Encode(v[key]); err != nil {
+				return err
+			}
+			if err := s11n.SetMapValue(n, key, &added); err != nil {
+				return err
+			}
+		}
+
+	case []any:
+		if n.Kind != yaml.SequenceNode {
+			return replaceNode(n, value)
+		}
+
+		if len(n.Content) > len(v) {
+			n.Content = n.Content[:len(v)]
+		}
+		for i, elem := range v {
+			if i < len(n.Content) {
+				if err := updateModel(n.Content[i], elem); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var added yaml.Node
+			if err := added.Encode(elem); err != nil {
+				return err
+			}
+			n.Content = append(n.Content, &added)
+		}
+
+	default:
+		var current any
+		if n.Kind == yaml.ScalarNode && n.Decode(&current) == nil && numbersOrEqual(current, value) {
+			return nil
+		}
+		return replaceNode(n, value)
+	}
+
+	return nil
+}
+
+// numbersOrEqual returns true if a scalar decoded from the state file
+// is the same as a value decoded from JSON, where all numbers are float64
+func numbersOrEqual(current any, value any) bool {
+	if current == value {
+		return true
+	}
+	switch c := current.(type) {
+	case int:
+		f, ok := value.(float64)
+		return ok && float64(c) == f
+	case float64:
+		f, ok := value.(float64)
+		return ok && c == f
+	}
+	return false
+}
+
+// replaceNode overwrites n with value, keeping the comments on n
+func replaceNode(n *yaml.Node, value any) error {
+	var replacement yaml.Node
+	if err := replacement.Encode(value); err != nil {
+		return err
+	}
+
+	replacement.HeadComment = n.HeadComment
+	replacement.LineComment = n.LineComment
+	replacement.FootComment = n.FootComment
+	*n = replacement
+
+	return nil
+}
+
+// storedKeysOnly returns a copy of live with only the map keys that are also
+// in stored, so that read only properties like Arn, which were never in the
+// template, are not reported as added. Nested maps, and the maps in lists
+// that are at the same position in both, are filtered the same way.
+func storedKeysOnly(stored any, live any) any {
+	switch l := live.(type) {
+	case map[string]any:
+		s, ok := stored.(map[string]any)
+		if !ok {
+			return live
+		}
+		retval := make(map[string]any, len(s))
+		for k, v := range l {
+			if sv, ok := s[k]; ok {
+				retval[k] = storedKeysOnly(sv, v)
+			}
+		}
+		return retval
+	case []any:
+		s, ok := stored.([]any)
+		if !ok {
+			return live
+		}
+		retval := make([]any, len(l))
+		for i, v := range l {
+			if i < len(s) {
+				retval[i] = storedKeysOnly(s[i], v)
+			} else {
+				retval[i] = v
+			}
+		}
+		return retval
+	default:
+		return live
+	}
+}
@@ -0,0 +1,91 @@
+package cc
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestUpdateModel(t *testing.T) {
+	stored := `# The bucket's model
+BucketName: my-bucket # Keep this name
+Port: 80
+Tags:
+  # The team that owns it
+  - Key: Team
+    Value: web
+  - Key: Old
+    Value: gone
+Removed: true
+`
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(stored), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	live := map[string]any{
+		"BucketName": "my-bucket",
+		"Port":       float64(80),
+		"Tags": []any{
+			map[string]any{"Key": "Team", "Value": "data"},
+		},
+		"Versioning": map[string]any{"Status": "Enabled"},
+	}
+
+	if err := updateModel(doc.Content[0], live); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# The bucket's model
+BucketName: my-bucket # Keep this name
+Port: 80
+Tags:
+    # The team that owns it
+    - Key: Team
+      Value: data
+Versioning:
+    Status: Enabled
+`
+	if string(out) != expected {
+		t.Errorf("%s\n!=\n%s\n", out, expected)
+	}
+}
+
+func TestStoredKeysOnly(t *testing.T) {
+	stored := map[string]any{
+		"BucketName": "a",
+		"Versioning": map[string]any{"Status": "Enabled"},
+		"Rules":      []any{map[string]any{"Id": "r1"}},
+		"Removed":    "gone",
+	}
+	live := map[string]any{
+		"Arn":        "arn:aws:s3:::a",
+		"BucketName": "b",
+		"Versioning": map[string]any{"Status": "Enabled", "MfaDelete": "Disabled"},
+		"Rules": []any{
+			map[string]any{"Id": "r1", "Computed": true},
+			map[string]any{"Id": "r2"},
+		},
+	}
+
+	expected := map[string]any{
+		"BucketName": "b",
+		"Versioning": map[string]any{"Status": "Enabled"},
+		"Rules": []any{
+			map[string]any{"Id": "r1"},
+			map[string]any{"Id": "r2"},
+		},
+	}
+
+	actual := storedKeysOnly(stored, live)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%#v\n!=\n%#v\n", actual, expected)
+	}
+}
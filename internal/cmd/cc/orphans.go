@@ -0,0 +1,91 @@
+package cc
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/aws-cloudformation/rain/internal/aws/ccapi"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// OrphanResource is a live resource of one of the deployment's types
+// that has no model in the state file
+type OrphanResource struct {
+	Type       string `json:"type"`
+	Identifier string `json:"identifier"`
+}
+
+// findOrphans lists the live resources of each type in the deployment
+// and returns the ones that are not in the state file.
+// CCAPI lists every resource of a type in the account and region,
+// so orphans might have been created outside of rain or by another deployment.
+func findOrphans(resources map[string]*yaml.Node, resourceModels *yaml.Node, opts DriftOptions) ([]*OrphanResource, error) {
+
+	// Collect the known identifiers for each type
+	known := make(map[string][]string)
+	schemas := newSchemaCache()
+	for name, resource := range resources {
+		_, t, _ := s11n.GetMapValue(resource, "Type")
+		if t == nil {
+			return nil, fmt.Errorf("resource %s expected to have Type", name)
+		}
+		ids := known[t.Value]
+		_, model, _ := s11n.GetMapValue(resourceModels, name)
+		if model != nil {
+			if _, idNode, _ := s11n.GetMapValue(model, "Identifier"); idNode != nil {
+				id, err := resourceIdentifier(t.Value, idNode, schemas)
+				if err != nil {
+					return nil, fmt.Errorf("resource model %s: %v", name, err)
+				}
+				if id != "" {
+					ids = append(ids, id)
+				}
+			}
+		}
+		known[t.Value] = ids
+	}
+
+	typeNames := make([]string, 0)
+	for typeName := range known {
+		typeNames = append(typeNames, typeName)
+	}
+	slices.Sort(typeNames)
+
+	retval := make([]*OrphanResource, 0)
+	for _, typeName := range typeNames {
+		live, err := ccapi.ListResourcesWithOptions(typeName, opts.readOptions())
+		if err != nil {
+			return nil, fmt.Errorf("unable to list %s resources: %v", typeName, err)
+		}
+		retval = append(retval, unknownResources(typeName, live, known[typeName])...)
+	}
+
+	return retval, nil
+}
+
+// unknownResources returns the live identifiers that are not known
+func unknownResources(typeName string, live []string, known []string) []*OrphanResource {
+	retval := make([]*OrphanResource, 0)
+	for _, id := range live {
+		if !slices.Contains(known, id) {
+			retval = append(retval, &OrphanResource{Type: typeName, Identifier: id})
+		}
+	}
+	return retval
+}
+
+// printOrphans lists resources that are not in the state file
+func printOrphans(w io.Writer, orphans []*OrphanResource) {
+	if len(orphans) == 0 {
+		return
+	}
+	fmt.Fprintln(w, console.Red(fmt.Sprintf("Found %d resources that are not in the state file:", len(orphans))))
+	for _, o := range orphans {
+		fmt.Fprintf(w, "    %s %s\n", o.Type, o.Identifier)
+	}
+	fmt.Fprintln(w, "They might have been created outside of rain, or by another deployment.")
+	fmt.Fprintln(w)
+}
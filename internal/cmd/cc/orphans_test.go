@@ -0,0 +1,21 @@
+package cc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnknownResources(t *testing.T) {
+	actual := unknownResources("AWS::S3::Bucket", []string{"a", "b", "c"}, []string{"b"})
+	expected := []*OrphanResource{
+		{Type: "AWS::S3::Bucket", Identifier: "a"},
+		{Type: "AWS::S3::Bucket", Identifier: "c"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%#v\n!=\n%#v\n", actual, expected)
+	}
+
+	if len(unknownResources("AWS::S3::Bucket", []string{"a"}, []string{"a"})) != 0 {
+		t.Errorf("expected no orphans")
+	}
+}
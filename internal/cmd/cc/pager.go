@@ -0,0 +1,98 @@
+package cc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/console/spinner"
+)
+
+// driftPager is set by the --pager flag on cc drift
+var driftPager bool
+
+// driftNoPager is set by the --no-pager flag on cc drift
+var driftNoPager bool
+
+// defaultPager is the pager that is used if $PAGER is not set
+const defaultPager = "less -R"
+
+// pager is the running pager, if there is one
+var pager *exec.Cmd
+
+// pagerInput is the pager's stdin
+var pagerInput io.WriteCloser
+
+// pagerCommand returns the command line of the pager, from $PAGER if it is set.
+// It returns nil if $PAGER is cat, which is how paging is usually turned off.
+func pagerCommand() []string {
+	args := strings.Fields(os.Getenv("PAGER"))
+	if len(args) == 0 {
+		args = strings.Fields(defaultPager)
+	}
+	if args[0] == "cat" {
+		return nil
+	}
+	return args
+}
+
+// asksQuestions returns true if drift will ask what to do, which it can't
+// do from inside a pager
+func asksQuestions() bool {
+	if reportOutput() || driftSummary || driftAgainst != "" {
+		return false
+	}
+	if driftBootstrap && !yes {
+		return true
+	}
+	return !(yes && driftDecisions != nil && driftDecisions.replay)
+}
+
+// usePager returns true if the output of drift should go to a pager
+func usePager() bool {
+	return driftPager && !driftNoPager && console.IsTTY && !reportOutput() && pagerCommand() != nil
+}
+
+// startPager starts the pager and returns the writer to send the output of drift to.
+// less is told to keep colours, unless $LESS is already set.
+func startPager() (io.Writer, error) {
+	args := pagerCommand()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("unable to start pager %s: %v", args[0], err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start pager %s: %v", args[0], err)
+	}
+	config.Debugf("Started pager %s", strings.Join(args, " "))
+
+	// The spinner would be drawn on top of the pager
+	spinner.Disable()
+
+	pager, pagerInput = cmd, in
+	return in, nil
+}
+
+// stopPager waits for the user to quit the pager, if one was started
+func stopPager() {
+	if pager == nil {
+		return
+	}
+
+	pagerInput.Close()
+	if err := pager.Wait(); err != nil {
+		config.Debugf("Pager exited: %v", err)
+	}
+	pager, pagerInput = nil, nil
+}
@@ -0,0 +1,53 @@
+package cc
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPagerCommand(t *testing.T) {
+	cases := map[string][]string{
+		"":               {"less", "-R"},
+		"more":           {"more"},
+		"less -R -S":     {"less", "-R", "-S"},
+		"cat":            nil,
+		"  most  -s    ": {"most", "-s"},
+	}
+
+	for env, expected := range cases {
+		t.Setenv("PAGER", env)
+		if actual := pagerCommand(); !slices.Equal(actual, expected) {
+			t.Errorf("%q: %#v\n!=\n%#v\n", env, actual, expected)
+		}
+	}
+}
+
+func TestAsksQuestions(t *testing.T) {
+	defer func() {
+		driftSummary = false
+		driftAgainst = ""
+		driftDecisions = nil
+		yes = false
+	}()
+
+	if !asksQuestions() {
+		t.Errorf("expected drift to ask questions by default")
+	}
+
+	driftDecisions = &decisionSet{replay: true}
+	if !asksQuestions() {
+		t.Errorf("expected replayed decisions to still ask for confirmation")
+	}
+
+	yes = true
+	if asksQuestions() {
+		t.Errorf("expected replayed decisions with --yes not to ask questions")
+	}
+
+	yes = false
+	driftDecisions = nil
+	driftSummary = true
+	if asksQuestions() {
+		t.Errorf("expected --summary not to ask questions")
+	}
+}
@@ -0,0 +1,70 @@
+package cc
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/aws"
+	"github.com/aws-cloudformation/rain/internal/aws/sts"
+	"github.com/aws-cloudformation/rain/internal/config"
+)
+
+// driftAccountId is set by the --account-id flag on cc drift
+var driftAccountId string
+
+var callerAccount string
+var callerAccountOnce sync.Once
+
+// sessionPseudoParameters returns the pseudo parameters of the current session,
+// for accountId if it is set, or else the account of roleArn, or of the credentials.
+// The account of the credentials is only looked up the first time it is needed.
+func sessionPseudoParameters(accountId string, roleArn string) cft.PseudoParameters {
+	if accountId == "" {
+		accountId = roleAccount(roleArn)
+	}
+	if accountId == "" {
+		callerAccountOnce.Do(func() {
+			var err error
+			callerAccount, err = sts.GetAccountID()
+			if err != nil {
+				config.Debugf("unable to look up the account id: %v", err)
+			}
+		})
+		accountId = callerAccount
+	}
+	return cft.NewPseudoParameters(accountId, aws.Config().Region)
+}
+
+// roleAccount returns the account id in a role ARN like
+// arn:aws:iam::123456789012:role/name, or an empty string
+func roleAccount(roleArn string) string {
+	parts := strings.Split(roleArn, ":")
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[4]
+}
+
+// pseudoParameters returns opts.PseudoParameters, or those of the current session
+func (opts DriftOptions) pseudoParameters() cft.PseudoParameters {
+	if opts.PseudoParameters != nil {
+		return *opts.PseudoParameters
+	}
+	return sessionPseudoParameters(opts.AccountId, opts.RoleArn)
+}
+
+// setSession sets the account and region that the live state in the report
+// was read from
+func (r *DriftReport) setSession(opts DriftOptions) {
+	p := opts.pseudoParameters()
+	r.Account = p.AccountId
+	r.Region = p.Region
+}
+
+// resolveStoredModel replaces the pseudo parameters that a stored model refers
+// to, like {Ref: AWS::Region}, with their values, since they are always
+// resolved in live models
+func resolveStoredModel(model map[string]any, opts DriftOptions) map[string]any {
+	return cft.ResolvePseudoParameters(model, opts.pseudoParameters()).(map[string]any)
+}
@@ -0,0 +1,57 @@
+package cc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft"
+)
+
+func TestResolveStoredModel(t *testing.T) {
+	p := cft.NewPseudoParameters("123456789012", "eu-west-1")
+	opts := DriftOptions{PseudoParameters: &p}
+
+	stored := map[string]any{
+		"BucketName": map[string]any{"Fn::Sub": "logs-${AWS::AccountId}-${AWS::Region}"},
+	}
+	live := map[string]any{"BucketName": "logs-123456789012-eu-west-1"}
+
+	resolved := resolveStoredModel(stored, opts)
+	if !reflect.DeepEqual(resolved, live) {
+		t.Errorf("%#v\n!=\n%#v\n", resolved, live)
+	}
+}
+
+func TestReportSession(t *testing.T) {
+	p := cft.NewPseudoParameters("123456789012", "eu-west-1")
+	report := &DriftReport{Name: "app"}
+	report.setSession(DriftOptions{PseudoParameters: &p})
+
+	out, err := formatReport(report, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "name": "app",
+  "resources": null,
+  "account": "123456789012",
+  "region": "eu-west-1",
+  "driftRatio": 0
+}`
+	if string(out) != expected {
+		t.Errorf("%s\n!=\n%s\n", out, expected)
+	}
+}
+
+func TestRoleAccount(t *testing.T) {
+	cases := map[string]string{
+		"arn:aws:iam::123456789012:role/drift": "123456789012",
+		"":                                     "",
+		"not-an-arn":                           "",
+	}
+	for arn, expected := range cases {
+		if actual := roleAccount(arn); actual != expected {
+			t.Errorf("%s: %s != %s", arn, actual, expected)
+		}
+	}
+}
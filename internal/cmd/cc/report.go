@@ -0,0 +1,194 @@
+package cc
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"gopkg.in/yaml.v3"
+)
+
+// reportOutput returns true if --output asks for a report
+// instead of the interactive diff
+func reportOutput() bool {
+	return driftOutput == "json" || driftOutput == "yaml"
+}
+
+// formatReport returns v as indented JSON, or as YAML if format is yaml.
+// The YAML is converted from the JSON, so that the json tags on the report
+// types decide the fields of both formats and they can't drift apart.
+func formatReport(v any, format string) ([]byte, error) {
+	j, err := json.MarshalIndent(v, "", "  ")
+	if err != nil || format != "yaml" {
+		return j, err
+	}
+
+	// JSON is valid YAML, so decoding it keeps the order of the fields
+	var node yaml.Node
+	if err := yaml.Unmarshal(j, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+
+	buf := strings.Builder{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(buf.String(), "\n")), nil
+}
+
+// blockStyle clears the flow and quoting styles that n was decoded with
+// from JSON. The encoder still quotes strings that would otherwise be
+// read back as another type.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// DriftReport is the machine-readable result of running drift on a deployment
+type DriftReport struct {
+	Name      string           `json:"name"`
+	Resources []*ResourceDrift `json:"resources"`
+
+	// Account and Region are where the live state was read from
+	Account string `json:"account,omitempty"`
+	Region  string `json:"region,omitempty"`
+
+	// Total is the number of resources in the state file, and Processed is
+	// the number that were checked, which is fewer if some were filtered out
+	Total     int `json:"total,omitempty"`
+	Processed int `json:"processed,omitempty"`
+
+	// DriftRatio is the fraction of all compared values that differ
+	DriftRatio float64 `json:"driftRatio"`
+
+	// SkippedByType is the number of resources left out by --include-type and --exclude-type
+	SkippedByType int `json:"skippedByType,omitempty"`
+
+	// SkippedRetained is the number of retained resources left out by --skip-retained
+	SkippedRetained int `json:"skippedRetained,omitempty"`
+
+	// SkippedByTag is the number of resources left out by --filter-tag
+	SkippedByTag int `json:"skippedByTag,omitempty"`
+
+	// Orphans are only set when --detect-orphans is used
+	Orphans []*OrphanResource `json:"orphans,omitempty"`
+}
+
+// HasDrift returns true if any resource in the report has drifted,
+// or if there are live resources that are not in the state file
+func (r *DriftReport) HasDrift() bool {
+	if len(r.Orphans) > 0 {
+		return true
+	}
+	for _, rd := range r.Resources {
+		if rd.Drifted {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourceDrift is the result of comparing the model stored
+// in the state file for a single resource to its live state
+type ResourceDrift struct {
+	Name        string         `json:"name"`
+	Type        string         `json:"type"`
+	Identifier  string         `json:"identifier"`
+	Arn         string         `json:"arn,omitempty"`
+	Drifted     bool           `json:"drifted"`
+	Deleted     bool           `json:"deleted,omitempty"`
+	TypeChanged bool           `json:"typeChanged,omitempty"`
+	Incomplete  bool           `json:"incomplete,omitempty"`
+	Retained    bool           `json:"retained,omitempty"`
+	Severity    string         `json:"severity,omitempty"`
+	Warning     string         `json:"warning,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	DriftRatio  float64        `json:"driftRatio"`
+	Differences []PropertyDiff `json:"differences,omitempty"`
+
+	// diff compares the stored model (old) to the live model (new)
+	diff       diff.Diff
+	liveModel  map[string]any
+	stateModel map[string]any
+	node       *yaml.Node
+	resource   *Resource
+
+	// scope are the property paths that were compared, or nil if the whole models were
+	scope []string
+
+	// differ and compared count the leaf values that differ and that were compared
+	differ   int
+	compared int
+
+	// caseMismatches are stored keys that only match a live key if case is ignored
+	caseMismatches []string
+}
+
+// PropertyDiff is a single property value that differs
+// between the stored model and the live model
+type PropertyDiff struct {
+	// Path is a /-separated path to the property, e.g. Tags/0/Value
+	Path string `json:"path"`
+
+	// Mode is "added" (only in live state), "removed" (only in the state file),
+	// "changed", or "type-changed" (e.g. a string that became a list)
+	Mode string `json:"mode"`
+
+	Stored any `json:"stored,omitempty"`
+	Live   any `json:"live,omitempty"`
+
+	// Severity is set by --severity-config: "info", "warn", or "critical"
+	Severity string `json:"severity,omitempty"`
+}
+
+// newPropertyDiffs converts the changes in d into PropertyDiffs
+func newPropertyDiffs(d diff.Diff, stateModel map[string]any) []PropertyDiff {
+	retval := make([]PropertyDiff, 0)
+	for _, c := range diff.Changes(d) {
+		pd := PropertyDiff{Path: c.PathString()}
+		switch c.Mode {
+		case diff.Added:
+			pd.Mode = "added"
+			pd.Live = c.Value
+		case diff.Removed:
+			pd.Mode = "removed"
+			pd.Stored = c.Value
+		case diff.TypeChanged:
+			pd.Mode = "type-changed"
+			pd.Live = c.Value
+			pd.Stored = lookup(stateModel, c.Path)
+		default:
+			pd.Mode = "changed"
+			pd.Live = c.Value
+			pd.Stored = lookup(stateModel, c.Path)
+		}
+		retval = append(retval, pd)
+	}
+	return retval
+}
+
+// lookup returns the value at path inside a decoded model, or nil
+func lookup(v any, path []interface{}) any {
+	for _, p := range path {
+		switch k := p.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil
+			}
+			v = m[k]
+		case int:
+			s, ok := v.([]any)
+			if !ok || k >= len(s) {
+				return nil
+			}
+			v = s[k]
+		}
+	}
+	return v
+}
@@ -0,0 +1,99 @@
+package cc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewPropertyDiffs(t *testing.T) {
+	stored := map[string]any{
+		"Name":    "a",
+		"Removed": "gone",
+		"Tags": []any{
+			map[string]any{"Key": "k", "Value": "v1"},
+		},
+	}
+	live := map[string]any{
+		"Name":  "a",
+		"Added": true,
+		"Tags": []any{
+			map[string]any{"Key": "k", "Value": "v2"},
+		},
+	}
+
+	expected := []PropertyDiff{
+		{Path: "Added", Mode: "added", Live: true},
+		{Path: "Removed", Mode: "removed", Stored: "gone"},
+		{Path: "Tags/0/Value", Mode: "changed", Stored: "v1", Live: "v2"},
+	}
+
+	actual := newPropertyDiffs(diff.CompareMaps(stored, live), stored)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%#v\n!=\n%#v", actual, expected)
+	}
+
+	report := &DriftReport{Resources: []*ResourceDrift{{Name: "A"}, {Name: "B", Drifted: true}}}
+	if !report.HasDrift() {
+		t.Errorf("expected report to have drift")
+	}
+}
+
+func TestFormatReport(t *testing.T) {
+	report := &DriftReport{
+		Name: "app",
+		Resources: []*ResourceDrift{
+			{
+				Name:       "Bucket",
+				Type:       "AWS::S3::Bucket",
+				Identifier: "true",
+				Drifted:    true,
+				DriftRatio: 0.5,
+				Differences: []PropertyDiff{
+					{Path: "Tags/0/Value", Mode: "changed", Stored: "a", Live: "b"},
+				},
+			},
+		},
+		DriftRatio: 0.5,
+	}
+
+	expected := `name: app
+resources:
+  - name: Bucket
+    type: AWS::S3::Bucket
+    identifier: "true"
+    drifted: true
+    driftRatio: 0.5
+    differences:
+      - path: Tags/0/Value
+        mode: changed
+        stored: a
+        live: b
+driftRatio: 0.5`
+
+	out, err := formatReport(report, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Errorf("%s\n!=\n%s\n", out, expected)
+	}
+
+	// The YAML has the same fields as the JSON
+	j, err := formatReport(report, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON, fromYAML any
+	if err := yaml.Unmarshal(j, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(out, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Errorf("%#v\n!=\n%#v\n", fromYAML, fromJSON)
+	}
+}
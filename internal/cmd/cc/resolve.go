@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/cft/parse"
 	"github.com/aws-cloudformation/rain/internal/aws"
 	"github.com/aws-cloudformation/rain/internal/aws/sts"
@@ -173,20 +174,7 @@ func resolvePseudoParam(name string) (string, error) {
 		// TODO: Needs special handling to remove nodes from the template
 		return "", errors.New("unsupported: AWS::NoValue")
 	case "Partition":
-		region := aws.Config().Region
-		if strings.HasPrefix(region, "us-gov") {
-			return "aws-us-gov", nil
-		}
-		if strings.HasPrefix(region, "cn-") {
-			return "aws-cn", nil
-		}
-		if strings.HasPrefix(region, "us-iso-") {
-			return "aws-iso", nil
-		}
-		if strings.HasPrefix(region, "us-isob-") {
-			return "aws-iso-b", nil
-		}
-		return "aws", nil
+		return cft.NewPseudoParameters("", aws.Config().Region).Partition, nil
 	case "StackId":
 		return "", errors.New("unsupported: AWS::StackId")
 	case "StackName":
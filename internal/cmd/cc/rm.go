@@ -42,6 +42,10 @@ var CCRmCmd = &cobra.Command{
 		if err != nil {
 			panic(err)
 		}
+		obj, _, err = decompressState(obj)
+		if err != nil {
+			panic(err)
+		}
 
 		state, err = parse.String(string(obj))
 		if err != nil {
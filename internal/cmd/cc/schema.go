@@ -0,0 +1,126 @@
+package cc
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
+	"github.com/aws-cloudformation/rain/internal/config"
+)
+
+// typeSchema holds the parts of a resource type schema that drift needs
+type typeSchema struct {
+	// ReadOnlyProperties are top level property names, without the /properties/ prefix
+	ReadOnlyProperties []string
+
+	// PrimaryIdentifier are the property names that make up the identifier,
+	// in the order that CCAPI expects them, without the /properties/ prefix
+	PrimaryIdentifier []string
+}
+
+// schemaCache holds parsed resource type schemas so that
+// resources of the same type don't each re-parse the schema.
+// Each schema is loaded and parsed once, the first time it is needed.
+// It is safe to use from multiple goroutines, and schemas of different
+// types are loaded in parallel.
+type schemaCache struct {
+	mu      sync.Mutex
+	schemas map[string]*typeSchema
+
+	// loading holds a lock for each type, which is held while its schema is loaded
+	loading map[string]*sync.Mutex
+}
+
+func newSchemaCache() *schemaCache {
+	return &schemaCache{schemas: make(map[string]*typeSchema)}
+}
+
+// get returns the parsed schema for typeName, loading it if it is not cached
+func (c *schemaCache) get(typeName string) (*typeSchema, error) {
+	if s, ok := c.cached(typeName); ok {
+		return s, nil
+	}
+
+	c.mu.Lock()
+	if c.loading == nil {
+		c.loading = make(map[string]*sync.Mutex)
+	}
+	l, ok := c.loading[typeName]
+	if !ok {
+		l = &sync.Mutex{}
+		c.loading[typeName] = l
+	}
+	c.mu.Unlock()
+
+	l.Lock()
+	defer l.Unlock()
+
+	// Another goroutine might have loaded it while we waited
+	if s, ok := c.cached(typeName); ok {
+		return s, nil
+	}
+
+	schema, err := cfn.GetTypeSchema(typeName, cfn.UseCacheNormally)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := parseTypeSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.schemas[typeName] = s
+	c.mu.Unlock()
+	return s, nil
+}
+
+// cached returns the schema for typeName if it has already been loaded
+func (c *schemaCache) cached(typeName string) (*typeSchema, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.schemas[typeName]
+	return s, ok
+}
+
+// warm loads the schemas for typeNames so that later calls to get are fast
+func (c *schemaCache) warm(typeNames []string) error {
+	for _, typeName := range typeNames {
+		if _, err := c.get(typeName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseTypeSchema(schema string) (*typeSchema, error) {
+	var schemaMap map[string]any
+	if err := json.Unmarshal([]byte(schema), &schemaMap); err != nil {
+		return nil, err
+	}
+
+	retval := &typeSchema{ReadOnlyProperties: make([]string, 0), PrimaryIdentifier: make([]string, 0)}
+
+	if readOnly, ok := schemaMap["readOnlyProperties"].([]any); ok {
+		config.Debugf("readOnly: %v", readOnly)
+		for _, p := range readOnly {
+			if ps, ok := p.(string); ok {
+				retval.ReadOnlyProperties = append(retval.ReadOnlyProperties,
+					strings.Replace(ps, "/properties/", "", 1))
+			}
+		}
+	}
+
+	if primary, ok := schemaMap["primaryIdentifier"].([]any); ok {
+		for _, p := range primary {
+			if ps, ok := p.(string); ok {
+				retval.PrimaryIdentifier = append(retval.PrimaryIdentifier,
+					strings.Replace(ps, "/properties/", "", 1))
+			}
+		}
+	}
+
+	return retval, nil
+}
@@ -0,0 +1,108 @@
+package cc
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
+)
+
+var schemaTestTypes = []string{
+	"AWS::S3::Bucket",
+	"AWS::SQS::Queue",
+	"AWS::Logs::LogGroup",
+}
+
+func TestSchemaCache(t *testing.T) {
+	cache := newSchemaCache()
+
+	if err := cache.warm(schemaTestTypes); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cache.schemas) != len(schemaTestTypes) {
+		t.Errorf("expected %d cached schemas, got %d", len(schemaTestTypes), len(cache.schemas))
+	}
+
+	s, err := cache.get("AWS::S3::Bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Contains(s.ReadOnlyProperties, "Arn") {
+		t.Errorf("expected Arn in read only properties: %v", s.ReadOnlyProperties)
+	}
+
+	again, _ := cache.get("AWS::S3::Bucket")
+	if again != s {
+		t.Errorf("expected the cached schema to be reused")
+	}
+}
+
+func TestSchemaCacheConcurrent(t *testing.T) {
+	cache := newSchemaCache()
+	types := benchmarkTypes()
+
+	results := make([]*typeSchema, len(types))
+	var wg sync.WaitGroup
+	for i, typeName := range types {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := cache.get(typeName)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = s
+		}()
+	}
+	wg.Wait()
+
+	for i, typeName := range types {
+		if expected, _ := cache.get(typeName); results[i] != expected {
+			t.Errorf("expected the schema for %s to be loaded once", typeName)
+		}
+	}
+}
+
+// benchmarkTypes mimics a deployment with 50 resources of 3 distinct types
+func benchmarkTypes() []string {
+	types := make([]string, 50)
+	for i := range types {
+		types[i] = schemaTestTypes[i%len(schemaTestTypes)]
+	}
+	return types
+}
+
+// For 50 resources of 3 types, BenchmarkSchemaCached is roughly 15x faster
+// than loading and parsing the schema for each resource:
+//
+//	BenchmarkSchemaUncached   21.9 ms/op   8.08 MB/op   95352 allocs/op
+//	BenchmarkSchemaCached      1.4 ms/op   0.48 MB/op    5642 allocs/op
+func BenchmarkSchemaUncached(b *testing.B) {
+	types := benchmarkTypes()
+	for i := 0; i < b.N; i++ {
+		for _, typeName := range types {
+			schema, err := cfn.GetTypeSchema(typeName, cfn.UseCacheNormally)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := parseTypeSchema(schema); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkSchemaCached(b *testing.B) {
+	types := benchmarkTypes()
+	for i := 0; i < b.N; i++ {
+		cache := newSchemaCache()
+		for _, typeName := range types {
+			if _, err := cache.get(typeName); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
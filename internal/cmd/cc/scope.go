@@ -0,0 +1,163 @@
+package cc
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// driftScopeConfig is set by the --scope-config flag on cc drift
+var driftScopeConfig string
+
+// driftScope is read from the file in --scope-config
+var driftScope *ScopeConfig
+
+// ScopeConfig limits the comparison of some resources to the properties
+// that matter. It is read from the file passed to --scope-config, for example:
+//
+//	Types:
+//	  AWS::IAM::Role:
+//	    - AssumeRolePolicyDocument
+//	    - Policies/*/PolicyDocument
+//	  AWS::S3::Bucket:
+//	    - VersioningConfiguration
+//	Resources:
+//	  LogBucket:
+//	    - LoggingConfiguration
+type ScopeConfig struct {
+	// Types maps a resource type to the property paths that are compared
+	// for resources of that type
+	Types map[string][]string `yaml:"Types"`
+
+	// Resources maps a logical id to the property paths that are compared
+	// for that resource, instead of the paths for its type
+	Resources map[string][]string `yaml:"Resources"`
+}
+
+// loadScopeConfig reads and checks a scope config file
+func loadScopeConfig(fileName string) (*ScopeConfig, error) {
+	f, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read scope config %s: %v", fileName, err)
+	}
+
+	var cfg ScopeConfig
+	if err := yaml.Unmarshal(f, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse scope config %s: %v", fileName, err)
+	}
+
+	for _, scopes := range []map[string][]string{cfg.Types, cfg.Resources} {
+		for name, paths := range scopes {
+			if len(paths) == 0 {
+				return nil, fmt.Errorf("%s: %s has no property paths", fileName, name)
+			}
+			for _, p := range paths {
+				if _, err := path.Match(p, ""); err != nil || strings.Trim(p, "/") == "" {
+					return nil, fmt.Errorf("%s: %s has an invalid path '%s'", fileName, name, p)
+				}
+			}
+		}
+	}
+
+	return &cfg, nil
+}
+
+// paths returns the property paths that are compared for a resource,
+// or nil if the whole model is compared
+func (cfg *ScopeConfig) paths(name string, typeName string) []string {
+	if cfg == nil {
+		return nil
+	}
+	if paths, ok := cfg.Resources[name]; ok {
+		return paths
+	}
+	return cfg.Types[typeName]
+}
+
+// scopeModel returns the parts of model at the /-separated property paths.
+// Each element of a path is matched like path.Match, so * matches any
+// single key or list index. The model is returned as it is if paths is nil.
+func scopeModel(model map[string]any, paths []string) map[string]any {
+	if paths == nil {
+		return model
+	}
+
+	patterns := make([][]string, 0, len(paths))
+	for _, p := range paths {
+		patterns = append(patterns, strings.Split(strings.Trim(p, "/"), "/"))
+	}
+
+	if scoped, ok := scopeValue(model, patterns).(map[string]any); ok {
+		return scoped
+	}
+	return map[string]any{}
+}
+
+// scopeValue returns the parts of v that patterns select, or nil if there are none.
+// All of v is selected once one of the patterns has no elements left.
+func scopeValue(v any, patterns [][]string) any {
+	for _, p := range patterns {
+		if len(p) == 0 {
+			return v
+		}
+	}
+
+	switch t := v.(type) {
+	case map[string]any:
+		retval := make(map[string]any)
+		for k, e := range t {
+			if s := scopeValue(e, nextPatterns(patterns, k)); s != nil {
+				retval[k] = s
+			}
+		}
+		if len(retval) > 0 {
+			return retval
+		}
+	case []any:
+		// Elements are kept at their index, so that a value that moved to
+		// another element is still drift and paths match the whole model
+		retval := make([]any, 0)
+		selected := false
+		for i, e := range t {
+			s := scopeValue(e, nextPatterns(patterns, strconv.Itoa(i)))
+			if s == nil {
+				retval = append(retval, unselected(e))
+				continue
+			}
+			retval = append(retval, s)
+			selected = true
+		}
+		if selected {
+			return retval
+		}
+	}
+
+	return nil
+}
+
+// unselected returns the value that stands in for a list element with
+// nothing selected in it: an empty value of the same kind, or nil
+func unselected(v any) any {
+	switch v.(type) {
+	case map[string]any:
+		return map[string]any{}
+	case []any:
+		return []any{}
+	}
+	return nil
+}
+
+// nextPatterns returns the rest of each pattern whose first element matches key
+func nextPatterns(patterns [][]string, key string) [][]string {
+	retval := make([][]string, 0)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p[0], key); ok {
+			retval = append(retval, p[1:])
+		}
+	}
+	return retval
+}
@@ -0,0 +1,121 @@
+package cc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/diff"
+)
+
+func TestScopeModel(t *testing.T) {
+	model := map[string]any{
+		"RoleName": "app",
+		"AssumeRolePolicyDocument": map[string]any{
+			"Version": "2012-10-17",
+		},
+		"Policies": []any{
+			map[string]any{"PolicyName": "a", "PolicyDocument": map[string]any{"Statement": []any{}}},
+			map[string]any{"PolicyName": "b"},
+		},
+	}
+
+	cases := []struct {
+		paths    []string
+		expected map[string]any
+	}{
+		{nil, model},
+		{[]string{"AssumeRolePolicyDocument"}, map[string]any{
+			"AssumeRolePolicyDocument": map[string]any{"Version": "2012-10-17"},
+		}},
+		{[]string{"Policies/*/PolicyDocument"}, map[string]any{
+			"Policies": []any{map[string]any{"PolicyDocument": map[string]any{"Statement": []any{}}}, map[string]any{}},
+		}},
+		{[]string{"MaxSessionDuration"}, map[string]any{}},
+	}
+
+	for _, c := range cases {
+		if actual := scopeModel(model, c.paths); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%v: %#v\n!=\n%#v\n", c.paths, actual, c.expected)
+		}
+	}
+
+	// A document that moved to another policy is still drift, at the index it is at
+	stored := map[string]any{"Policies": []any{
+		map[string]any{"PolicyName": "a", "PolicyDocument": "X"},
+		map[string]any{"PolicyName": "b"},
+	}}
+	moved := map[string]any{"Policies": []any{
+		map[string]any{"PolicyName": "a"},
+		map[string]any{"PolicyName": "b", "PolicyDocument": "X"},
+	}}
+	policies := []string{"Policies/*/PolicyDocument"}
+	expected := map[string]any{"Policies": []any{map[string]any{}, map[string]any{"PolicyDocument": "X"}}}
+	if actual := scopeModel(moved, policies); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%#v\n!=\n%#v\n", actual, expected)
+	}
+	d := compareModels(scopeModel(stored, policies), scopeModel(moved, policies), DriftOptions{})
+	if d.Mode() == diff.Unchanged {
+		t.Errorf("expected a moved document to be drift")
+	}
+	paths := make([]string, 0)
+	for _, pd := range newPropertyDiffs(d, scopeModel(stored, policies)) {
+		paths = append(paths, pd.Path)
+	}
+	if !slices.Equal(paths, []string{"Policies/0/PolicyDocument", "Policies/1/PolicyDocument"}) {
+		t.Errorf("unexpected paths %v", paths)
+	}
+
+	// Changes outside of the scope are not drift
+	live := map[string]any{"RoleName": "changed", "AssumeRolePolicyDocument": map[string]any{"Version": "2012-10-17"}}
+	scope := []string{"AssumeRolePolicyDocument"}
+	if d := compareModels(scopeModel(model, scope), scopeModel(live, scope), DriftOptions{}); d.Mode() != diff.Unchanged {
+		t.Errorf("expected no drift in scope, got %s", d.Mode())
+	}
+}
+
+func TestScopeConfig(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "scope.yaml")
+	err := os.WriteFile(fileName, []byte(`
+Types:
+  AWS::IAM::Role:
+    - AssumeRolePolicyDocument
+  AWS::S3::Bucket:
+    - VersioningConfiguration
+Resources:
+  LogBucket:
+    - LoggingConfiguration
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadScopeConfig(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := cfg.paths("Bucket", "AWS::S3::Bucket"); !reflect.DeepEqual(p, []string{"VersioningConfiguration"}) {
+		t.Errorf("unexpected paths for the type: %v", p)
+	}
+	if p := cfg.paths("LogBucket", "AWS::S3::Bucket"); !reflect.DeepEqual(p, []string{"LoggingConfiguration"}) {
+		t.Errorf("expected the resource's paths to be used instead of its type's, got %v", p)
+	}
+	if p := cfg.paths("Queue", "AWS::SQS::Queue"); p != nil {
+		t.Errorf("expected no scope for other types, got %v", p)
+	}
+
+	var none *ScopeConfig
+	if p := none.paths("Bucket", "AWS::S3::Bucket"); p != nil {
+		t.Errorf("expected no scope without a config, got %v", p)
+	}
+
+	if err := os.WriteFile(fileName, []byte("Types:\n  AWS::S3::Bucket: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadScopeConfig(fileName); err == nil {
+		t.Errorf("expected an error for a type without paths")
+	}
+}
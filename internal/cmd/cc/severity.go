@@ -0,0 +1,214 @@
+package cc
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/internal/console"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity levels for drift, from least to most severe
+const (
+	SeverityInfo     = "info"
+	SeverityWarn     = "warn"
+	SeverityCritical = "critical"
+)
+
+// severityRank orders the severity levels, with 0 for no severity
+var severityRank = map[string]int{
+	SeverityInfo:     1,
+	SeverityWarn:     2,
+	SeverityCritical: 3,
+}
+
+// SeverityConfig decides how severe the drift of each property is.
+// It is read from the file passed to --severity-config, for example:
+//
+//	Default: warn
+//	Rules:
+//	  - Path: Tags/**
+//	    Severity: info
+//	  - Type: AWS::EC2::SecurityGroup
+//	    Path: SecurityGroupIngress/**
+//	    Severity: critical
+type SeverityConfig struct {
+	// Default is the severity of properties that no rule matches.
+	// It is warn if it is not set.
+	Default string `yaml:"Default"`
+
+	// Rules are checked in order, and the first one that matches a property is used
+	Rules []SeverityRule `yaml:"Rules"`
+}
+
+// SeverityRule sets the severity of the properties that match Path,
+// in resources of Type, or of any type if Type is empty
+type SeverityRule struct {
+	// Path is a /-separated property path. Each element is matched like
+	// path.Match, so * matches any single key or index, and ** matches
+	// any number of elements, e.g. Tags/** matches every tag.
+	Path string `yaml:"Path"`
+
+	Type     string `yaml:"Type"`
+	Severity string `yaml:"Severity"`
+}
+
+// loadSeverityConfig reads and checks a severity config file
+func loadSeverityConfig(fileName string) (*SeverityConfig, error) {
+	f, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read severity config %s: %v", fileName, err)
+	}
+
+	var cfg SeverityConfig
+	if err := yaml.Unmarshal(f, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse severity config %s: %v", fileName, err)
+	}
+
+	if cfg.Default == "" {
+		cfg.Default = SeverityWarn
+	}
+	if _, ok := severityRank[cfg.Default]; !ok {
+		return nil, fmt.Errorf("%s: unknown default severity '%s'", fileName, cfg.Default)
+	}
+	for i, rule := range cfg.Rules {
+		if _, ok := severityRank[rule.Severity]; !ok {
+			return nil, fmt.Errorf("%s: rule %d has unknown severity '%s'", fileName, i+1, rule.Severity)
+		}
+		if _, err := path.Match(rule.Path, ""); err != nil || rule.Path == "" {
+			return nil, fmt.Errorf("%s: rule %d has an invalid path '%s'", fileName, i+1, rule.Path)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// matchPropertyPath returns true if the /-separated property path matches pattern
+func matchPropertyPath(pattern string, propertyPath string) bool {
+	return matchElements(strings.Split(strings.Trim(pattern, "/"), "/"),
+		strings.Split(strings.Trim(propertyPath, "/"), "/"))
+}
+
+func matchElements(pattern []string, elements []string) bool {
+	if len(pattern) == 0 {
+		return len(elements) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(elements); i++ {
+			if matchElements(pattern[1:], elements[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(elements) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], elements[0]); !ok {
+		return false
+	}
+	return matchElements(pattern[1:], elements[1:])
+}
+
+// propertySeverity returns the severity of a change to the property
+// at propertyPath in a resource of typeName
+func (cfg *SeverityConfig) propertySeverity(typeName string, propertyPath string) string {
+	for _, rule := range cfg.Rules {
+		if rule.Type != "" && rule.Type != typeName {
+			continue
+		}
+		if matchPropertyPath(rule.Path, propertyPath) {
+			return rule.Severity
+		}
+	}
+	return cfg.Default
+}
+
+// classify sets the severity of each difference in rd, and of rd itself
+// to the highest of them. A deleted resource, or one whose type changed,
+// is always critical.
+func (cfg *SeverityConfig) classify(rd *ResourceDrift) {
+	if !rd.Drifted {
+		return
+	}
+
+	if rd.Deleted || rd.TypeChanged {
+		rd.Severity = SeverityCritical
+		return
+	}
+
+	rd.Severity = ""
+	for i := range rd.Differences {
+		s := cfg.propertySeverity(rd.Type, rd.Differences[i].Path)
+		rd.Differences[i].Severity = s
+		rd.Severity = maxSeverity(rd.Severity, s)
+	}
+
+	// Resources that only differ by a warning, like being missing from
+	// one of two compared state files, have no property differences
+	if rd.Severity == "" {
+		rd.Severity = cfg.Default
+	}
+}
+
+// classifyAll sets the severity of each result. Nothing is rated if cfg is nil.
+func classifyAll(results []*ResourceDrift, cfg *SeverityConfig) {
+	if cfg == nil {
+		return
+	}
+	for _, rd := range results {
+		cfg.classify(rd)
+	}
+}
+
+// highestSeverity returns the highest severity of the results,
+// or an empty string if none of them has a severity
+func highestSeverity(results []*ResourceDrift) string {
+	highest := ""
+	for _, rd := range results {
+		highest = maxSeverity(highest, rd.Severity)
+	}
+	return highest
+}
+
+// maxSeverity returns the more severe of a and b
+func maxSeverity(a, b string) string {
+	if severityRank[b] > severityRank[a] {
+		return b
+	}
+	return a
+}
+
+// colorSeverity colors s according to the severity of the drift
+func colorSeverity(severity string, s string) string {
+	switch severity {
+	case SeverityInfo:
+		return console.Cyan(s)
+	case SeverityWarn:
+		return console.Yellow(s)
+	default:
+		return console.Red(s)
+	}
+}
+
+// severitySuffix returns a suffix like " (critical)" for severity,
+// or an empty string if there is no severity
+func severitySuffix(severity string) string {
+	if severity == "" {
+		return ""
+	}
+	return " (" + severity + ")"
+}
+
+// severityLabel returns a prefix like "[critical] " for severity,
+// or an empty string if there is no severity
+func severityLabel(severity string) string {
+	if severity == "" {
+		return ""
+	}
+	return "[" + severity + "] "
+}
@@ -0,0 +1,123 @@
+package cc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPropertyPath(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"Tags/**", "Tags/0/Value", true},
+		{"Tags/**", "Tags", true},
+		{"Tags/*/Value", "Tags/3/Value", true},
+		{"Tags/*/Value", "Tags/3/Key", false},
+		{"**/CidrIp", "SecurityGroupIngress/0/CidrIp", true},
+		{"Security*/**", "SecurityGroupEgress/1", true},
+		{"BucketName", "BucketName", true},
+		{"BucketName", "BucketNamePrefix", false},
+	}
+
+	for _, c := range cases {
+		if actual := matchPropertyPath(c.pattern, c.path); actual != c.expected {
+			t.Errorf("%s %s: %v != %v", c.pattern, c.path, actual, c.expected)
+		}
+	}
+}
+
+func TestSeverityConfig(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "severity.yaml")
+	err := os.WriteFile(fileName, []byte(`
+Rules:
+  - Path: Tags/**
+    Severity: info
+  - Type: AWS::EC2::SecurityGroup
+    Path: SecurityGroupIngress/**
+    Severity: critical
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadSeverityConfig(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Default != SeverityWarn {
+		t.Errorf("expected the default severity to be warn, got %s", cfg.Default)
+	}
+
+	results := []*ResourceDrift{
+		{Name: "Ok", Type: "AWS::S3::Bucket"},
+		{Name: "Tagged", Type: "AWS::S3::Bucket", Drifted: true,
+			Differences: []PropertyDiff{{Path: "Tags/0/Value"}}},
+		{Name: "Bucket", Type: "AWS::S3::Bucket", Drifted: true,
+			Differences: []PropertyDiff{{Path: "Tags/0/Value"}, {Path: "BucketName"}}},
+		{Name: "Group", Type: "AWS::EC2::SecurityGroup", Drifted: true,
+			Differences: []PropertyDiff{{Path: "SecurityGroupIngress/0/CidrIp"}}},
+		{Name: "Other", Type: "AWS::EC2::Instance", Drifted: true,
+			Differences: []PropertyDiff{{Path: "SecurityGroupIngress/0/CidrIp"}}},
+		{Name: "Deleted", Type: "AWS::S3::Bucket", Drifted: true, Deleted: true},
+		{Name: "Replaced", Type: "AWS::S3::Bucket", Drifted: true, TypeChanged: true},
+	}
+	classifyAll(results, cfg)
+
+	expected := []string{"", SeverityInfo, SeverityWarn, SeverityCritical, SeverityWarn, SeverityCritical, SeverityCritical}
+	for i, rd := range results {
+		if rd.Severity != expected[i] {
+			t.Errorf("%s: expected %q, got %q", rd.Name, expected[i], rd.Severity)
+		}
+	}
+	if s := results[2].Differences[0].Severity; s != SeverityInfo {
+		t.Errorf("expected the tag change to be info, got %s", s)
+	}
+
+	if s := highestSeverity(results[:3]); s != SeverityWarn {
+		t.Errorf("expected the highest severity to be warn, got %s", s)
+	}
+	if code := driftExitCode(highestSeverity(results)); code != driftExitCritical {
+		t.Errorf("expected exit code %d, got %d", driftExitCritical, code)
+	}
+	if code := driftExitCode(""); code != driftExitDrift {
+		t.Errorf("expected exit code %d without severities, got %d", driftExitDrift, code)
+	}
+
+	if err := os.WriteFile(fileName, []byte("Default: severe\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSeverityConfig(fileName); err == nil {
+		t.Errorf("expected an error for an unknown severity")
+	}
+}
+
+func TestDriftExitStatus(t *testing.T) {
+	defer func(f string, c bool, s string) {
+		driftFailOn, driftTypeChanged, driftHighestSeverity = f, c, s
+	}(driftFailOn, driftTypeChanged, driftHighestSeverity)
+
+	cases := []struct {
+		failOn      string
+		failed      bool
+		drifted     bool
+		typeChanged bool
+		expected    int
+	}{
+		{"none", true, true, true, 0},
+		{"error", false, true, true, 0},
+		{"error", true, false, false, driftExitError},
+		{"drift", false, false, false, 0},
+		{"drift", false, true, false, driftExitDrift},
+		{"drift", false, true, true, driftExitTypeChanged},
+		{"drift", true, true, false, driftExitError},
+	}
+	for _, c := range cases {
+		driftFailOn, driftTypeChanged, driftHighestSeverity = c.failOn, c.typeChanged, ""
+		if code := driftExitStatus(c.failed, c.drifted); code != c.expected {
+			t.Errorf("%+v: got %d", c, code)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/aws-cloudformation/rain/cft"
@@ -27,6 +28,9 @@ type StateResult struct {
 	StateFile cft.Template
 	Lock      string
 	IsUpdate  bool
+
+	// Compressed is true if the state file is written gzip-compressed
+	Compressed bool
 }
 
 // addCommon adds common elements to the state file
@@ -118,8 +122,14 @@ func checkState(
 		addCommon(stateMap, absPath)
 
 		// Write the state file to the bucket
+		result.Compressed = compressState
 		str := format.String(state, format.Options{JSON: false, Unsorted: false})
-		err := s3.PutObject(bucketName, key, []byte(str))
+		body, err := encodeState(str, result.Compressed)
+		if err != nil {
+			spinner.Pop()
+			return nil, err
+		}
+		err = s3.PutObjectWithChecksum(bucketName, key, body)
 		spinner.Pop()
 		if err != nil {
 			return nil, fmt.Errorf("unable to write state to bucket: %v", err)
@@ -132,6 +142,13 @@ func checkState(
 
 		config.Debugf("Found existing state file")
 
+		// A compressed state file stays compressed
+		obj, compressed, err := decompressState(obj)
+		if err != nil {
+			return nil, err
+		}
+		result.Compressed = compressed || compressState
+
 		state, err := parse.String(string(obj))
 		if err != nil {
 			return nil, fmt.Errorf("unable to parse state file: %v", err)
@@ -167,8 +184,14 @@ func checkState(
 			}
 		}
 
-		// Check to see if the deployment has drifted
-		if err := runDriftOnState(name, state, bucketName, key); err != nil {
+		// Check to see if the deployment has drifted. A state file that was only
+		// locked for a first deployment is checked after the lock, so that
+		// the lock is what is reported.
+		if err := validateState(state); err != nil {
+			return nil, err
+		}
+		src := stateSource{bucket: bucketName, key: key, compressed: result.Compressed}
+		if _, err := runDriftOnState(os.Stdout, name, state, src); err != nil {
 			return nil, err
 		}
 
@@ -185,7 +208,11 @@ func checkState(
 		addCommon(stateMap, absPath)
 
 		str := format.String(state, format.Options{JSON: false, Unsorted: false})
-		err = s3.PutObject(bucketName, key, []byte(str))
+		body, err := encodeState(str, result.Compressed)
+		if err != nil {
+			return nil, err
+		}
+		err = s3.PutObjectWithChecksum(bucketName, key, body)
 		if err != nil {
 			return nil, fmt.Errorf("unable to write updated state file to bucket: %v", err)
 		}
@@ -203,7 +230,8 @@ func writeState(
 	results *DeploymentResults,
 	bucketName string,
 	name string,
-	absPath string) error {
+	absPath string,
+	compress bool) error {
 
 	original := format.String(state, format.Options{JSON: false, Unsorted: false})
 	config.Debugf("writeState original template: %v", original)
@@ -242,6 +270,7 @@ func writeState(
 
 			resourceStateMap := node.AddMap(resourceModels, name)
 			node.Add(resourceStateMap, "Identifier", resource.Identifier)
+			node.Add(resourceStateMap, "Type", resource.Type)
 			modelMap := node.AddMap(resourceStateMap, "Model")
 			var parsed map[string]any
 			json.Unmarshal([]byte(resource.Model), &parsed)
@@ -257,7 +286,11 @@ func writeState(
 	str := format.String(state, format.Options{JSON: false, Unsorted: false})
 	config.Debugf("About to write state file:\n%v", str)
 	key := getStateFileKey(name)
-	err := s3.PutObject(bucketName, key, []byte(str))
+	body, err := encodeState(str, compress)
+	if err != nil {
+		return err
+	}
+	err = s3.PutObjectWithChecksum(bucketName, key, body)
 	if err != nil {
 		return fmt.Errorf("unable to write unlocked state file to bucket: %v", err)
 	}
@@ -279,6 +312,9 @@ func runState(cmd *cobra.Command, args []string) {
 	key := getStateFileKey(name)
 
 	obj, err := s3.GetObject(bucketName, key)
+	if err == nil {
+		obj, _, err = decompressState(obj)
+	}
 	if err != nil {
 		fmt.Printf("Unable to download state: %v", err)
 		return
@@ -0,0 +1,122 @@
+package cc
+
+import (
+	"strings"
+)
+
+// defaultManagedTagPrefixes are the tag key prefixes that AWS reserves for
+// the tags it adds itself, like aws:cloudformation:stack-name
+var defaultManagedTagPrefixes = []string{"aws:"}
+
+// isManagedTag returns true if key starts with one of the prefixes
+func isManagedTag(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutManagedTags returns a copy of model without the tags whose keys
+// start with one of the prefixes. Tags can be a list of Key and Value maps,
+// or a map of keys to values, depending on the resource type.
+// Only the top level Tags property is changed, and it is
+// removed if every tag in it was managed.
+func withoutManagedTags(model map[string]any, prefixes []string) map[string]any {
+	tags, ok := model["Tags"]
+	if !ok || len(prefixes) == 0 {
+		return model
+	}
+
+	var filtered any
+	removed := false
+	switch t := tags.(type) {
+	case []any:
+		kept := make([]any, 0, len(t))
+		for _, tag := range t {
+			if m, ok := tag.(map[string]any); ok {
+				if key, ok := m["Key"].(string); ok && isManagedTag(key, prefixes) {
+					removed = true
+					continue
+				}
+			}
+			kept = append(kept, tag)
+		}
+		filtered = kept
+		removed = removed && len(kept) == 0
+	case map[string]any:
+		kept := make(map[string]any)
+		for key, val := range t {
+			if !isManagedTag(key, prefixes) {
+				kept[key] = val
+			}
+		}
+		filtered = kept
+		removed = len(t) > 0 && len(kept) == 0
+	default:
+		return model
+	}
+
+	retval := make(map[string]any, len(model))
+	for k, v := range model {
+		retval[k] = v
+	}
+	retval["Tags"] = filtered
+
+	// A template with only managed tags has no Tags at all
+	if removed {
+		delete(retval, "Tags")
+	}
+	return retval
+}
+
+// tagValue returns the value of the tag with key in model's Tags,
+// which can be a list of Key and Value maps or a map of keys to values
+func tagValue(model map[string]any, key string) (string, bool) {
+	switch t := model["Tags"].(type) {
+	case []any:
+		for _, tag := range t {
+			if m, ok := tag.(map[string]any); ok && m["Key"] == key {
+				v, ok := m["Value"].(string)
+				return v, ok
+			}
+		}
+	case map[string]any:
+		v, ok := t[key].(string)
+		return v, ok
+	}
+	return "", false
+}
+
+// hasTags returns true if model has every tag in tags, with the same value
+func hasTags(model map[string]any, tags map[string]string) bool {
+	for key, want := range tags {
+		if v, ok := tagValue(model, key); !ok || v != want {
+			return false
+		}
+	}
+	return true
+}
+
+// filterByTag returns the results whose live model has all of tags, and
+// the number that were left out. Resources without a live model, because
+// they were deleted or could not be queried, are matched by their stored
+// model instead, so that they are not hidden.
+func filterByTag(results []*ResourceDrift, tags map[string]string) ([]*ResourceDrift, int) {
+	if len(tags) == 0 {
+		return results, 0
+	}
+
+	kept := make([]*ResourceDrift, 0, len(results))
+	for _, rd := range results {
+		model := rd.liveModel
+		if rd.Deleted || rd.Error != "" || rd.Incomplete {
+			model = rd.stateModel
+		}
+		if hasTags(model, tags) {
+			kept = append(kept, rd)
+		}
+	}
+	return kept, len(results) - len(kept)
+}
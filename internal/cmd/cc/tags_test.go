@@ -0,0 +1,97 @@
+package cc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithoutManagedTags(t *testing.T) {
+	prefixes := []string{"aws:", "internal:"}
+
+	cases := []struct {
+		model    map[string]any
+		expected map[string]any
+	}{
+		{
+			map[string]any{"Tags": []any{
+				map[string]any{"Key": "aws:cloudformation:stack-name", "Value": "x"},
+				map[string]any{"Key": "Name", "Value": "y"},
+				map[string]any{"Key": "internal:owner", "Value": "z"},
+			}},
+			map[string]any{"Tags": []any{
+				map[string]any{"Key": "Name", "Value": "y"},
+			}},
+		},
+		{
+			map[string]any{"Name": "a", "Tags": map[string]any{"aws:createdBy": "x", "Team": "web"}},
+			map[string]any{"Name": "a", "Tags": map[string]any{"Team": "web"}},
+		},
+		{
+			map[string]any{"Name": "a"},
+			map[string]any{"Name": "a"},
+		},
+		{
+			map[string]any{"Name": "a", "Tags": []any{map[string]any{"Key": "aws:x", "Value": "1"}}},
+			map[string]any{"Name": "a"},
+		},
+		{
+			map[string]any{"Name": "a", "Tags": map[string]any{"aws:createdBy": "x"}},
+			map[string]any{"Name": "a"},
+		},
+		{
+			map[string]any{"Name": "a", "Tags": []any{}},
+			map[string]any{"Name": "a", "Tags": []any{}},
+		},
+	}
+
+	for _, c := range cases {
+		actual := withoutManagedTags(c.model, prefixes)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%#v\n!=\n%#v\n", actual, c.expected)
+		}
+	}
+
+	// The model that was passed in is not changed
+	model := map[string]any{"Tags": []any{map[string]any{"Key": "aws:x", "Value": "1"}}}
+	withoutManagedTags(model, prefixes)
+	if len(model["Tags"].([]any)) != 1 {
+		t.Errorf("expected the original tags to be unchanged")
+	}
+}
+
+func TestFilterByTag(t *testing.T) {
+	listTags := func(kv ...string) map[string]any {
+		tags := make([]any, 0)
+		for i := 0; i+1 < len(kv); i += 2 {
+			tags = append(tags, map[string]any{"Key": kv[i], "Value": kv[i+1]})
+		}
+		return map[string]any{"Tags": tags}
+	}
+
+	results := []*ResourceDrift{
+		{Name: "Payments", liveModel: listTags("Team", "payments", "Env", "prod")},
+		{Name: "Search", liveModel: listTags("Team", "search")},
+		{Name: "Mapped", liveModel: map[string]any{"Tags": map[string]any{"Team": "payments"}}},
+		{Name: "Untagged", liveModel: map[string]any{}},
+		{Name: "Deleted", Deleted: true, liveModel: map[string]any{}, stateModel: listTags("Team", "payments")},
+	}
+
+	kept, skipped := filterByTag(results, map[string]string{"Team": "payments"})
+	names := make([]string, 0)
+	for _, rd := range kept {
+		names = append(names, rd.Name)
+	}
+	expected := []string{"Payments", "Mapped", "Deleted"}
+	if !reflect.DeepEqual(names, expected) || skipped != 2 {
+		t.Errorf("%#v (%d skipped)\n!=\n%#v\n", names, skipped, expected)
+	}
+
+	kept, _ = filterByTag(results, map[string]string{"Team": "payments", "Env": "prod"})
+	if len(kept) != 1 || kept[0].Name != "Payments" {
+		t.Errorf("expected only Payments to have both tags")
+	}
+
+	if kept, skipped := filterByTag(results, nil); len(kept) != len(results) || skipped != 0 {
+		t.Errorf("expected no filter to keep every resource")
+	}
+}
@@ -0,0 +1,101 @@
+package cc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// fnTransform is the key of the intrinsic that runs a macro on part of a template.
+// Its short form, !Transform, is left as a tag by the parser.
+const fnTransform = "Fn::Transform"
+
+// isTransform returns true if n is the short form of Fn::Transform
+func isTransform(n *yaml.Node) bool {
+	return n.Tag == "!Transform"
+}
+
+// templateTransforms returns where a template uses transforms, which
+// CloudFormation runs but rain does not: each macro in its Transform
+// section, and the path of each Fn::Transform
+func templateTransforms(template cft.Template) []string {
+	retval := sectionTransforms(template)
+
+	template.Walk(func(path string, n *yaml.Node) error {
+		if strings.HasSuffix(path, "/"+fnTransform) || isTransform(n) {
+			retval = append(retval, path)
+		}
+		return nil
+	})
+
+	return retval
+}
+
+// sectionTransforms returns the macros in the Transform section of a template,
+// which CloudFormation runs on every resource
+func sectionTransforms(template cft.Template) []string {
+	retval := make([]string, 0)
+
+	section, err := template.GetSection(cft.Transform)
+	if err != nil {
+		return retval
+	}
+	switch section.Kind {
+	case yaml.ScalarNode:
+		retval = append(retval, section.Value)
+	case yaml.SequenceNode:
+		for _, macro := range section.Content {
+			if macro.Kind == yaml.ScalarNode {
+				retval = append(retval, macro.Value)
+			} else {
+				retval = append(retval, string(cft.Transform))
+			}
+		}
+	default:
+		retval = append(retval, string(cft.Transform))
+	}
+	return retval
+}
+
+// hasTransform returns true if n, usually a resource, contains an Fn::Transform
+func hasTransform(n *yaml.Node) bool {
+	if n == nil {
+		return false
+	}
+	if isTransform(n) {
+		return true
+	}
+	if n.Kind == yaml.MappingNode {
+		if k, _, _ := s11n.GetMapValue(n, fnTransform); k != nil {
+			return true
+		}
+	}
+	for _, child := range n.Content {
+		if hasTransform(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// warnTransforms warns on stderr that the template of the named deployment
+// uses transforms, so that it is seen even when the report is printed as JSON.
+// Drift compares the stored models, which are what Cloud Control API returned
+// for the deployed resources, so it is only changing live state from the
+// untransformed template that can't be trusted.
+func warnTransforms(name string, template cft.Template) {
+	transforms := templateTransforms(template)
+	if len(transforms) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr, console.Yellow(fmt.Sprintf(
+		"WARNING: the template for %s uses transforms that rain does not run (%s). "+
+			"Drift is checked against the stored models, but the live state of a resource "+
+			"that is transformed can't be changed to match its template.",
+		name, strings.Join(transforms, ", "))))
+}
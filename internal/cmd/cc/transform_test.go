@@ -0,0 +1,106 @@
+package cc
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/parse"
+)
+
+func TestTemplateTransforms(t *testing.T) {
+	template, err := parse.String(`
+Transform:
+  - AWS::Serverless-2016-10-31
+  - MyMacro
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      Fn::Transform:
+        Name: AWS::Include
+        Parameters:
+          Location: s3://bucket/props.yaml
+  Queue:
+    Type: AWS::SQS::Queue
+    Properties:
+      QueueName: !Transform {Name: Upper, Parameters: {Value: q}}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"AWS::Serverless-2016-10-31",
+		"MyMacro",
+		"Resources/Bucket/Properties/Fn::Transform",
+		"Resources/Queue/Properties/QueueName",
+	}
+	if actual := templateTransforms(template); !slices.Equal(actual, expected) {
+		t.Errorf("%#v\n!=\n%#v\n", actual, expected)
+	}
+
+	resources, err := template.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasTransform(resources["Queue"]) {
+		t.Errorf("expected Queue to have a transform")
+	}
+
+	plain, err := parse.String(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Sub ${AWS::StackName}-Transform
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := templateTransforms(plain); len(actual) != 0 {
+		t.Errorf("expected no transforms, got %s", strings.Join(actual, ", "))
+	}
+	resources, err = plain.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasTransform(resources["Bucket"]) {
+		t.Errorf("expected Bucket to have no transform")
+	}
+}
+
+func TestUpdateInputsSectionTransform(t *testing.T) {
+	template, err := parse.String(`
+Transform: AWS::Serverless-2016-10-31
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, err := template.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(prior cft.Template) { deployedTemplate = prior }(deployedTemplate)
+	deployedTemplate = template
+
+	schemas := &schemaCache{schemas: map[string]*typeSchema{
+		"AWS::S3::Bucket": {PrimaryIdentifier: []string{"BucketName"}},
+	}}
+	s := selection{
+		ResourceName:       "Bucket",
+		ResourceType:       "AWS::S3::Bucket",
+		DeploymentResource: &Resource{Name: "Bucket", Type: "AWS::S3::Bucket", Node: resources["Bucket"]},
+	}
+	_, _, err = updateInputs(s, schemas)
+	if err == nil || !strings.Contains(err.Error(), "AWS::Serverless-2016-10-31") {
+		t.Errorf("expected the Transform section to block changing live state, got %v", err)
+	}
+}
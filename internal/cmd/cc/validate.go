@@ -0,0 +1,58 @@
+package cc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// validateState checks that a state file has the sections that drift needs,
+// and that its resources are consistent with their models.
+// It reports the line numbers of any problems so they are easy to fix
+func validateState(template cft.Template) error {
+	if template.Node == nil || len(template.Node.Content) == 0 {
+		return fmt.Errorf("%w: state file is empty", ErrMissingSection)
+	}
+
+	root := template.Node.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%w: expected the state file to be a map (line %d)", ErrMissingSection, root.Line)
+	}
+
+	resourcesKey, resources, _ := s11n.GetMapValue(root, string(cft.Resources))
+	if resources == nil {
+		return fmt.Errorf("%w: state file missing required key 'Resources'", ErrMissingSection)
+	}
+	if resources.Kind != yaml.MappingNode {
+		return fmt.Errorf("%w: expected 'Resources' to be a map (line %d)", ErrMissingSection, resourcesKey.Line)
+	}
+
+	stateKey, state, _ := s11n.GetMapValue(root, string(cft.State))
+	if state == nil {
+		return ErrMissingState
+	}
+	if state.Kind != yaml.MappingNode {
+		return fmt.Errorf("%w: expected 'State' to be a map (line %d)", ErrMissingSection, stateKey.Line)
+	}
+
+	for _, key := range []string{"FilePath", "LastWriteTime", "ResourceModels"} {
+		k, v, _ := s11n.GetMapValue(state, key)
+		if v == nil {
+			return fmt.Errorf("%w: state file missing required key '%s' - expected under 'State' (line %d)",
+				ErrMissingSection, key, stateKey.Line)
+		}
+		if key == "ResourceModels" && v.Kind != yaml.MappingNode {
+			return fmt.Errorf("%w: expected 'ResourceModels' to be a map (line %d)", ErrMissingSection, k.Line)
+		}
+	}
+
+	// Report every problem with the resources and their models at once
+	if errs := template.Validate(); len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrMissingSection, errors.Join(errs...))
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package cc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+)
+
+func TestValidateState(t *testing.T) {
+	testCases := []struct {
+		state    string
+		expected string
+	}{
+		{`
+Resources: {}
+State:
+  FilePath: a.yaml
+  LastWriteTime: "2024-05-01T12:00:00Z"
+  ResourceModels: {}
+`, ""},
+		{`
+Resources: {}
+`, "missing required key 'State'"},
+		{`
+Resources: {}
+State:
+  FilePath: a.yaml
+  LastWriteTime: "2024-05-01T12:00:00Z"
+`, "missing required key 'ResourceModels' - expected under 'State' (line 3)"},
+		{`
+Resources: {}
+State:
+  FilePath: a.yaml
+  LastWriteTime: "2024-05-01T12:00:00Z"
+  ResourceModels: []
+`, "expected 'ResourceModels' to be a map (line 6)"},
+		{`
+Resources:
+  A:
+    Properties: {}
+  B:
+    Type: AWS::S3::Bucket
+State:
+  FilePath: a.yaml
+  LastWriteTime: "2024-05-01T12:00:00Z"
+  ResourceModels:
+    A:
+      Identifier: a
+`, "resource A is missing 'Type' (line 3)\nresource B has no entry in 'ResourceModels' (line 5)"},
+	}
+
+	for _, testCase := range testCases {
+		template, err := parse.String(testCase.state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = validateState(template)
+		if testCase.expected == "" {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), testCase.expected) || !errors.Is(err, ErrMissingSection) {
+			t.Errorf("expected %q, got %v", testCase.expected, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package cc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/console/spinner"
+)
+
+// driftWatch is set by the --watch flag on cc drift
+var driftWatch bool
+
+// driftInterval is set by the --interval flag on cc drift
+var driftInterval time.Duration = 5 * time.Minute
+
+// watchHistory is the number of checks shown in the rolling status of --watch
+const watchHistory = 12
+
+// watchRun is the outcome of one check made by --watch
+type watchRun struct {
+	time    time.Time
+	drifted int
+	err     error
+}
+
+// status describes the outcome of a check in a few words, like 10:05 ok
+func (r watchRun) status() string {
+	t := r.time.Format("15:04")
+	switch {
+	case r.err != nil:
+		return console.Yellow(t + " error")
+	case r.drifted > 0:
+		return console.Red(fmt.Sprintf("%s %d drifted", t, r.drifted))
+	default:
+		return console.Green(t + " ok")
+	}
+}
+
+// rollingStatus describes the most recent checks, oldest first
+func rollingStatus(runs []watchRun) string {
+	runs = runs[max(len(runs)-watchHistory, 0):]
+	parts := make([]string, 0, len(runs))
+	for _, r := range runs {
+		parts = append(parts, r.status())
+	}
+	return strings.Join(parts, "  ")
+}
+
+// watchResult is what DetectDrift returned for a check made by --watch
+type watchResult struct {
+	report *DriftReport
+	err    error
+}
+
+// runWatch checks the named deployment for drift every --interval,
+// clearing the screen before showing the results, until it is interrupted
+func runWatch(w io.Writer, name string) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	// Schemas don't change between checks, so they are only loaded once
+	opts := driftOptions()
+	opts.schemas = newSchemaCache()
+
+	runs := make([]watchRun, 0)
+	for {
+		done := make(chan watchResult, 1)
+		go func() {
+			report, err := DetectDrift(name, opts)
+			done <- watchResult{report, err}
+		}()
+
+		var result watchResult
+		select {
+		case result = <-done:
+		case <-interrupt:
+			stopWatch(w, name)
+			return
+		}
+
+		run := watchRun{time: time.Now(), err: result.err}
+		if result.report != nil {
+			for _, rd := range result.report.Resources {
+				if rd.Drifted {
+					run.drifted++
+				}
+			}
+		}
+		runs = append(runs, run)
+		if len(runs) > watchHistory {
+			runs = runs[1:]
+		}
+
+		printWatch(w, name, result, runs)
+
+		select {
+		case <-time.After(driftInterval):
+		case <-interrupt:
+			stopWatch(w, name)
+			return
+		}
+	}
+}
+
+// printWatch replaces the screen with the results of the latest check
+func printWatch(w io.Writer, name string, result watchResult, runs []watchRun) {
+	last := runs[len(runs)-1]
+
+	console.ClearScreen()
+	fmt.Fprint(w, console.Blue("Deployment name:  "))
+	fmt.Fprint(w, console.Cyan(fmt.Sprintf("%s\n", name)))
+	fmt.Fprint(w, console.Blue("Last checked:     "))
+	fmt.Fprint(w, console.Cyan(fmt.Sprintf("%s\n", last.time.Format(time.RFC3339))))
+	fmt.Fprint(w, console.Blue("Next check:       "))
+	fmt.Fprint(w, console.Cyan(fmt.Sprintf("%s\n", last.time.Add(driftInterval).Format(time.RFC3339))))
+	fmt.Fprintln(w)
+
+	if result.err != nil {
+		fmt.Fprintln(w, console.Red(fmt.Sprintf("Unable to check drift: %v", result.err)))
+	} else {
+		printDriftSummary(w, result.report.Resources)
+		printSkippedByType(w, result.report.SkippedByType)
+		printSkippedRetained(w, result.report.SkippedRetained)
+		printSkippedByTag(w, result.report.SkippedByTag)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprint(w, console.Blue("Recent checks:    "))
+	fmt.Fprintln(w, rollingStatus(runs))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Press Ctrl+C to stop watching")
+}
+
+// stopWatch leaves the terminal tidy when --watch is interrupted
+func stopWatch(w io.Writer, name string) {
+	spinner.Stop()
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Stopped watching %s\n", name)
+}
@@ -0,0 +1,29 @@
+package cc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws-cloudformation/rain/internal/console"
+)
+
+func TestRollingStatus(t *testing.T) {
+	defer func(n bool) { console.NoColour = n }(console.NoColour)
+	console.NoColour = true
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	runs := make([]watchRun, 0)
+	for i := 0; i < watchHistory+2; i++ {
+		runs = append(runs, watchRun{time: start.Add(time.Duration(i) * 5 * time.Minute)})
+	}
+	runs[len(runs)-2].drifted = 2
+	runs[len(runs)-1].err = errors.New("throttled")
+
+	actual := rollingStatus(runs)
+	expected := "10:10 ok  10:15 ok  10:20 ok  10:25 ok  10:30 ok  10:35 ok  10:40 ok  " +
+		"10:45 ok  10:50 ok  10:55 ok  11:00 2 drifted  11:05 error"
+	if actual != expected {
+		t.Errorf("%q != %q", actual, expected)
+	}
+}
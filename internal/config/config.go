@@ -15,6 +15,10 @@ var Profile = ""
 // Region holds the requested AWS region name
 var Region = ""
 
+// EndpointURL holds the requested AWS endpoint, for testing against a mock
+// like LocalStack. The AWS SDK chooses the endpoint if it is empty.
+var EndpointURL = ""
+
 // Debugf prints messages for stdout only if Debug is true
 func Debugf(message string, parts ...interface{}) {
 	if Debug {
@@ -23,6 +23,16 @@ var isANSI bool
 // NoColour should be false if you want output to be coloured
 var NoColour = false
 
+// noColourEnv is true if the NO_COLOR environment variable is set to
+// anything but an empty string. See https://no-color.org
+var noColourEnv = os.Getenv("NO_COLOR") != ""
+
+// HasColour returns true if output will be coloured. Colour is turned off
+// by NoColour, by the NO_COLOR environment variable, or when stdout is not a terminal.
+func HasColour() bool {
+	return !NoColour && !noColourEnv && IsTTY
+}
+
 func init() {
 	IsTTY = term.IsTerminal(int(os.Stdout.Fd()))
 	isANSI = true
@@ -68,6 +78,13 @@ func ClearLine() {
 	}
 }
 
+// ClearScreen removes all text from the console and puts the cursor at the top left
+func ClearScreen() {
+	if IsTTY && isANSI {
+		fmt.Print("\033[H\033[2J")
+	}
+}
+
 // ClearLines removes all text from the previous n lines (starting with the current line) and puts the cursor on the left
 func ClearLines(n int) {
 	if !IsTTY {
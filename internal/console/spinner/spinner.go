@@ -5,6 +5,7 @@ package spinner
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws-cloudformation/rain/internal/config"
@@ -13,33 +14,76 @@ import (
 
 var spin = []string{"˙", "·", ".", " "}
 
+// mu guards the state below, so that the spinner can be used from several goroutines
+var mu sync.Mutex
+
 var hasTimer = false
 
 var statuses []string
 var count = 0
 var startTime time.Time
 var paused = false
+var disabled = false
 
 var lastLine = ""
 
+// NoSpinner turns the spinner off, for output that is captured in logs.
+// The spinner is also silent if stdout is not a terminal.
+var NoSpinner = false
+
+// group is the status shown instead of the stack while a group is started,
+// groupActive is the number of messages pushed to it that are not popped yet,
+// and groupDone is the number that have been popped
+var group = ""
+var groupActive = 0
+var groupDone = 0
+
 func init() {
 	statuses = make([]string, 0)
 
 	go func() {
 		for console.IsTTY && !config.Debug {
-			if !paused && len(statuses) > 0 {
+			mu.Lock()
+			if !paused && (len(statuses) > 0 || group != "") {
 				update()
 				count = (count + 1) % len(spin)
 			}
+			mu.Unlock()
 
 			time.Sleep(time.Second / 7)
 		}
 	}()
 }
 
+// current returns the status to show, and false if there is none
+func current() (string, bool) {
+	if group != "" {
+		counts := make([]string, 0, 2)
+		if groupDone > 0 {
+			counts = append(counts, fmt.Sprintf("%d done", groupDone))
+		}
+		if groupActive > 0 {
+			counts = append(counts, fmt.Sprintf("%d in progress", groupActive))
+		}
+		if len(counts) == 0 {
+			return group, true
+		}
+		return fmt.Sprintf("%s (%s)", group, strings.Join(counts, ", ")), true
+	}
+	if len(statuses) > 0 {
+		return statuses[len(statuses)-1], true
+	}
+	return "", false
+}
+
+// update redraws the spinner. The caller must hold mu.
 func update() {
+	if disabled || NoSpinner {
+		return
+	}
+
 	if config.Debug {
-		if len(statuses) > 0 {
+		if group == "" && len(statuses) > 0 {
 			config.Debugf(statuses[len(statuses)-1])
 			statuses = statuses[:len(statuses)-1]
 		}
@@ -53,8 +97,8 @@ func update() {
 
 	console.ClearLines(console.CountLines(lastLine))
 
-	if !paused && len(statuses) > 0 {
-		status := strings.TrimSpace(statuses[len(statuses)-1])
+	if status, ok := current(); !paused && ok {
+		status = strings.TrimSpace(status)
 
 		if hasTimer {
 			lastLine = fmt.Sprintf("%s%s%s %s %s",
@@ -77,24 +121,39 @@ func update() {
 	}
 }
 
-// Push enables the spinner and displays the provided message
+// Push enables the spinner and displays the provided message.
+// While a group is started, the message is counted towards the group instead.
 func Push(status string) {
-	statuses = append(statuses, status)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if group != "" {
+		groupActive++
+		if config.Debug {
+			config.Debugf(status)
+		}
+	} else {
+		statuses = append(statuses, status)
+	}
 
 	update()
 }
 
 // StartTimer enables the spinner and displays a timer counting upwards from 0
 func StartTimer(status string) {
+	mu.Lock()
 	startTime = time.Now()
 	hasTimer = true
+	mu.Unlock()
 
 	Push(status)
 }
 
 // StopTimer disables the timer
 func StopTimer() {
+	mu.Lock()
 	hasTimer = false
+	mu.Unlock()
 
 	Pop()
 
@@ -102,9 +161,18 @@ func StopTimer() {
 	// time.Since(startTime).Truncate(time.Second),
 }
 
-// Pop removes the move recent status and stops the spinner if there are no more messages
+// Pop removes the move recent status and stops the spinner if there are no more messages.
+// While a group is started, it removes one of the messages counted towards the group.
 func Pop() {
-	if len(statuses) > 0 {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if group != "" {
+		if groupActive > 0 {
+			groupActive--
+			groupDone++
+		}
+	} else if len(statuses) > 0 {
 		statuses = statuses[:len(statuses)-1]
 	}
 
@@ -113,8 +181,44 @@ func Pop() {
 	}
 }
 
+// StartGroup shows status in place of any other messages until EndGroup
+// is called. Messages pushed from several goroutines in the meantime are
+// only counted, e.g. "Querying 10 resources (4 done, 3 in progress)", since the
+// order they are pushed and popped in does not make a stack.
+func StartGroup(status string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	group = status
+	groupActive = 0
+	groupDone = 0
+	if config.Debug {
+		config.Debugf(status)
+	}
+
+	update()
+}
+
+// EndGroup stops the group started by StartGroup and shows the
+// messages that were pushed before it again
+func EndGroup() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	group = ""
+	groupActive = 0
+	groupDone = 0
+
+	if console.IsTTY {
+		update()
+	}
+}
+
 // Pause pauses the spinner so that you can interact with the console
 func Pause() {
+	mu.Lock()
+	defer mu.Unlock()
+
 	paused = true
 
 	if console.IsTTY {
@@ -124,6 +228,9 @@ func Pause() {
 
 // Resume resumes the spinner
 func Resume() {
+	mu.Lock()
+	defer mu.Unlock()
+
 	paused = false
 
 	if console.IsTTY {
@@ -133,14 +240,38 @@ func Resume() {
 
 // Stop empties all spinner messages and stops the spinner
 func Stop() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stop()
+}
+
+// stop empties all spinner messages. The caller must hold mu.
+func stop() {
 	statuses = make([]string, 0)
+	group = ""
+	groupActive = 0
+	groupDone = 0
 
 	if console.IsTTY {
 		update()
 	}
 }
 
+// Disable empties all spinner messages and prevents the spinner from
+// writing anything to the console, for commands that need clean output
+func Disable() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stop()
+	disabled = true
+}
+
 // Update causes the spinner to update - use this if you have changed the display and need the spinner to redraw
 func Update() {
+	mu.Lock()
+	defer mu.Unlock()
+
 	update()
 }
@@ -0,0 +1,40 @@
+package spinner
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestConcurrentGroup(t *testing.T) {
+	Push("outer")
+	StartGroup("Querying 20 resources")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Push("resource")
+			Pop()
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	status, _ := current()
+	mu.Unlock()
+	if status != "Querying 20 resources (20 done)" {
+		t.Errorf("unexpected status %q", status)
+	}
+
+	EndGroup()
+
+	mu.Lock()
+	status, _ = current()
+	mu.Unlock()
+	if status != "outer" {
+		t.Errorf("expected the status pushed before the group, got %q", status)
+	}
+
+	Pop()
+}
@@ -0,0 +1,56 @@
+package console
+
+import (
+	"strings"
+
+	"github.com/gookit/color"
+)
+
+// Table returns rows as aligned columns under a header line.
+// Column widths fit the widest cell, ignoring colour codes,
+// so cells can be coloured. The header is bold if the console supports colours.
+// Rows with fewer cells than the header are padded with empty cells.
+func Table(header []string, rows [][]string) string {
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = visibleWidth(h)
+	}
+	for _, row := range rows {
+		for i := 0; i < len(row) && i < len(widths); i++ {
+			widths[i] = max(widths[i], visibleWidth(row[i]))
+		}
+	}
+
+	out := strings.Builder{}
+
+	writeRow := func(cells []string, style func(...interface{}) string) {
+		line := strings.Builder{}
+		for i := range widths {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			if style != nil {
+				cell = style(cell)
+			}
+			line.WriteString(cell)
+			if i < len(widths)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-visibleWidth(cell)+2))
+			}
+		}
+		out.WriteString(strings.TrimRight(line.String(), " "))
+		out.WriteString("\n")
+	}
+
+	writeRow(header, Bold)
+	for _, row := range rows {
+		writeRow(row, nil)
+	}
+
+	return out.String()
+}
+
+// visibleWidth returns the number of characters in s that take up space on the console
+func visibleWidth(s string) int {
+	return len([]rune(color.ClearCode(s)))
+}
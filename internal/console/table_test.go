@@ -0,0 +1,30 @@
+package console
+
+import "testing"
+
+func TestTable(t *testing.T) {
+	defer func(n bool) { NoColour = n }(NoColour)
+	NoColour = true
+
+	actual := Table(
+		[]string{"Name", "Type", "Status"},
+		[][]string{
+			{"Bucket", "AWS::S3::Bucket", "Ok"},
+			{"Q", "AWS::SQS::Queue", "Drift"},
+			{"Short"},
+		},
+	)
+	expected := "Name    Type             Status\n" +
+		"Bucket  AWS::S3::Bucket  Ok\n" +
+		"Q       AWS::SQS::Queue  Drift\n" +
+		"Short\n"
+
+	if actual != expected {
+		t.Errorf("%#v\n!=\n%#v\n", actual, expected)
+	}
+
+	// Colour codes don't count towards the width
+	if w := visibleWidth("\x1b[32mOk\x1b[0m"); w != 2 {
+		t.Errorf("expected a width of 2, got %d", w)
+	}
+}
@@ -0,0 +1,70 @@
+package console
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultWidth is the width that Width falls back to when stdout is not a terminal
+const DefaultWidth = 80
+
+// Width returns the width of the console in characters,
+// or DefaultWidth if stdout is not a terminal or its width is unknown
+func Width() int {
+	if !IsTTY {
+		return DefaultWidth
+	}
+	if w, _ := Size(); w > 0 {
+		return w
+	}
+	return DefaultWidth
+}
+
+// Wrap splits line into lines that are at most width characters wide,
+// breaking at the last space that fits, or in the middle of a word that doesn't.
+// The lines after the first are indented by indent spaces, so that they
+// line up under the start of the value they continue.
+// The line is returned as it is if width is 0 or less, or it already fits.
+func Wrap(line string, width int, indent int) []string {
+	if width <= 0 || utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+
+	// There has to be room for something after the indentation
+	indent = min(indent, width/2)
+	prefix := strings.Repeat(" ", indent)
+
+	lines := make([]string, 0)
+	rest := []rune(line)
+	room := width
+	for len(rest) > room {
+		cut := room
+		if i := lastSpace(rest[:room+1]); i > 0 && strings.TrimSpace(string(rest[:i])) != "" {
+			cut = i
+		}
+		lines = append(lines, strings.TrimRight(string(rest[:cut]), " "))
+		rest = []rune(strings.TrimLeft(string(rest[cut:]), " "))
+		if len(lines) == 1 {
+			room = width - indent
+		}
+	}
+	if len(rest) > 0 {
+		lines = append(lines, string(rest))
+	}
+
+	for i := 1; i < len(lines); i++ {
+		lines[i] = prefix + lines[i]
+	}
+
+	return lines
+}
+
+// lastSpace returns the index of the last space in r, or -1 if there is none
+func lastSpace(r []rune) int {
+	for i := len(r) - 1; i >= 0; i-- {
+		if r[i] == ' ' {
+			return i
+		}
+	}
+	return -1
+}
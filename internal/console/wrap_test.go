@@ -0,0 +1,27 @@
+package console
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	cases := []struct {
+		line     string
+		width    int
+		indent   int
+		expected []string
+	}{
+		{"Short: value", 20, 4, []string{"Short: value"}},
+		{"Long: one two three four", 0, 4, []string{"Long: one two three four"}},
+		{"Long: one two three four", 12, 6, []string{"Long: one", "      two", "      three", "      four"}},
+		{"Data: abcdefghijklmnop", 10, 2, []string{"Data:", "  abcdefgh", "  ijklmnop"}},
+		{"abcdefghij", 4, 0, []string{"abcd", "efgh", "ij"}},
+	}
+
+	for _, c := range cases {
+		if actual := Wrap(c.line, c.width, c.indent); !slices.Equal(actual, c.expected) {
+			t.Errorf("%#v\n!=\n%#v\n", actual, c.expected)
+		}
+	}
+}
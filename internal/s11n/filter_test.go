@@ -0,0 +1,104 @@
+package s11n
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFilter(t *testing.T) {
+	var doc yaml.Node
+	err := yaml.Unmarshal([]byte(`
+Type: AWS::S3::Bucket
+Properties:
+  BucketName: foo
+Tags:
+  - first
+  - second
+`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := doc.Content[0]
+
+	testCases := []struct {
+		name     string
+		node     *yaml.Node
+		query    []string
+		expected bool
+	}{
+		{"no query", n, []string{}, true},
+		{"match", n, []string{"Type==AWS::S3::Bucket"}, true},
+		{"non-match", n, []string{"Type==AWS::SQS::Queue"}, false},
+		{"missing key", n, []string{"Missing==foo"}, false},
+		{"non-scalar value", n, []string{"Properties==foo"}, false},
+		{"non-scalar value with empty query value", n, []string{"Properties=="}, false},
+		{"nested match", n, []string{"Properties.BucketName==foo"}, true},
+		{"sequence index", n, []string{"Tags.1==second"}, true},
+		{"negative sequence index", n, []string{"Tags.-1==second"}, false},
+		{"sequence index out of range", n, []string{"Tags.2==second"}, false},
+		{"all terms match", n, []string{"Type==AWS::S3::Bucket", "Properties.BucketName==foo"}, true},
+		{"second term fails", n, []string{"Type==AWS::S3::Bucket", "Properties.BucketName==bar"}, false},
+		{"scalar node", n.Content[1], []string{"Type==AWS::S3::Bucket"}, false},
+		{"key exists", n, []string{"Properties.BucketName"}, true},
+		{"key does not exist", n, []string{"DeletionPolicy"}, false},
+		{"negated missing key", n, []string{"!DeletionPolicy"}, true},
+		{"negated existing key", n, []string{"!Properties"}, false},
+		{"negated comparison", n, []string{"!Type==AWS::SQS::Queue"}, true},
+		{"negated with other terms", n, []string{"Type==AWS::S3::Bucket", "!Tags.2"}, true},
+	}
+
+	for _, tc := range testCases {
+		if actual := filter(tc.node, tc.query); actual != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestMatchAllMultipleQueries(t *testing.T) {
+	var doc yaml.Node
+	err := yaml.Unmarshal([]byte(`
+Resources:
+  A:
+    Type: AWS::S3::Bucket
+    Condition: IsProd
+  B:
+    Type: AWS::S3::Bucket
+`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make([]*yaml.Node, 0)
+	for n := range MatchAll(&doc, "Resources/*|Type==AWS::S3::Bucket|Condition==IsProd") {
+		results = append(results, n)
+	}
+
+	if len(results) != 1 || GetValue(results[0], "Condition") != "IsProd" {
+		t.Errorf("expected only resource A to match, got %d results", len(results))
+	}
+}
+
+func TestMatchAllMissingKey(t *testing.T) {
+	var doc yaml.Node
+	err := yaml.Unmarshal([]byte(`
+Resources:
+  A:
+    Type: AWS::S3::Bucket
+    DeletionPolicy: Retain
+  B:
+    Type: AWS::S3::Bucket
+`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make([]*yaml.Node, 0)
+	for n := range MatchAll(&doc, "Resources/*|!DeletionPolicy") {
+		results = append(results, n)
+	}
+
+	if len(results) != 1 || GetValue(results[0], "DeletionPolicy") != "" {
+		t.Errorf("expected only resource B to match, got %d results", len(results))
+	}
+}
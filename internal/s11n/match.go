@@ -1,6 +1,8 @@
 package s11n
 
 import (
+	"context"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -13,9 +15,16 @@ import (
 // If zero or more than one node matches the provided path,
 // MatchOne will return nil
 func MatchOne(node *yaml.Node, path string) *yaml.Node {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Once there are two results there can't be exactly one
 	results := make([]*yaml.Node, 0)
-	for n := range MatchAll(node, path) {
+	for n := range MatchAllContext(ctx, node, path) {
 		results = append(results, n)
+		if len(results) > 1 {
+			break
+		}
 	}
 
 	if len(results) != 1 {
@@ -29,126 +38,379 @@ func MatchOne(node *yaml.Node, path string) *yaml.Node {
 // The path is a `/`-separated string that describes a path into the template's tree.
 // Wildcard elements (which can be map keys or array indices) are represented by a `*`.
 // Matching an arbitrary number (including zero) of descendents can be done with `**`.
+// `**{1,3}` matches between 1 and 3 levels of descendents, and `**{2}` exactly 2.
+// An element like `[BucketName,KeyName]` matches any of the listed map keys or array indices.
+//
+// An element can be followed by `|` and a query that the matched node must satisfy,
+// e.g. `Resources/*|Type==AWS::S3::Bucket` or `Resources/*|Properties.Enabled==true`.
+// The query key can be dotted to look into nested nodes, and the supported
+// operators are ==, !=, <, >, <= and >=. Numbers and booleans are compared
+// according to their YAML tag; everything else is compared as a string.
+// A query with no operator, like `Resources/*|DeletionPolicy`, only checks
+// that the key exists, and a query starting with ! is negated, so
+// `Resources/*|!DeletionPolicy` matches resources that have no DeletionPolicy.
+//
+// MatchAll does not follow aliases: an alias node is only matched as a leaf,
+// and nothing beneath it is visited. Use cft.Template.ExpandAliases
+// first to match through anchored blocks.
+//
+// The matches are sent from a goroutine that only stops once every match
+// has been read. Use MatchAllContext to stop reading before the channel is closed.
 func MatchAll(node *yaml.Node, path string) <-chan *yaml.Node {
+	return MatchAllContext(context.Background(), node, path)
+}
+
+// MatchAllContext is like MatchAll, but stops looking for matches and closes
+// the channel when ctx is cancelled, so callers can stop reading early
+// without leaking the goroutine.
+func MatchAllContext(ctx context.Context, node *yaml.Node, path string) <-chan *yaml.Node {
 	ch := make(chan *yaml.Node)
 	go func() {
-		matchPath(ch, node, strings.Split(path, "/"))
+		m := matcher{func(n *yaml.Node, _ []string) bool {
+			select {
+			case ch <- n:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}}
+		m.matchPath(node, strings.Split(path, "/"), nil)
+		close(ch)
+	}()
+
+	return ch
+}
+
+// Match is a node found by MatchAllWithPath, along with the concrete
+// path to it, e.g. Resources/Bucket for the pattern Resources/*
+type Match struct {
+	Path string
+	Node *yaml.Node
+}
+
+// MatchAllWithPath is like MatchAll, but also sends the path to each match,
+// made of the map keys and sequence indices that lead to it.
+// This tells callers which node matched a wildcard or a descent.
+func MatchAllWithPath(node *yaml.Node, path string) <-chan Match {
+	return MatchAllWithPathContext(context.Background(), node, path)
+}
+
+// MatchAllWithPathContext is like MatchAllWithPath, but stops looking
+// for matches and closes the channel when ctx is cancelled
+func MatchAllWithPathContext(ctx context.Context, node *yaml.Node, path string) <-chan Match {
+	ch := make(chan Match)
+	go func() {
+		m := matcher{func(n *yaml.Node, resolved []string) bool {
+			select {
+			case ch <- Match{Path: strings.Join(resolved, "/"), Node: n}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}}
+		m.matchPath(node, strings.Split(path, "/"), nil)
 		close(ch)
 	}()
 
 	return ch
 }
 
-func matchPath(ch chan<- *yaml.Node, n *yaml.Node, path []string) {
+// matcher passes each matching node and the path to it to send,
+// which returns false when the search should stop
+type matcher struct {
+	send func(n *yaml.Node, resolved []string) bool
+}
+
+// join returns resolved with elem appended, without changing resolved
+func join(resolved []string, elem string) []string {
+	return append(resolved[:len(resolved):len(resolved)], elem)
+}
+
+// matchPath sends the nodes beneath n that match path.
+// resolved is the concrete path from the root to n.
+// It returns false if the search should stop.
+func (m matcher) matchPath(n *yaml.Node, path []string, resolved []string) bool {
 	if n.Kind == yaml.DocumentNode {
 		for _, doc := range n.Content {
-			matchPath(ch, doc, path)
+			if !m.matchPath(doc, path, resolved) {
+				return false
+			}
 		}
-		return
+		return true
 	}
 
 	if len(path) == 0 {
-		ch <- n
-		return
+		return m.send(n, resolved)
 	}
 
 	head, tail := path[0], path[1:]
 	query := make([]string, 0)
 
 	// Deal with recursive descent
-	if head == "**" {
-		matchPath(ch, n, tail)
-
-		if n.Kind == yaml.MappingNode {
-			for i := 0; i < len(n.Content); i += 2 {
-				if len(n.Content) <= i+1 {
-					config.Debugf("About to step over array at %v:%s", i, n.Content[i].Value)
-					config.Debugf("n:\n%v", node.ToSJson(n))
-				}
-				matchPath(ch, n.Content[i+1], path)
-			}
-		} else if n.Kind == yaml.SequenceNode {
-			for _, child := range n.Content {
-				matchPath(ch, child, path)
-			}
-		}
+	if minDepth, maxDepth, ok := parseDescent(head); ok {
+		return m.descend(n, tail, resolved, 0, minDepth, maxDepth)
 	}
 
 	// Parse out any query
 	parts := strings.Split(head, "|")
-	if len(parts) == 2 {
+	if len(parts) > 1 {
 		head = parts[0]
 		query = parts[1:]
 	}
 
+	// Parse out any alternation, like [BucketName,KeyName]
+	var alternatives []string
+	if len(head) > 1 && head[0] == '[' && head[len(head)-1] == ']' {
+		for _, a := range strings.Split(head[1:len(head)-1], ",") {
+			alternatives = append(alternatives, strings.TrimSpace(a))
+		}
+	}
+
+	matchesHead := func(key string) bool {
+		if alternatives != nil {
+			return slices.Contains(alternatives, key)
+		}
+		return head == "*" || key == head
+	}
+
 	if n.Kind == yaml.MappingNode {
 		for i := 0; i < len(n.Content); i += 2 {
 			key := n.Content[i]
 
-			if head == "*" || key.Value == head {
+			if matchesHead(key.Value) {
 				if len(n.Content) <= i+1 {
 					config.Debugf("About to step over array at head == \"*\", i=%v, key=%v, n=\n%v",
 						i, key.Value, node.ToSJson(n))
 				}
 				value := n.Content[i+1]
 				if filter(value, query) {
-					matchPath(ch, value, tail)
+					if !m.matchPath(value, tail, join(resolved, key.Value)) {
+						return false
+					}
 				}
 			}
 		}
 	} else if n.Kind == yaml.SequenceNode {
-		if head == "*" {
-			for _, child := range n.Content {
-				if filter(child, query) {
-					matchPath(ch, child, tail)
+		if head == "*" || alternatives != nil {
+			for i, child := range n.Content {
+				if matchesHead(strconv.Itoa(i)) && filter(child, query) {
+					if !m.matchPath(child, tail, join(resolved, strconv.Itoa(i))) {
+						return false
+					}
 				}
 			}
 		} else {
 			i, err := strconv.Atoi(head)
-			if err == nil && i < len(n.Content) {
+			if err == nil && i >= 0 && i < len(n.Content) {
 				value := n.Content[i]
 				if filter(value, query) {
-					matchPath(ch, value, tail)
+					if !m.matchPath(value, tail, join(resolved, strconv.Itoa(i))) {
+						return false
+					}
 				}
 			}
 		}
 	}
+
+	return true
 }
 
-func filter(n *yaml.Node, query []string) bool {
-	for _, q := range query {
-		parts := strings.Split(q, "==")
+// parseDescent parses a recursive descent element: ** for any depth,
+// **{min,max} for between min and max levels deep, or **{n} for exactly n levels.
+// maxDepth is -1 when there is no limit. ok is false if head is not a descent.
+func parseDescent(head string) (minDepth int, maxDepth int, ok bool) {
+	if head == "**" {
+		return 0, -1, true
+	}
+
+	bounds, found := strings.CutPrefix(head, "**{")
+	if !found {
+		return 0, 0, false
+	}
+	bounds, found = strings.CutSuffix(bounds, "}")
+	if !found {
+		return 0, 0, false
+	}
+
+	lo, hi, hasRange := strings.Cut(bounds, ",")
+	if !hasRange {
+		hi = lo
+	}
+
+	minDepth, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil || minDepth < 0 {
+		return 0, 0, false
+	}
+	maxDepth, err = strconv.Atoi(strings.TrimSpace(hi))
+	if err != nil || maxDepth < minDepth {
+		return 0, 0, false
+	}
+
+	return minDepth, maxDepth, true
+}
+
+// descend matches tail against n and its descendants that are
+// between minDepth and maxDepth levels below the node the descent started from.
+// It returns false if the search should stop.
+func (m matcher) descend(n *yaml.Node, tail []string, resolved []string, depth int, minDepth int, maxDepth int) bool {
+	if depth >= minDepth && !m.matchPath(n, tail, resolved) {
+		return false
+	}
+
+	if maxDepth >= 0 && depth >= maxDepth {
+		return true
+	}
+
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i < len(n.Content); i += 2 {
+			if len(n.Content) <= i+1 {
+				config.Debugf("About to step over array at %v:%s", i, n.Content[i].Value)
+				config.Debugf("n:\n%v", node.ToSJson(n))
+				break
+			}
+			if !m.descend(n.Content[i+1], tail, join(resolved, n.Content[i].Value), depth+1, minDepth, maxDepth) {
+				return false
+			}
+		}
+	} else if n.Kind == yaml.SequenceNode {
+		for i, child := range n.Content {
+			if !m.descend(child, tail, join(resolved, strconv.Itoa(i)), depth+1, minDepth, maxDepth) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// queryOperators are the comparisons supported in a query.
+// Two-character operators come first so that >= is not read as >
+var queryOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// parseQuery splits a query like Properties.Enabled==true into
+// its key path, operator, and value. A query with no operator
+// only checks that the key exists.
+func parseQuery(q string) (keys []string, op string, val string) {
+	idx := -1
+	for _, o := range queryOperators {
+		i := strings.Index(q, o)
+		if i >= 0 && (idx == -1 || i < idx) {
+			idx = i
+			op = o
+		}
+	}
+
+	key := q
+	if idx >= 0 {
+		key, val = q[:idx], q[idx+len(op):]
+	}
+
+	return strings.Split(key, "."), op, val
+}
 
+// queryValue descends into n by each key, which can be a map key or a sequence index
+func queryValue(n *yaml.Node, keys []string) *yaml.Node {
+	for _, k := range keys {
 		var value *yaml.Node
 		if n.Kind == yaml.MappingNode {
 			for i := 0; i < len(n.Content); i += 2 {
-				if n.Content[i].Value == parts[0] {
+				if n.Content[i].Value == k {
 					value = n.Content[i+1]
 					break
 				}
 			}
+		} else if n.Kind == yaml.SequenceNode {
+			i, err := strconv.Atoi(k)
+			if err == nil && i >= 0 && i < len(n.Content) {
+				value = n.Content[i]
+			}
+		}
 
-			if value == nil {
-				return false
+		if value == nil {
+			return nil
+		}
+		n = value
+	}
+
+	return n
+}
+
+// compare applies op to a scalar node and a query value.
+// !!int and !!float nodes are compared numerically and !!bool nodes as booleans.
+// Anything else is compared as a string, which only supports == and !=
+func compare(n *yaml.Node, op string, val string) bool {
+	if n.Kind != yaml.ScalarNode {
+		return false
+	}
+
+	switch n.ShortTag() {
+	case "!!int", "!!float":
+		a, aerr := strconv.ParseFloat(n.Value, 64)
+		b, berr := strconv.ParseFloat(val, 64)
+		if aerr == nil && berr == nil {
+			switch op {
+			case "==":
+				return a == b
+			case "!=":
+				return a != b
+			case ">":
+				return a > b
+			case "<":
+				return a < b
+			case ">=":
+				return a >= b
+			case "<=":
+				return a <= b
 			}
-		} else if n.Kind == yaml.SequenceNode {
-			i, err := strconv.Atoi(parts[0])
-			if err != nil || i >= len(n.Content) {
-				return false
+		}
+	case "!!bool":
+		a, aerr := strconv.ParseBool(n.Value)
+		b, berr := strconv.ParseBool(val)
+		if aerr == nil && berr == nil {
+			switch op {
+			case "==":
+				return a == b
+			case "!=":
+				return a != b
 			}
-			value = n.Content[i]
-		} else {
 			return false
 		}
+	}
 
-		if len(parts) == 2 && value.Value != parts[1] {
-			if value.Kind != yaml.ScalarNode {
-				return false
-			}
+	switch op {
+	case "==":
+		return n.Value == val
+	case "!=":
+		return n.Value != val
+	}
+
+	return false
+}
 
+// filter returns true if n satisfies every query.
+// A query is a key, optionally followed by an operator and a value.
+// Dotted keys like Properties.Enabled descend into nested nodes.
+// A query that starts with ! is satisfied when the rest of it is not.
+func filter(n *yaml.Node, query []string) bool {
+	for _, q := range query {
+		q, negated := strings.CutPrefix(q, "!")
+		if satisfies(n, q) == negated {
 			return false
 		}
 	}
 
 	return true
 }
+
+// satisfies returns true if n has the key in q and, if q has
+// an operator, the value at that key compares as q requires
+func satisfies(n *yaml.Node, q string) bool {
+	keys, op, val := parseQuery(q)
+
+	value := queryValue(n, keys)
+	if value == nil {
+		return false
+	}
+
+	return op == "" || compare(value, op, val)
+}
@@ -1,8 +1,11 @@
 package s11n_test
 
 import (
+	"context"
 	"fmt"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/aws-cloudformation/rain/cft/parse"
 	"github.com/aws-cloudformation/rain/internal/s11n"
@@ -124,6 +127,22 @@ func TestMatchPath(t *testing.T) {
 		{path: "**/*|Tags", expected: []*yaml.Node{
 			toNode(get(tplMap, []interface{}{"Resources", "Queue"})),
 		}},
+		{path: "Resources/*/Properties/[BucketName,QueueName]", expected: []*yaml.Node{
+			toNode(get(tplMap, []interface{}{"Resources", "Bucket", "Properties", "BucketName"})),
+			toNode(get(tplMap, []interface{}{"Resources", "Queue", "Properties", "QueueName"})),
+		}},
+		{path: "[Parameters, Outputs]/BucketName", expected: []*yaml.Node{
+			toNode(get(tplMap, []interface{}{"Outputs", "BucketName"})),
+			toNode(get(tplMap, []interface{}{"Parameters", "BucketName"})),
+		}},
+		{path: "Resources/[Bucket,Queue]|Type==AWS::SQS::Queue", expected: []*yaml.Node{
+			toNode(get(tplMap, []interface{}{"Resources", "Queue"})),
+		}},
+		{path: "Resources/Queue/Tags/[1,5]/Key", expected: []*yaml.Node{
+			toNode(get(tplMap, []interface{}{"Resources", "Queue", "Tags", 1, "Key"})),
+		}},
+		{path: "Resources/[Missing]", expected: []*yaml.Node{}},
+		{path: "Resources/Queue/Tags/-1", expected: []*yaml.Node{}},
 	}
 
 	tpl, _ := parse.Map(tplMap)
@@ -148,3 +167,182 @@ func TestMatchPath(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchPathQuery(t *testing.T) {
+	source := `
+Resources:
+  A:
+    Type: AWS::S3::Bucket
+    Properties:
+      Enabled: true
+      Count: 3
+      Ratio: 2.5
+  B:
+    Type: AWS::S3::Bucket
+    Properties:
+      Enabled: "true"
+      Count: 10
+      Ratio: 1.5
+  C:
+    Type: AWS::SQS::Queue
+    Properties:
+      Enabled: false
+      Count: "3"
+`
+	tpl, err := parse.String(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path     string
+		expected []string
+	}{
+		{"Resources/*|Type==AWS::S3::Bucket", []string{"A", "B"}},
+		{"Resources/*|Type!=AWS::S3::Bucket", []string{"C"}},
+		{"Resources/*|Properties.Enabled==true", []string{"A", "B"}},
+		{"Resources/*|Properties.Enabled!=true", []string{"C"}},
+		{"Resources/*|Properties.Count==3", []string{"A", "C"}},
+		{"Resources/*|Properties.Count==3.0", []string{"A"}},
+		{"Resources/*|Properties.Count>3", []string{"B"}},
+		{"Resources/*|Properties.Count>=3", []string{"A", "B"}},
+		{"Resources/*|Properties.Count<10", []string{"A"}},
+		{"Resources/*|Properties.Count<=10", []string{"A", "B"}},
+		{"Resources/*|Properties.Ratio<2", []string{"B"}},
+		{"Resources/*|Properties.Missing==1", []string{}},
+		{"Resources/*|Properties==1", []string{}},
+	}
+
+	for _, testCase := range testCases {
+		results := make([]*yaml.Node, 0)
+		for n := range s11n.MatchAll(tpl.Node, testCase.path) {
+			results = append(results, n)
+		}
+		expected := make([]*yaml.Node, 0)
+		for _, name := range testCase.expected {
+			n, _ := tpl.GetResource(name)
+			expected = append(expected, n)
+		}
+
+		if len(results) != len(expected) {
+			t.Errorf("%s: Expected %d results, got %d", testCase.path, len(expected), len(results))
+			continue
+		}
+
+		for i := range results {
+			if results[i] != expected[i] {
+				t.Errorf("%s: result %d is not %s", testCase.path, i, testCase.expected[i])
+			}
+		}
+	}
+}
+
+func TestMatchAllContextNoLeak(t *testing.T) {
+	items := make([]interface{}, 100)
+	for i := range items {
+		items[i] = map[string]interface{}{"Name": fmt.Sprint(i)}
+	}
+	n := toNode(map[string]interface{}{"Items": items})
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 50; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		for range s11n.MatchAllContext(ctx, n, "Items/*/Name") {
+			break
+		}
+		cancel()
+
+		// MatchOne stops reading once it has more than one result
+		if s11n.MatchOne(n, "Items/*/Name") != nil {
+			t.Errorf("expected no result when more than one node matches")
+		}
+	}
+
+	// Give the cancelled goroutines a moment to return
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("leaked %d goroutines", after-before)
+	}
+}
+
+func TestMatchPathBoundedDescent(t *testing.T) {
+	n := toNode(map[string]interface{}{
+		"Name": "0",
+		"A": map[string]interface{}{
+			"Name": "1",
+			"B": map[string]interface{}{
+				"Name": "2",
+				"C": map[string]interface{}{
+					"Name": "3",
+				},
+			},
+		},
+	})
+
+	testCases := []struct {
+		path     string
+		expected []string
+	}{
+		{"**/Name", []string{"0", "1", "2", "3"}},
+		{"**{0,1}/Name", []string{"0", "1"}},
+		{"**{1,2}/Name", []string{"1", "2"}},
+		{"**{2}/Name", []string{"2"}},
+		{"**{ 1 , 3 }/Name", []string{"1", "2", "3"}},
+		{"**{3,1}/Name", []string{}},
+		{"**{x}/Name", []string{}},
+	}
+
+	for _, testCase := range testCases {
+		actual := make([]string, 0)
+		for found := range s11n.MatchAll(n, testCase.path) {
+			actual = append(actual, found.Value)
+		}
+		if d := cmp.Diff(testCase.expected, actual); d != "" {
+			t.Errorf("%s: %s", testCase.path, d)
+		}
+	}
+}
+
+func TestMatchAllWithPath(t *testing.T) {
+	tpl, err := parse.String(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      Tags:
+        - Key: a
+          Value: "1"
+  Queue:
+    Type: AWS::SQS::Queue
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]string{
+		"Resources/*":                          {"Resources/Bucket", "Resources/Queue"},
+		"Resources/*|Type==AWS::SQS::Queue":    {"Resources/Queue"},
+		"Resources/Bucket/Properties/Tags/*":   {"Resources/Bucket/Properties/Tags/0"},
+		"**/Key":                               {"Resources/Bucket/Properties/Tags/0/Key"},
+		"Resources/[Queue,Missing]/Type":       {"Resources/Queue/Type"},
+		"Resources/Bucket/Properties/Tags/0/*": {"Resources/Bucket/Properties/Tags/0/Key", "Resources/Bucket/Properties/Tags/0/Value"},
+	}
+
+	for path, expected := range cases {
+		actual := make([]string, 0)
+		for m := range s11n.MatchAllWithPath(tpl.Node, path) {
+			if s11n.MatchOne(tpl.Node, m.Path) != m.Node {
+				t.Errorf("%s: the node does not match its own path %s", path, m.Path)
+			}
+			actual = append(actual, m.Path)
+		}
+		if d := cmp.Diff(expected, actual); d != "" {
+			t.Errorf("%s: %s", path, d)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package s11n
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/aws-cloudformation/rain/internal/config"
 	"github.com/aws-cloudformation/rain/internal/node"
@@ -9,18 +10,12 @@ import (
 )
 
 // GetMapValue returns the key and value nodes from node that matches key.
-// if node is not a mapping node or the key does not exist, GetMapValue returns nil
+// The key node is what to use for the line number of, or comments on, the key.
+// If node is not a mapping node or the key does not exist, GetMapValue
+// returns nil nodes and an error.
 func GetMapValue(n *yaml.Node, key string) (*yaml.Node, *yaml.Node, error) {
-	if n == nil {
-		return nil, nil, fmt.Errorf("node is nil for key %s", key)
-	}
-
-	if n.Kind != yaml.MappingNode {
-		return nil, nil, fmt.Errorf("kind is %v for key %s", n.Kind, key)
-	}
-
-	if len(n.Content)%2 != 0 {
-		return nil, nil, fmt.Errorf("uneven length %v for key %s", len(n.Content), key)
+	if err := checkMap(n, key); err != nil {
+		return nil, nil, err
 	}
 
 	for i := 0; i < len(n.Content); i += 2 {
@@ -39,6 +34,82 @@ func GetMapValue(n *yaml.Node, key string) (*yaml.Node, *yaml.Node, error) {
 	return nil, nil, fmt.Errorf("key %s not found", key)
 }
 
+// checkMap returns an error if n is not a mapping node with a value for every key
+func checkMap(n *yaml.Node, key string) error {
+	if n == nil {
+		return fmt.Errorf("node is nil for key %s", key)
+	}
+
+	if n.Kind != yaml.MappingNode {
+		return fmt.Errorf("kind is %v for key %s", n.Kind, key)
+	}
+
+	if len(n.Content)%2 != 0 {
+		return fmt.Errorf("uneven length %v for key %s", len(n.Content), key)
+	}
+
+	return nil
+}
+
+// SetMapValue sets the value of key in a mapping node. The value of an
+// existing key is replaced in place, otherwise the key is appended.
+// Unlike node.SetMapValue, only keys are compared, never values.
+func SetMapValue(n *yaml.Node, key string, value *yaml.Node) error {
+	if err := checkMap(n, key); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content[i+1] = value
+			return nil
+		}
+	}
+
+	n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	return nil
+}
+
+// DeleteMapValue removes key and its value from a mapping node.
+// It does nothing if the key is not in the map.
+func DeleteMapValue(n *yaml.Node, key string) error {
+	if err := checkMap(n, key); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content = append(n.Content[:i:i], n.Content[i+2:]...)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// GetMapValueFold is like GetMapValue but compares keys case-insensitively.
+// If more than one key matches, an exact match is preferred, otherwise
+// the first match is returned. mismatch is true when the returned key's
+// casing differs from key.
+func GetMapValueFold(n *yaml.Node, key string) (keyNode *yaml.Node, valueNode *yaml.Node, mismatch bool, err error) {
+	keyNode, valueNode, err = GetMapValue(n, key)
+	if err == nil {
+		return keyNode, valueNode, false, nil
+	}
+
+	if n == nil || n.Kind != yaml.MappingNode || len(n.Content)%2 != 0 {
+		return nil, nil, false, err
+	}
+
+	for i := 0; i < len(n.Content); i += 2 {
+		if strings.EqualFold(n.Content[i].Value, key) {
+			return n.Content[i], n.Content[i+1], true, nil
+		}
+	}
+
+	return nil, nil, false, fmt.Errorf("key %s not found", key)
+}
+
 // GetValue tries to get a scalar value from a mapping node
 // If anything goes wrong, it returns an empty string.
 // Use GetMapValue if you need more control.
@@ -81,3 +81,121 @@ func TestGetValue(t *testing.T) {
 		t.Fatal("expected foo: bar")
 	}
 }
+
+func TestGetMapValue(t *testing.T) {
+	var base yaml.Node
+	err := yaml.Unmarshal([]byte(nodeTestBase), &base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := base.Content[0]
+
+	k, v, err := s11n.GetMapValue(n, "baz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k != n.Content[2] || v != n.Content[3] {
+		t.Errorf("expected the key and value nodes of baz")
+	}
+	if k.Line != 3 {
+		t.Errorf("expected baz on line 3, got %d", k.Line)
+	}
+
+	k, v, err = s11n.GetMapValue(n, "missing")
+	if err == nil || k != nil || v != nil {
+		t.Errorf("expected an error and no nodes for a missing key")
+	}
+
+	if _, _, err := s11n.GetMapValue(v, "foo"); err == nil {
+		t.Errorf("expected an error for a nil node")
+	}
+}
+
+func TestGetMapValueFold(t *testing.T) {
+	var base yaml.Node
+	err := yaml.Unmarshal([]byte("BucketName: a\nbucketname: b\nTags: c\n"), &base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := base.Content[0]
+
+	testCases := []struct {
+		key      string
+		value    string
+		mismatch bool
+	}{
+		{"BucketName", "a", false},
+		{"bucketname", "b", false},
+		{"BUCKETNAME", "a", true},
+		{"tags", "c", true},
+	}
+
+	for _, testCase := range testCases {
+		_, v, mismatch, err := s11n.GetMapValueFold(n, testCase.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Value != testCase.value || mismatch != testCase.mismatch {
+			t.Errorf("%s: got %s, %v; expected %s, %v", testCase.key,
+				v.Value, mismatch, testCase.value, testCase.mismatch)
+		}
+	}
+
+	if _, _, _, err := s11n.GetMapValueFold(n, "Missing"); err == nil {
+		t.Errorf("expected an error for a missing key")
+	}
+}
+
+func TestSetMapValue(t *testing.T) {
+	var base yaml.Node
+	err := yaml.Unmarshal([]byte("A: B\nB: c\n"), &base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := base.Content[0]
+
+	// B is also a value, which must not be matched
+	if err := s11n.SetMapValue(n, "B", &yaml.Node{Kind: yaml.ScalarNode, Value: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s11n.SetMapValue(n, "E", &yaml.Node{Kind: yaml.ScalarNode, Value: "f"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]string{"A": "B", "B": "d", "E": "f"} {
+		if v := s11n.GetValue(n, key); v != expected {
+			t.Errorf("%s: %#v\n!=\n%#v\n", key, v, expected)
+		}
+	}
+	if len(n.Content) != 6 {
+		t.Errorf("expected 3 keys, got %d nodes", len(n.Content))
+	}
+
+	if err := s11n.SetMapValue(&yaml.Node{Kind: yaml.SequenceNode}, "A", n); err == nil {
+		t.Errorf("expected an error for a sequence node")
+	}
+}
+
+func TestDeleteMapValue(t *testing.T) {
+	var base yaml.Node
+	err := yaml.Unmarshal([]byte("A: B\nB: c\nD: e\n"), &base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := base.Content[0]
+
+	if err := s11n.DeleteMapValue(n, "B"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s11n.DeleteMapValue(n, "Missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(n.Content) != 4 || s11n.GetValue(n, "A") != "B" || s11n.GetValue(n, "D") != "e" {
+		t.Errorf("unexpected map after delete: %d nodes", len(n.Content))
+	}
+
+	if err := s11n.DeleteMapValue(nil, "A"); err == nil {
+		t.Errorf("expected an error for a nil node")
+	}
+}
@@ -137,6 +137,8 @@ func ColouriseDiff(d diff.Diff, longFormat bool) string {
 			output.WriteString(console.Red(line))
 		case strings.HasPrefix(line, diff.Changed.String()):
 			output.WriteString(console.Blue(line))
+		case strings.HasPrefix(line, diff.TypeChanged.String()):
+			output.WriteString(console.Magenta(line))
 		case strings.HasPrefix(line, diff.Involved.String()):
 			output.WriteString(console.Grey(line))
 		default: